@@ -0,0 +1,106 @@
+package circuit
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+)
+
+// BlindedCircuitId identifies the lower level circuits of epochs that hide their totals: Circuit hashing a random
+// blinding factor with its asset sum, so that the MerkleRootWithAssetSumHash it publishes cannot be checked against
+// a guess of the total. It does not bind the epoch context.
+const BlindedCircuitId = "v5-mimc-bn254-depth10-btc-eth-blinded-sum" + UnboundSuffix
+
+// Blinding is how a circuit blinds the hash of its asset sum. Circuits that blind are those of BlindedCircuitId.
+type Blinding int
+
+const (
+	// NoBlinding hashes the asset sum alone
+	NoBlinding Blinding = iota
+	// BlindSum hashes a blinding factor with the asset sum, as bottom level proofs of ledger accounts do
+	BlindSum
+	// BlindSubtrees also hashes every account with the blinding factor of the lower level proof it stands for
+	BlindSubtrees
+)
+
+// NewBlindedCircuit returns the circuit for accountCount accounts blinding its asset sum as given. It neither counts
+// accounts nor binds the epoch context.
+func NewBlindedCircuit(accountCount int, blinding Blinding) *Circuit {
+	if blinding == NoBlinding {
+		panic("a blinded circuit must blind its asset sum")
+	}
+	circuit := &Circuit{Accounts: make([]Account, accountCount), SumBlinding: make([]frontend.Variable, 1), blinding: blinding}
+	if blinding == BlindSubtrees {
+		circuit.AccountBlindings = make([]frontend.Variable, accountCount)
+	}
+	return circuit
+}
+
+// assertBlinding checks the circuit has one blinding factor for its asset sum and, if its accounts are lower level
+// proofs, one for each of them. The factors are any field elements, so they take part in no other constraint.
+func (circuit *Circuit) assertBlinding() {
+	accountBlindings := 0
+	if circuit.blinding == BlindSubtrees {
+		accountBlindings = len(circuit.Accounts)
+	}
+	sumBlindings := 1
+	if circuit.blinding == NoBlinding {
+		sumBlindings = 0
+	}
+	if len(circuit.AccountBlindings) != accountBlindings || len(circuit.SumBlinding) != sumBlindings {
+		panic("the circuit does not have a blinding factor for each blinded hash")
+	}
+	if circuit.blinding != NoBlinding && (circuit.counting != NoCounting || len(circuit.Context) != 0) {
+		panic("a blinded circuit neither counts accounts nor binds the epoch context")
+	}
+}
+
+// BlindingOf is how the circuit proving accounts with the given asset sum blinds it: not at all if the asset sum
+// has no Blinding, and otherwise also the accounts if they have one, as the proofs of a blinded lower level do.
+func BlindingOf(accounts []GoAccount, assetSum GoBalance) Blinding {
+	blinded := 0
+	for _, account := range accounts {
+		if account.Balance.Blinding != nil {
+			blinded++
+		}
+	}
+	if blinded != 0 && blinded != len(accounts) {
+		panic(fmt.Sprintf("%d of %d accounts have a blinding factor, but accounts must all have one or none", blinded, len(accounts)))
+	}
+	switch {
+	case assetSum.Blinding == nil && blinded == 0:
+		return NoBlinding
+	case assetSum.Blinding == nil:
+		panic("the asset sum of blinded accounts must be blinded too")
+	case blinded == 0:
+		return BlindSum
+	}
+	return BlindSubtrees
+}
+
+// ConvertGoAccountBlindings returns the AccountBlindings of a circuit proving goAccounts, or nil unless they are
+// blinded lower level proofs.
+func ConvertGoAccountBlindings(goAccounts []GoAccount) (blindings []frontend.Variable) {
+	if len(goAccounts) == 0 || goAccounts[0].Balance.Blinding == nil {
+		return nil
+	}
+	blindings = make([]frontend.Variable, len(goAccounts))
+	for i, goAccount := range goAccounts {
+		if goAccount.Balance.Blinding == nil {
+			panic("accounts must all have a blinding factor or none")
+		}
+		blindings[i] = new(big.Int).Set(goAccount.Balance.Blinding)
+	}
+	return blindings
+}
+
+// GoRandomSumBlinding returns a random blinding factor for the hash of an asset sum: a uniform field element.
+func GoRandomSumBlinding() *big.Int {
+	var blinding fr.Element
+	if _, err := blinding.SetRandom(); err != nil {
+		panic(err)
+	}
+	return blinding.BigInt(new(big.Int))
+}
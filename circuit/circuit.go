@@ -8,6 +8,9 @@ import (
 
 const TreeDepth = 10
 
+// AssetCount is the number of assets in a Balance.
+const AssetCount = 2
+
 type Balance struct {
 	Bitcoin  frontend.Variable
 	Ethereum frontend.Variable
@@ -18,11 +21,97 @@ type Account struct {
 	Balance Balance
 }
 
+// Counting is how a circuit counts the accounts its proof covers. Circuits that count accounts are those of
+// CountingCircuitId: they hash each leaf's count with its balance, and their total with the asset sum.
+type Counting int
+
+const (
+	// NoCounting is the CircuitId circuit, which does not count accounts
+	NoCounting Counting = iota
+	// CountLeaves counts every account as 0 or 1, as bottom level proofs count ledger accounts but not padding
+	CountLeaves
+	// CountSubtrees counts every account as the accounts counted by the lower level proof it stands for
+	CountSubtrees
+)
+
+// Binding is whether a circuit binds its proof to an epoch context. Only the top level proof of an epoch has to:
+// it commits to the lower level proofs through its Merkle root, so the proofs of batches that did not change can
+// stand in the epochs that follow.
+type Binding int
+
+const (
+	// BindsContext takes the epoch context as public inputs, as top level circuits do
+	BindsContext Binding = iota
+	// Unbound takes no epoch context, as lower level circuits; their ids end in UnboundSuffix
+	Unbound
+)
+
+// UnboundSuffix ends the id of the Unbound variant of a circuit.
+const UnboundSuffix = "-unbound"
+
+// CircuitId is the id of the variant of the circuit of the given id that binds the epoch context as given.
+func (binding Binding) CircuitId(circuitId string) string {
+	if binding == Unbound {
+		return circuitId + UnboundSuffix
+	}
+	return circuitId
+}
+
+// EpochContext is the epoch, snapshot timestamp and issuer id hash a proof is bound to.
+type EpochContext struct {
+	Epoch             frontend.Variable
+	SnapshotTimestamp frontend.Variable
+	IssuerIdHash      frontend.Variable
+}
+
+// newContext returns the epoch context of a circuit binding it as given: one context, or none.
+func newContext(binding Binding) []EpochContext {
+	if binding == Unbound {
+		return nil
+	}
+	return make([]EpochContext, 1)
+}
+
+// assertEpochContext makes the epoch context take part in constraints, so that the proof is bound to it and cannot
+// be replayed under another epoch, snapshot or issuer.
+func assertEpochContext(api frontend.API, ranger frontend.Rangechecker, context []EpochContext) {
+	if len(context) > 1 {
+		panic("a circuit binds at most one epoch context")
+	}
+	for _, c := range context {
+		ranger.Check(c.Epoch, 64)
+		ranger.Check(c.SnapshotTimestamp, 64)
+		api.AssertIsDifferent(c.IssuerIdHash, 0)
+	}
+}
+
 type Circuit struct {
-	Accounts                   []Account         `gnark:""`
-	AssetSum                   Balance           `gnark:""`
-	MerkleRoot                 frontend.Variable `gnark:",public"`
-	MerkleRootWithAssetSumHash frontend.Variable `gnark:",public"`
+	Accounts []Account `gnark:""`
+	AssetSum Balance   `gnark:""`
+	// AccountCounts holds how many accounts each of Accounts counts for, and AccountCount their total; both are
+	// empty unless the circuit counts accounts
+	AccountCounts              []frontend.Variable `gnark:""`
+	AccountCount               []frontend.Variable `gnark:""`
+	MerkleRoot                 frontend.Variable   `gnark:",public"`
+	MerkleRootWithAssetSumHash frontend.Variable   `gnark:",public"`
+	// Context holds the epoch context the proof is bound to, and is empty unless the circuit binds one
+	Context []EpochContext `gnark:",public"`
+	// AccountBlindings holds the blinding factor of each of Accounts and SumBlinding that of AssetSum, hashed after
+	// them; both are empty unless the circuit blinds its asset sum, and AccountBlindings unless it blinds subtrees
+	AccountBlindings []frontend.Variable `gnark:""`
+	SumBlinding      []frontend.Variable `gnark:""`
+	counting         Counting
+	blinding         Blinding
+}
+
+// NewCircuit returns the circuit for accountCount accounts, counting them and binding the epoch context as given.
+func NewCircuit(accountCount int, counting Counting, binding Binding) *Circuit {
+	circuit := &Circuit{Accounts: make([]Account, accountCount), Context: newContext(binding), counting: counting}
+	if counting != NoCounting {
+		circuit.AccountCounts = make([]frontend.Variable, accountCount)
+		circuit.AccountCount = make([]frontend.Variable, 1)
+	}
+	return circuit
 }
 
 func PowOfTwo(n int) (result int) {
@@ -48,29 +137,37 @@ func addBalance(api frontend.API, a, b Balance) Balance {
 	}
 }
 
-func hashBalance(hasher mimc.MiMC, balances Balance) (hash frontend.Variable) {
+// hashBalance hashes the balances, followed by the extras: the account count if the circuit counts accounts, the
+// gross balances and prices of a cross-margin subtree, or the blinding factor of a blinded one.
+func hashBalance(hasher mimc.MiMC, balances Balance, extras ...frontend.Variable) (hash frontend.Variable) {
 	hasher.Reset()
 	// TODO: don't manually enumerate
 	hasher.Write(balances.Bitcoin, balances.Ethereum)
+	hasher.Write(extras...)
 	return hasher.Sum()
 }
 
-func hashAccount(hasher mimc.MiMC, account Account) (hash frontend.Variable) {
+func hashAccount(hasher mimc.MiMC, account Account, extras ...frontend.Variable) (hash frontend.Variable) {
+	balanceHash := hashBalance(hasher, account.Balance, extras...)
 	hasher.Reset()
-	hasher.Write(account.UserId, hashBalance(hasher, account.Balance))
+	hasher.Write(account.UserId, balanceHash)
 	return hasher.Sum()
 }
 
-func computeMerkleRootFromAccounts(api frontend.API, hasher mimc.MiMC, accounts []Account) (rootHash frontend.Variable) {
-	nodes := make([]frontend.Variable, PowOfTwo(TreeDepth))
-	for i := 0; i < PowOfTwo(TreeDepth); i++ {
-		if i < len(accounts) {
+// computeMerkleRootFromAccounts hashes the accounts into the root of a tree of the given depth. Unless extras is
+// empty, extras[i] is hashed with the balance of accounts[i].
+func computeMerkleRootFromAccounts(api frontend.API, hasher mimc.MiMC, accounts []Account, extras [][]frontend.Variable, depth int) (rootHash frontend.Variable) {
+	nodes := make([]frontend.Variable, PowOfTwo(depth))
+	for i := 0; i < PowOfTwo(depth); i++ {
+		if i < len(accounts) && len(extras) > 0 {
+			nodes[i] = hashAccount(hasher, accounts[i], extras[i]...)
+		} else if i < len(accounts) {
 			nodes[i] = hashAccount(hasher, accounts[i])
 		} else {
 			nodes[i] = 0
 		}
 	}
-	for i := TreeDepth - 1; i >= 0; i-- {
+	for i := depth - 1; i >= 0; i-- {
 		for j := 0; j < PowOfTwo(i); j++ {
 			hasher.Reset()
 			hasher.Write(nodes[j*2], nodes[j*2+1])
@@ -85,6 +182,34 @@ func assertBalancesAreEqual(api frontend.API, a, b Balance) {
 	api.AssertIsEqual(a.Ethereum, b.Ethereum)
 }
 
+// assertAccountCount checks AccountCount is the total of AccountCounts. Each leaf of a bottom level proof counts
+// at most one account, so the count cannot exceed the number of leaves, and a leaf counting none has no balance.
+func (circuit *Circuit) assertAccountCount(api frontend.API, ranger frontend.Rangechecker) {
+	if circuit.counting == NoCounting {
+		if len(circuit.AccountCounts) != 0 || len(circuit.AccountCount) != 0 {
+			panic("the circuit does not count accounts")
+		}
+		return
+	}
+	if len(circuit.AccountCounts) != len(circuit.Accounts) || len(circuit.AccountCount) != 1 {
+		panic("the circuit counts accounts but does not have a count for each account and one total")
+	}
+	var runningCount frontend.Variable = 0
+	for i, count := range circuit.AccountCounts {
+		if circuit.counting == CountLeaves {
+			api.AssertIsBoolean(count)
+			// a leaf that is not counted is padding, which must add nothing to the asset sum
+			uncounted := api.Sub(1, count)
+			api.AssertIsEqual(api.Mul(uncounted, circuit.Accounts[i].Balance.Bitcoin), 0)
+			api.AssertIsEqual(api.Mul(uncounted, circuit.Accounts[i].Balance.Ethereum), 0)
+		} else {
+			ranger.Check(count, 64)
+		}
+		runningCount = api.Add(runningCount, count)
+	}
+	api.AssertIsEqual(runningCount, circuit.AccountCount[0])
+}
+
 func (circuit *Circuit) Define(api frontend.API) error {
 	if len(circuit.Accounts) > PowOfTwo(TreeDepth) {
 		panic("number of accounts exceeds the maximum number of leaves in the Merkle tree")
@@ -100,9 +225,22 @@ func (circuit *Circuit) Define(api frontend.API) error {
 		runningBalance = addBalance(api, runningBalance, account.Balance)
 	}
 	assertBalancesAreEqual(api, runningBalance, circuit.AssetSum)
-	root := computeMerkleRootFromAccounts(api, hasher, circuit.Accounts)
+	ranger := rangecheck.New(api)
+	circuit.assertAccountCount(api, ranger)
+	circuit.assertBlinding()
+	// a circuit either counts accounts or blinds subtrees, so each account has at most one extra
+	extras := make([][]frontend.Variable, 0, len(circuit.Accounts))
+	for _, count := range circuit.AccountCounts {
+		extras = append(extras, []frontend.Variable{count})
+	}
+	for _, blinding := range circuit.AccountBlindings {
+		extras = append(extras, []frontend.Variable{blinding})
+	}
+	root := computeMerkleRootFromAccounts(api, hasher, circuit.Accounts, extras, TreeDepth)
 	api.AssertIsEqual(root, circuit.MerkleRoot)
-	rootWithSum := hashAccount(hasher, Account{UserId: circuit.MerkleRoot, Balance: circuit.AssetSum})
+	sumExtras := append(append([]frontend.Variable{}, circuit.AccountCount...), circuit.SumBlinding...)
+	rootWithSum := hashAccount(hasher, Account{UserId: circuit.MerkleRoot, Balance: circuit.AssetSum}, sumExtras...)
 	api.AssertIsEqual(rootWithSum, circuit.MerkleRootWithAssetSumHash)
+	assertEpochContext(api, ranger, circuit.Context)
 	return nil
 }
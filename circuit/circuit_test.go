@@ -3,6 +3,7 @@ package circuit
 import (
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/test"
 	"math/big"
 	"testing"
@@ -15,6 +16,7 @@ var baseCircuit = initBaseCircuit(count)
 func initBaseCircuit(count int) *Circuit {
 	return &Circuit{
 		Accounts: make([]Account, count),
+		Context:  make([]EpochContext, 1),
 	}
 }
 
@@ -27,6 +29,7 @@ func TestCircuitWorks(t *testing.T) {
 	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
 	c.MerkleRoot = goMerkleRoot
 	c.MerkleRootWithAssetSumHash = goMerkleRootWithHash
+	c.Context = []EpochContext{{Epoch: 0, SnapshotTimestamp: 0, IssuerIdHash: GoComputeIssuerIdHash("test")}}
 
 	assert.ProverSucceeded(baseCircuit, &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
 }
@@ -38,11 +41,12 @@ func TestCircuitDoesNotAcceptNegativeAccounts(t *testing.T) {
 	goAccounts, _, _, _ := GenerateTestData(count, 0)
 	goAccounts[0].Balance.Bitcoin = *big.NewInt(-1)
 	c.Accounts = ConvertGoAccountsToAccounts(goAccounts)
-	goAssetSum := SumGoAccountBalancesIncludingNegatives(goAccounts)
+	goAssetSum := SumGoAccountBalancesInField(goAccounts)
 	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
 	merkleRoot := GoComputeMerkleRootFromAccounts(goAccounts)
 	c.MerkleRoot = merkleRoot
-	c.MerkleRootWithAssetSumHash = GoComputeMiMCHashForAccount(GoAccount{merkleRoot, goAssetSum})
+	c.MerkleRootWithAssetSumHash = GoComputeMiMCHashForAccount(GoAccount{UserId: merkleRoot, Balance: goAssetSum})
+	c.Context = []EpochContext{{Epoch: 0, SnapshotTimestamp: 0, IssuerIdHash: GoComputeIssuerIdHash("test")}}
 
 	assert.ProverFailed(baseCircuit, &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
 }
@@ -62,7 +66,8 @@ func TestCircuitDoesNotAcceptAccountsWithOverflow(t *testing.T) {
 	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
 	merkleRoot := GoComputeMerkleRootFromAccounts(goAccounts)
 	c.MerkleRoot = merkleRoot
-	c.MerkleRootWithAssetSumHash = GoComputeMiMCHashForAccount(GoAccount{merkleRoot, goAssetSum})
+	c.MerkleRootWithAssetSumHash = GoComputeMiMCHashForAccount(GoAccount{UserId: merkleRoot, Balance: goAssetSum})
+	c.Context = []EpochContext{{Epoch: 0, SnapshotTimestamp: 0, IssuerIdHash: GoComputeIssuerIdHash("test")}}
 
 	assert.ProverFailed(baseCircuit, &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
 }
@@ -76,6 +81,7 @@ func TestCircuitDoesNotAcceptInvalidMerkleRoot(t *testing.T) {
 	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
 	c.MerkleRoot = 123
 	c.MerkleRootWithAssetSumHash = goMerkleRootWithHash
+	c.Context = []EpochContext{{Epoch: 0, SnapshotTimestamp: 0, IssuerIdHash: GoComputeIssuerIdHash("test")}}
 
 	assert.ProverFailed(baseCircuit, &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
 }
@@ -89,6 +95,217 @@ func TestCircuitDoesNotAcceptInvalidMerkleRootWithSumHash(t *testing.T) {
 	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
 	c.MerkleRoot = merkleRoot
 	c.MerkleRootWithAssetSumHash = 123
+	c.Context = []EpochContext{{Epoch: 0, SnapshotTimestamp: 0, IssuerIdHash: GoComputeIssuerIdHash("test")}}
+
+	assert.ProverFailed(baseCircuit, &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestCircuitAcceptsEpochContext(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var c Circuit
+	goAccounts, goAssetSum, goMerkleRoot, goMerkleRootWithHash := GenerateTestData(count, 0)
+	c.Accounts = ConvertGoAccountsToAccounts(goAccounts)
+	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
+	c.MerkleRoot = goMerkleRoot
+	c.MerkleRootWithAssetSumHash = goMerkleRootWithHash
+	c.Context = []EpochContext{{Epoch: 42, SnapshotTimestamp: 1767225600, IssuerIdHash: GoComputeIssuerIdHash("test")}}
+
+	assert.ProverSucceeded(baseCircuit, &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestCircuitDoesNotAcceptEpochWithOverflow(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var c Circuit
+	goAccounts, goAssetSum, goMerkleRoot, goMerkleRootWithHash := GenerateTestData(count, 0)
+	c.Accounts = ConvertGoAccountsToAccounts(goAccounts)
+	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
+	c.MerkleRoot = goMerkleRoot
+	c.MerkleRootWithAssetSumHash = goMerkleRootWithHash
+	c.Context = []EpochContext{{Epoch: new(big.Int).Lsh(big.NewInt(1), 64), SnapshotTimestamp: 0, IssuerIdHash: GoComputeIssuerIdHash("test")}}
+
+	assert.ProverFailed(baseCircuit, &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestCircuitDoesNotAcceptSnapshotTimestampWithOverflow(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var c Circuit
+	goAccounts, goAssetSum, goMerkleRoot, goMerkleRootWithHash := GenerateTestData(count, 0)
+	c.Accounts = ConvertGoAccountsToAccounts(goAccounts)
+	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
+	c.MerkleRoot = goMerkleRoot
+	c.MerkleRootWithAssetSumHash = goMerkleRootWithHash
+	c.Context = []EpochContext{{Epoch: 0, SnapshotTimestamp: -1, IssuerIdHash: GoComputeIssuerIdHash("test")}}
+
+	assert.ProverFailed(baseCircuit, &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestCircuitDoesNotAcceptMissingIssuer(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var c Circuit
+	goAccounts, goAssetSum, goMerkleRoot, goMerkleRootWithHash := GenerateTestData(count, 0)
+	c.Accounts = ConvertGoAccountsToAccounts(goAccounts)
+	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
+	c.MerkleRoot = goMerkleRoot
+	c.MerkleRootWithAssetSumHash = goMerkleRootWithHash
+	c.Context = []EpochContext{{Epoch: 0, SnapshotTimestamp: 0, IssuerIdHash: 0}}
 
 	assert.ProverFailed(baseCircuit, &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
 }
+
+func TestUnboundCircuitTakesNoEpochContext(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	var c Circuit
+	goAccounts, goAssetSum, goMerkleRoot, goMerkleRootWithHash := GenerateTestData(count, 0)
+	c.Accounts = ConvertGoAccountsToAccounts(goAccounts)
+	c.AssetSum = ConvertGoBalanceToBalance(goAssetSum)
+	c.MerkleRoot = goMerkleRoot
+	c.MerkleRootWithAssetSumHash = goMerkleRootWithHash
+
+	assert.ProverSucceeded(NewCircuit(count, NoCounting, Unbound), &c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+	assert.Equal(CircuitId+UnboundSuffix, Unbound.CircuitId(CircuitId))
+	assert.Equal(CircuitId, BindsContext.CircuitId(CircuitId))
+}
+
+// countedAssignment is the assignment of the circuit counting goAccounts with their AccountCounts.
+func countedAssignment(goAccounts []GoAccount) *Circuit {
+	goAssetSum := SumGoAccountBalancesIncludingNegatives(goAccounts)
+	goAssetSum.AccountCount = big.NewInt(0)
+	for _, account := range goAccounts {
+		goAssetSum.AccountCount.Add(goAssetSum.AccountCount, account.Balance.AccountCount)
+	}
+	merkleRoot := GoComputeMerkleRootFromAccounts(goAccounts)
+	return &Circuit{
+		Accounts:                   ConvertGoAccountsToAccounts(goAccounts),
+		AssetSum:                   ConvertGoBalanceToBalance(goAssetSum),
+		AccountCounts:              ConvertGoAccountCounts(goAccounts),
+		AccountCount:               []frontend.Variable{goAssetSum.AccountCount},
+		MerkleRoot:                 merkleRoot,
+		MerkleRootWithAssetSumHash: GoComputeMiMCHashForAccount(GoAccount{UserId: merkleRoot, Balance: goAssetSum}),
+		Context:                    []EpochContext{{Epoch: 0, SnapshotTimestamp: 0, IssuerIdHash: GoComputeIssuerIdHash("test")}},
+	}
+}
+
+func TestCircuitCountsAccounts(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	goAccounts, _, _, _ := GenerateTestData(count, 0)
+	for i := range goAccounts {
+		// the last four accounts are padding, of zero balance
+		goAccounts[i].Balance.AccountCount = big.NewInt(0)
+		if i < count-4 {
+			goAccounts[i].Balance.AccountCount.SetInt64(1)
+		} else {
+			goAccounts[i].Balance.Bitcoin.SetInt64(0)
+			goAccounts[i].Balance.Ethereum.SetInt64(0)
+		}
+	}
+	assert.ProverSucceeded(NewCircuit(count, CountLeaves, BindsContext), countedAssignment(goAccounts), test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	// a leaf of a bottom level proof counts at most one account, but a lower level proof may count many
+	goAccounts[0].Balance.AccountCount = big.NewInt(2)
+	assert.ProverFailed(NewCircuit(count, CountLeaves, BindsContext), countedAssignment(goAccounts), test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+	assert.ProverSucceeded(NewCircuit(count, CountSubtrees, BindsContext), countedAssignment(goAccounts), test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	// a leaf that is not counted must be padding of zero balance
+	goAccounts[0].Balance.AccountCount = big.NewInt(0)
+	assert.ProverFailed(NewCircuit(count, CountLeaves, BindsContext), countedAssignment(goAccounts), test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+	goAccounts[0].Balance.Bitcoin.SetInt64(0)
+	goAccounts[0].Balance.Ethereum.SetInt64(0)
+	assert.ProverSucceeded(NewCircuit(count, CountLeaves, BindsContext), countedAssignment(goAccounts), test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	// the total must be that of the leaves, even if it is the one hashed with the merkle root
+	c := countedAssignment(goAccounts)
+	goAssetSum := SumGoAccountBalances(goAccounts)
+	goAssetSum.AccountCount.Add(goAssetSum.AccountCount, big.NewInt(1))
+	c.AccountCount = []frontend.Variable{goAssetSum.AccountCount}
+	c.MerkleRootWithAssetSumHash = GoComputeMiMCHashForAccount(GoAccount{UserId: c.MerkleRoot.([]byte), Balance: goAssetSum})
+	assert.ProverFailed(NewCircuit(count, CountSubtrees, BindsContext), c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+// blindedAssignment is the assignment of the circuit proving goAccounts with a blinded asset sum.
+func blindedAssignment(goAccounts []GoAccount) *Circuit {
+	goAssetSum := SumGoAccountBalances(goAccounts)
+	goAssetSum.Blinding = GoRandomSumBlinding()
+	merkleRoot := GoComputeMerkleRootFromAccounts(goAccounts)
+	return &Circuit{
+		Accounts:                   ConvertGoAccountsToAccounts(goAccounts),
+		AssetSum:                   ConvertGoBalanceToBalance(goAssetSum),
+		AccountBlindings:           ConvertGoAccountBlindings(goAccounts),
+		SumBlinding:                []frontend.Variable{goAssetSum.Blinding},
+		MerkleRoot:                 merkleRoot,
+		MerkleRootWithAssetSumHash: GoComputeMiMCHashForAccount(GoAccount{UserId: merkleRoot, Balance: goAssetSum}),
+	}
+}
+
+func TestBlindedCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	goAccounts, _, _, _ := GenerateTestData(count, 0)
+	assert.ProverSucceeded(NewBlindedCircuit(count, BlindSum), blindedAssignment(goAccounts), test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	// the hash of a blinded sum is not that of the sum alone
+	c := blindedAssignment(goAccounts)
+	goAssetSum := SumGoAccountBalances(goAccounts)
+	c.MerkleRootWithAssetSumHash = GoComputeMiMCHashForAccount(GoAccount{UserId: c.MerkleRoot.([]byte), Balance: goAssetSum})
+	assert.ProverFailed(NewBlindedCircuit(count, BlindSum), c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	// the accounts of a mid level proof are blinded lower level proofs, hashed with their blinding factors
+	for i := range goAccounts {
+		goAccounts[i].Balance.Blinding = GoRandomSumBlinding()
+	}
+	goAssetSum.Blinding = GoRandomSumBlinding()
+	assert.Equal(BlindSubtrees, BlindingOf(goAccounts, goAssetSum))
+	assert.ProverSucceeded(NewBlindedCircuit(count, BlindSubtrees), blindedAssignment(goAccounts), test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+	c = blindedAssignment(goAccounts)
+	c.AccountBlindings[0] = GoRandomSumBlinding()
+	assert.ProverFailed(NewBlindedCircuit(count, BlindSubtrees), c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+// hiddenTotalAssignment is the assignment of the hidden total circuit for goAccounts, blinded mid level proofs, and
+// the given reserves.
+func hiddenTotalAssignment(t *testing.T, goAccounts []GoAccount, reserves GoBalance) *HiddenTotalCircuit {
+	goAssetSum := SumGoAccountBalances(goAccounts)
+	blinding := GoRandomBlinding()
+	commitment, err := ConvertGoCommitmentToCommitment(GoCommitToBalance(goAssetSum, blinding))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &HiddenTotalCircuit{
+		Accounts:           ConvertGoAccountsToAccounts(goAccounts),
+		AccountBlindings:   ConvertGoAccountBlindings(goAccounts),
+		AssetSum:           ConvertGoBalanceToBalance(goAssetSum),
+		Blinding:           ConvertGoBalanceToBalance(blinding),
+		MerkleRoot:         GoComputeMerkleRootFromAccounts(goAccounts),
+		AssetSumCommitment: commitment,
+		Reserves:           ConvertGoBalanceToBalance(reserves),
+		Epoch:              0,
+		SnapshotTimestamp:  0,
+		IssuerIdHash:       GoComputeIssuerIdHash("test"),
+	}
+}
+
+func TestHiddenTotalCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	goAccounts, goAssetSum, _, _ := GenerateTestData(count, 0)
+	for i := range goAccounts {
+		goAccounts[i].Balance.Blinding = GoRandomSumBlinding()
+	}
+	shape := NewHiddenTotalCircuit(count)
+	assert.ProverSucceeded(shape, hiddenTotalAssignment(t, goAccounts, goAssetSum), test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	// liabilities above the reserves of either asset are not proven
+	short := GoBalance{Bitcoin: goAssetSum.Bitcoin, Ethereum: *new(big.Int).Sub(&goAssetSum.Ethereum, big.NewInt(1))}
+	assert.ProverFailed(shape, hiddenTotalAssignment(t, goAccounts, short), test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	// the commitment must be to the total
+	c := hiddenTotalAssignment(t, goAccounts, goAssetSum)
+	other := hiddenTotalAssignment(t, goAccounts[1:], goAssetSum)
+	c.AssetSumCommitment.Bitcoin = other.AssetSumCommitment.Bitcoin
+	assert.ProverFailed(shape, c, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
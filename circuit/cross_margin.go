@@ -0,0 +1,198 @@
+package circuit
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/rangecheck"
+)
+
+// CrossMarginCircuitId identifies the circuits of ledgers with margin accounts; see CrossMarginCircuit.
+const CrossMarginCircuitId = "v6-mimc-bn254-depth10-btc-eth-epoch-context-cross-margin"
+
+// Margin is what a CrossMarginCircuit takes as accounts.
+type Margin int
+
+const (
+	// NoMargin is a Circuit or HiddenTotalCircuit rather than a CrossMarginCircuit
+	NoMargin Margin = iota
+	// MarginLeaves takes ledger accounts, whose balances may be negative as long as their net value is not
+	MarginLeaves
+	// MarginSubtrees takes lower level cross-margin proofs, each with its net and gross sums
+	MarginSubtrees
+)
+
+// signedBalanceOffset shifts a balance of [-2^63, 2^63) to the 64 bits the range checks allow.
+var signedBalanceOffset = new(big.Int).Lsh(big.NewInt(1), 63)
+
+// netValueBits bounds the net value of an account: two products of a 64 bit price and a balance of at most 2^63.
+const netValueBits = 128
+
+// CrossMarginCircuit proves a ledger whose accounts may owe some assets as long as they hold others worth more. Each
+// asset's balance lies in [-2^63, 2^63), and the bottom level proves each account's net value, the sum of its
+// balances weighted by the public Prices, is not negative. AssetSum is the net total of each asset and GrossSum the
+// total of its positive balances. The upper levels add up both; the gross sums and Prices are hashed with the net
+// sums in MerkleRootWithAssetSumHash, so every proof of an epoch is bound to the same prices.
+type CrossMarginCircuit struct {
+	Accounts []Account `gnark:""`
+	// AccountGross holds the GrossSum of each lower level proof, and is empty for the bottom level
+	AccountGross               []Balance         `gnark:""`
+	AssetSum                   Balance           `gnark:""`
+	GrossSum                   Balance           `gnark:""`
+	MerkleRoot                 frontend.Variable `gnark:",public"`
+	MerkleRootWithAssetSumHash frontend.Variable `gnark:",public"`
+	Context                    []EpochContext    `gnark:",public"`
+	Prices                     Balance           `gnark:",public"`
+	margin                     Margin
+}
+
+// NewCrossMarginCircuit returns the cross-margin circuit for accountCount accounts of the given kind, binding the
+// epoch context as given.
+func NewCrossMarginCircuit(accountCount int, margin Margin, binding Binding) *CrossMarginCircuit {
+	if margin == NoMargin {
+		panic("a cross-margin circuit takes ledger accounts or cross-margin proofs")
+	}
+	circuit := &CrossMarginCircuit{Accounts: make([]Account, accountCount), Context: newContext(binding), margin: margin}
+	if margin == MarginSubtrees {
+		circuit.AccountGross = make([]Balance, accountCount)
+	}
+	return circuit
+}
+
+// assertSignedBalance checks each asset of balances lies in [-2^63, 2^63).
+func assertSignedBalance(api frontend.API, ranger frontend.Rangechecker, balances Balance) {
+	ranger.Check(api.Add(balances.Bitcoin, signedBalanceOffset), 64)
+	ranger.Check(api.Add(balances.Ethereum, signedBalanceOffset), 64)
+}
+
+// positivePart checks balance lies in [-2^63, 2^63) and returns it if it is positive, or 0.
+func positivePart(api frontend.API, balance frontend.Variable) frontend.Variable {
+	bits := api.ToBinary(api.Add(balance, signedBalanceOffset), 64)
+	// the top bit of the offset balance is set exactly when the balance is not negative
+	return api.Select(bits[63], balance, 0)
+}
+
+// assertNetValueNonNegative checks the balances are worth at least nothing at prices. A negative net value is a
+// field element far above 2^netValueBits.
+func assertNetValueNonNegative(api frontend.API, ranger frontend.Rangechecker, balances Balance, prices Balance) {
+	netValue := api.Add(api.Mul(balances.Bitcoin, prices.Bitcoin), api.Mul(balances.Ethereum, prices.Ethereum))
+	ranger.Check(netValue, netValueBits)
+}
+
+// sumExtras are what is hashed after the net balances of a cross-margin sum.
+func sumExtras(gross Balance, prices Balance) []frontend.Variable {
+	return []frontend.Variable{gross.Bitcoin, gross.Ethereum, prices.Bitcoin, prices.Ethereum}
+}
+
+func (circuit *CrossMarginCircuit) Define(api frontend.API) error {
+	if len(circuit.Accounts) > PowOfTwo(TreeDepth) {
+		panic("number of accounts exceeds the maximum number of leaves in the Merkle tree")
+	}
+	subtrees := circuit.margin == MarginSubtrees
+	if (subtrees && len(circuit.AccountGross) != len(circuit.Accounts)) || (!subtrees && len(circuit.AccountGross) != 0) {
+		panic("the gross sums must be given for every account of a subtree circuit, and only then")
+	}
+	hasher, err := mimc.NewMiMC(api)
+	if err != nil {
+		panic(err)
+	}
+	ranger := rangecheck.New(api)
+	assertBalanceNonNegativeAndNonOverflow(api, circuit.Prices)
+
+	var runningBalance = Balance{Bitcoin: 0, Ethereum: 0}
+	var runningGross = Balance{Bitcoin: 0, Ethereum: 0}
+	extras := make([][]frontend.Variable, len(circuit.AccountGross))
+	for i, account := range circuit.Accounts {
+		if !subtrees {
+			gross := Balance{Bitcoin: positivePart(api, account.Balance.Bitcoin), Ethereum: positivePart(api, account.Balance.Ethereum)}
+			assertNetValueNonNegative(api, ranger, account.Balance, circuit.Prices)
+			runningGross = addBalance(api, runningGross, gross)
+		} else {
+			// each lower level proof proved its accounts' net values, at the same prices as its hash binds it to
+			assertSignedBalance(api, ranger, account.Balance)
+			assertBalanceNonNegativeAndNonOverflow(api, circuit.AccountGross[i])
+			runningGross = addBalance(api, runningGross, circuit.AccountGross[i])
+			extras[i] = sumExtras(circuit.AccountGross[i], circuit.Prices)
+		}
+		runningBalance = addBalance(api, runningBalance, account.Balance)
+	}
+	assertBalancesAreEqual(api, runningBalance, circuit.AssetSum)
+	assertBalancesAreEqual(api, runningGross, circuit.GrossSum)
+	root := computeMerkleRootFromAccounts(api, hasher, circuit.Accounts, extras, TreeDepth)
+	api.AssertIsEqual(root, circuit.MerkleRoot)
+	rootWithSum := hashAccount(hasher, Account{UserId: circuit.MerkleRoot, Balance: circuit.AssetSum}, sumExtras(circuit.GrossSum, circuit.Prices)...)
+	api.AssertIsEqual(rootWithSum, circuit.MerkleRootWithAssetSumHash)
+	assertEpochContext(api, ranger, circuit.Context)
+	return nil
+}
+
+// GoNetValue is the value of balance at prices, which the bottom level cross-margin circuit proves is not negative.
+func GoNetValue(balance GoBalance, prices GoBalance) *big.Int {
+	value := new(big.Int).Mul(&balance.Bitcoin, &prices.Bitcoin)
+	return value.Add(value, new(big.Int).Mul(&balance.Ethereum, &prices.Ethereum))
+}
+
+func positiveOrZero(value *big.Int) *big.Int {
+	if value.Sign() < 0 {
+		return new(big.Int)
+	}
+	return value
+}
+
+// SumGoMarginBalances sums accounts as a CrossMarginCircuit at prices does: the net total of each asset, with the
+// gross total of its positive balances and the prices. Accounts that are lower level proofs carry their own gross
+// sums, which must have been proven at the same prices.
+func SumGoMarginBalances(accounts []GoAccount, prices GoBalance) GoBalance {
+	assetSum := GoBalance{Gross: &GoBalance{}, Prices: &prices}
+	for _, account := range accounts {
+		if account.Balance.AccountCount != nil {
+			panic("cross-margin balances cannot be counted")
+		}
+		assetSum.Bitcoin.Add(&assetSum.Bitcoin, &account.Balance.Bitcoin)
+		assetSum.Ethereum.Add(&assetSum.Ethereum, &account.Balance.Ethereum)
+		gross := account.Balance.Gross
+		if gross == nil {
+			gross = &GoBalance{Bitcoin: *positiveOrZero(&account.Balance.Bitcoin), Ethereum: *positiveOrZero(&account.Balance.Ethereum)}
+		} else if account.Balance.Prices == nil || !account.Balance.Prices.Equals(prices) {
+			panic("lower level cross-margin proofs must be proven at the same prices")
+		}
+		assetSum.Gross.Bitcoin.Add(&assetSum.Gross.Bitcoin, &gross.Bitcoin)
+		assetSum.Gross.Ethereum.Add(&assetSum.Gross.Ethereum, &gross.Ethereum)
+	}
+	return assetSum
+}
+
+// MarginOf tells what kind of cross-margin circuit proves accounts into assetSum: none unless assetSum has prices,
+// and one of subtrees if the accounts carry gross sums, which they must all or none do.
+func MarginOf(accounts []GoAccount, assetSum GoBalance) Margin {
+	if assetSum.Prices == nil {
+		return NoMargin
+	}
+	subtrees := 0
+	for _, account := range accounts {
+		if account.Balance.Gross != nil {
+			subtrees++
+		}
+	}
+	if subtrees != 0 && subtrees != len(accounts) {
+		panic(fmt.Sprintf("%d of %d accounts have a gross sum, but accounts must all have one or none", subtrees, len(accounts)))
+	}
+	if subtrees != 0 {
+		return MarginSubtrees
+	}
+	return MarginLeaves
+}
+
+// ConvertGoAccountGross returns the AccountGross of a subtree circuit proving goAccounts, or nil for ledger accounts.
+func ConvertGoAccountGross(goAccounts []GoAccount) (gross []Balance) {
+	if len(goAccounts) == 0 || goAccounts[0].Balance.Gross == nil {
+		return nil
+	}
+	gross = make([]Balance, len(goAccounts))
+	for i, goAccount := range goAccounts {
+		gross[i] = ConvertGoBalanceToBalance(*goAccount.Balance.Gross)
+	}
+	return gross
+}
@@ -0,0 +1,89 @@
+package circuit
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/test"
+)
+
+var testPrices = GoBalance{Bitcoin: *big.NewInt(30), Ethereum: *big.NewInt(1)}
+
+// marginAccounts are ledger accounts of which the first borrows Ethereum against Bitcoin and the second the reverse.
+func marginAccounts() []GoAccount {
+	accounts, _, _, _ := GenerateTestData(count, 0)
+	accounts[0].Balance.Ethereum = *big.NewInt(-1000)
+	accounts[1].Balance.Bitcoin = *big.NewInt(-30)
+	return accounts
+}
+
+func crossMarginAssignment(accounts []GoAccount, assetSum GoBalance, margin Margin) *CrossMarginCircuit {
+	merkleRoot := GoComputeMerkleRootFromAccounts(accounts)
+	return &CrossMarginCircuit{
+		Accounts:                   ConvertGoAccountsToAccounts(accounts),
+		AccountGross:               ConvertGoAccountGross(accounts),
+		AssetSum:                   ConvertGoBalanceToBalance(assetSum),
+		GrossSum:                   ConvertGoBalanceToBalance(*assetSum.Gross),
+		MerkleRoot:                 merkleRoot,
+		MerkleRootWithAssetSumHash: GoComputeMiMCHashForAccount(GoAccount{UserId: merkleRoot, Balance: assetSum}),
+		Context:                    []EpochContext{{Epoch: 0, SnapshotTimestamp: 0, IssuerIdHash: GoComputeIssuerIdHash("test")}},
+		Prices:                     ConvertGoBalanceToBalance(*assetSum.Prices),
+		margin:                     margin,
+	}
+}
+
+func TestCrossMarginCircuitAcceptsNegativeBalancesOfNonNegativeNetValue(t *testing.T) {
+	assert := test.NewAssert(t)
+	accounts := marginAccounts()
+	assetSum := SumGoMarginBalances(accounts, testPrices)
+	assert.Equal(-1, accounts[0].Balance.Ethereum.Sign())
+	assert.True(assetSum.Gross.Ethereum.Cmp(&assetSum.Ethereum) > 0, "the gross total leaves out the negative balances")
+
+	assert.ProverSucceeded(NewCrossMarginCircuit(count, MarginLeaves, BindsContext), crossMarginAssignment(accounts, assetSum, MarginLeaves),
+		test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestCrossMarginCircuitDoesNotAcceptNegativeNetValue(t *testing.T) {
+	assert := test.NewAssert(t)
+	accounts := marginAccounts()
+	// the first account's Bitcoin is worth less than the Ethereum it owes
+	accounts[0].Balance.Ethereum = *new(big.Int).Neg(new(big.Int).Add(GoNetValue(GoBalance{Bitcoin: accounts[0].Balance.Bitcoin}, testPrices), big.NewInt(1)))
+	assert.Equal(-1, GoNetValue(accounts[0].Balance, testPrices).Sign())
+
+	assert.ProverFailed(NewCrossMarginCircuit(count, MarginLeaves, BindsContext), crossMarginAssignment(accounts, SumGoMarginBalances(accounts, testPrices), MarginLeaves),
+		test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestCrossMarginCircuitDoesNotAcceptUnderstatedGrossSum(t *testing.T) {
+	assert := test.NewAssert(t)
+	accounts := marginAccounts()
+	assetSum := SumGoMarginBalances(accounts, testPrices)
+	// the gross total of Bitcoin claimed to be its net total
+	assetSum.Gross.Bitcoin = *new(big.Int).Set(&assetSum.Bitcoin)
+
+	assert.ProverFailed(NewCrossMarginCircuit(count, MarginLeaves, BindsContext), crossMarginAssignment(accounts, assetSum, MarginLeaves),
+		test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestCrossMarginCircuitSumsSubtrees(t *testing.T) {
+	assert := test.NewAssert(t)
+	subtrees := make([]GoAccount, 2)
+	for i := range subtrees {
+		accounts := marginAccounts()
+		subtrees[i] = GoAccount{UserId: GoComputeMerkleRootFromAccounts(accounts), Balance: SumGoMarginBalances(accounts, testPrices)}
+	}
+	assetSum := SumGoMarginBalances(subtrees, testPrices)
+	assert.True(assetSum.Prices.Equals(testPrices))
+
+	assert.ProverSucceeded(NewCrossMarginCircuit(len(subtrees), MarginSubtrees, BindsContext), crossMarginAssignment(subtrees, assetSum, MarginSubtrees),
+		test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	// a subtree proven at other prices does not hash to the same leaf at these prices
+	otherPrices := GoBalance{Bitcoin: *big.NewInt(1), Ethereum: *big.NewInt(1)}
+	subtrees[1].Balance.Prices = &otherPrices
+	assetSum.Prices = &otherPrices
+	assert.ProverFailed(NewCrossMarginCircuit(len(subtrees), MarginSubtrees, BindsContext), crossMarginAssignment(subtrees, assetSum, MarginSubtrees),
+		test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
@@ -0,0 +1,158 @@
+package circuit
+
+import (
+	"fmt"
+	"math/big"
+
+	mimcCrypto "github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/rangecheck"
+)
+
+// EstimationAccount is an Account holding a balance of each of any number of assets.
+type EstimationAccount struct {
+	UserId   frontend.Variable
+	Balances []frontend.Variable
+}
+
+// EstimationCircuit is Circuit for any tree depth and number of assets. It makes the same constraints per asset
+// and per level as Circuit, but is only meant to measure what proving with other parameters would cost: its
+// proofs never verify as those of any circuit id. See NewEstimationCircuit.
+type EstimationCircuit struct {
+	Accounts                   []EstimationAccount `gnark:""`
+	AssetSum                   []frontend.Variable `gnark:""`
+	MerkleRoot                 frontend.Variable   `gnark:",public"`
+	MerkleRootWithAssetSumHash frontend.Variable   `gnark:",public"`
+	Epoch                      frontend.Variable   `gnark:",public"`
+	SnapshotTimestamp          frontend.Variable   `gnark:",public"`
+	IssuerIdHash               frontend.Variable   `gnark:",public"`
+	depth                      int
+}
+
+func hashEstimationAccount(hasher mimc.MiMC, userId frontend.Variable, balances []frontend.Variable) frontend.Variable {
+	hasher.Reset()
+	hasher.Write(balances...)
+	balanceHash := hasher.Sum()
+	hasher.Reset()
+	hasher.Write(userId, balanceHash)
+	return hasher.Sum()
+}
+
+func (circuit *EstimationCircuit) Define(api frontend.API) error {
+	if len(circuit.Accounts) > PowOfTwo(circuit.depth) {
+		panic("number of accounts exceeds the maximum number of leaves in the Merkle tree")
+	}
+	hasher, err := mimc.NewMiMC(api)
+	if err != nil {
+		panic(err)
+	}
+	ranger := rangecheck.New(api)
+	runningBalances := make([]frontend.Variable, len(circuit.AssetSum))
+	for k := range runningBalances {
+		runningBalances[k] = 0
+	}
+	nodes := make([]frontend.Variable, PowOfTwo(circuit.depth))
+	for i := range nodes {
+		nodes[i] = 0
+	}
+	for i, account := range circuit.Accounts {
+		for k, balance := range account.Balances {
+			ranger.Check(balance, 64)
+			runningBalances[k] = api.Add(runningBalances[k], balance)
+		}
+		nodes[i] = hashEstimationAccount(hasher, account.UserId, account.Balances)
+	}
+	for k := range runningBalances {
+		api.AssertIsEqual(runningBalances[k], circuit.AssetSum[k])
+	}
+	for i := circuit.depth - 1; i >= 0; i-- {
+		for j := 0; j < PowOfTwo(i); j++ {
+			hasher.Reset()
+			hasher.Write(nodes[j*2], nodes[j*2+1])
+			nodes[j] = hasher.Sum()
+		}
+	}
+	api.AssertIsEqual(nodes[0], circuit.MerkleRoot)
+	rootWithSum := hashEstimationAccount(hasher, circuit.MerkleRoot, circuit.AssetSum)
+	api.AssertIsEqual(rootWithSum, circuit.MerkleRootWithAssetSumHash)
+	ranger.Check(circuit.Epoch, 64)
+	ranger.Check(circuit.SnapshotTimestamp, 64)
+	api.AssertIsDifferent(circuit.IssuerIdHash, 0)
+	return nil
+}
+
+// goHashEstimationAccount hashes an account of the given balances as EstimationCircuit does.
+func goHashEstimationAccount(userId []byte, balances []*big.Int) Hash {
+	hasher := mimcCrypto.NewMiMC()
+	for _, balance := range balances {
+		if _, err := hasher.Write(padToModBytes(balance.Bytes(), false)); err != nil {
+			panic(err)
+		}
+	}
+	balanceHash := hasher.Sum(nil)
+	hasher.Reset()
+	if _, err := hasher.Write(padToModBytes(userId, false)); err != nil {
+		panic(err)
+	}
+	if _, err := hasher.Write(balanceHash); err != nil {
+		panic(err)
+	}
+	return hasher.Sum(nil)
+}
+
+// NewEstimationCircuit returns the estimation circuit for accountCount accounts of the given number of assets in a
+// tree of the given depth, and an assignment that satisfies it. The first two assets are the generated test data's
+// Bitcoin and Ethereum, so at TreeDepth with AssetCount assets the tree is that of Circuit.
+func NewEstimationCircuit(accountCount int, depth int, assets int) (shape *EstimationCircuit, assignment *EstimationCircuit) {
+	if depth < 1 || accountCount < 1 || accountCount > PowOfTwo(depth) {
+		panic(fmt.Sprintf("%d accounts do not fit in a tree of depth %d", accountCount, depth))
+	}
+	if assets < 1 {
+		panic("the accounts must hold at least one asset")
+	}
+	goAccounts, _, _, _ := GenerateTestData(accountCount, 0)
+	shape = &EstimationCircuit{Accounts: make([]EstimationAccount, accountCount), AssetSum: make([]frontend.Variable, assets), depth: depth}
+	for i := range shape.Accounts {
+		shape.Accounts[i].Balances = make([]frontend.Variable, assets)
+	}
+	assignment = &EstimationCircuit{
+		Accounts:          make([]EstimationAccount, accountCount),
+		AssetSum:          make([]frontend.Variable, assets),
+		Epoch:             0,
+		SnapshotTimestamp: 0,
+		IssuerIdHash:      GoComputeIssuerIdHash("estimate"),
+		depth:             depth,
+	}
+	sums := make([]*big.Int, assets)
+	for k := range sums {
+		sums[k] = new(big.Int)
+	}
+	hashes := make([]Hash, accountCount)
+	for i, goAccount := range goAccounts {
+		balances := make([]*big.Int, assets)
+		for k := range balances {
+			switch k {
+			case 0:
+				balances[k] = new(big.Int).Set(&goAccount.Balance.Bitcoin)
+			case 1:
+				balances[k] = new(big.Int).Set(&goAccount.Balance.Ethereum)
+			default:
+				balances[k] = big.NewInt(int64(i*k + 1))
+			}
+			sums[k].Add(sums[k], balances[k])
+		}
+		assignment.Accounts[i] = EstimationAccount{UserId: new(big.Int).SetBytes(goAccount.UserId), Balances: make([]frontend.Variable, assets)}
+		for k, balance := range balances {
+			assignment.Accounts[i].Balances[k] = balance
+		}
+		hashes[i] = goHashEstimationAccount(goAccount.UserId, balances)
+	}
+	for k, sum := range sums {
+		assignment.AssetSum[k] = sum
+	}
+	merkleRoot := goComputeMerkleRoot(hashes, depth)
+	assignment.MerkleRoot = merkleRoot
+	assignment.MerkleRootWithAssetSumHash = goHashEstimationAccount(merkleRoot, sums)
+	return shape, assignment
+}
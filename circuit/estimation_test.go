@@ -0,0 +1,25 @@
+package circuit
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/test"
+)
+
+func TestEstimationCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	shape, assignment := NewEstimationCircuit(8, 3, 4)
+	assert.ProverSucceeded(shape, assignment, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	// the default depth and assets are the tree proofs are made with
+	shape, assignment = NewEstimationCircuit(8, TreeDepth, AssetCount)
+	_, _, goMerkleRoot, goMerkleRootWithAssetSumHash := GenerateTestData(8, 0)
+	assert.Equal(goMerkleRoot, assignment.MerkleRoot)
+	assert.Equal(goMerkleRootWithAssetSumHash, assignment.MerkleRootWithAssetSumHash)
+	assert.ProverSucceeded(shape, assignment, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+
+	assert.Panics(func() { NewEstimationCircuit(9, 3, AssetCount) })
+	assert.Panics(func() { NewEstimationCircuit(8, 3, 0) })
+}
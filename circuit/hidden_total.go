@@ -0,0 +1,210 @@
+package circuit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	edbn254 "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	tedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/rangecheck"
+)
+
+// HiddenTotalCircuitId identifies the top level circuit that hides the total liabilities; see HiddenTotalCircuit.
+const HiddenTotalCircuitId = "v5-mimc-bn254-depth10-btc-eth-epoch-context-hidden-total"
+
+// pedersenDomain separates the derivation of the commitments' second generator from any other hash
+const pedersenDomain = "bgproof pedersen generator v1"
+
+// Commitment holds a Pedersen commitment to each asset, a point of the twisted Edwards curve embedded in BN254.
+type Commitment struct {
+	Bitcoin  twistededwards.Point
+	Ethereum twistededwards.Point
+}
+
+// HiddenTotalCircuit is the top level circuit for proofs that do not reveal the total liabilities. Like Circuit, it
+// sums the mid level proofs it takes as accounts into AssetSum and hashes them to MerkleRoot, but AssetSum is only
+// published as AssetSumCommitment, a Pedersen commitment of each asset's total with the blinding factors in Blinding.
+// The circuit proves each total is at most the public reserve figure in Reserves, so solvency is shown without the
+// liabilities. It does not count accounts. The mid level proofs are those of BlindedCircuitId, each hashed with the
+// blinding factor of its asset sum in AccountBlindings, so that no published hash reveals a total either.
+type HiddenTotalCircuit struct {
+	Accounts           []Account           `gnark:""`
+	AccountBlindings   []frontend.Variable `gnark:""`
+	AssetSum           Balance             `gnark:""`
+	Blinding           Balance             `gnark:""`
+	MerkleRoot         frontend.Variable   `gnark:",public"`
+	AssetSumCommitment Commitment          `gnark:",public"`
+	Reserves           Balance             `gnark:",public"`
+	Epoch              frontend.Variable   `gnark:",public"`
+	SnapshotTimestamp  frontend.Variable   `gnark:",public"`
+	IssuerIdHash       frontend.Variable   `gnark:",public"`
+}
+
+// NewHiddenTotalCircuit returns the hidden total circuit for accountCount mid level proofs.
+func NewHiddenTotalCircuit(accountCount int) *HiddenTotalCircuit {
+	return &HiddenTotalCircuit{Accounts: make([]Account, accountCount), AccountBlindings: make([]frontend.Variable, accountCount)}
+}
+
+func assertIsCommitment(api frontend.API, curve twistededwards.Curve, commitment twistededwards.Point, value, blinding frontend.Variable) {
+	g, h := pedersenGenerators()
+	computed := curve.DoubleBaseScalarMul(
+		twistededwards.Point{X: g.X.BigInt(new(big.Int)), Y: g.Y.BigInt(new(big.Int))},
+		twistededwards.Point{X: h.X.BigInt(new(big.Int)), Y: h.Y.BigInt(new(big.Int))},
+		value, blinding)
+	api.AssertIsEqual(computed.X, commitment.X)
+	api.AssertIsEqual(computed.Y, commitment.Y)
+}
+
+func (circuit *HiddenTotalCircuit) Define(api frontend.API) error {
+	if len(circuit.Accounts) > PowOfTwo(TreeDepth) {
+		panic("number of accounts exceeds the maximum number of leaves in the Merkle tree")
+	}
+	var runningBalance = Balance{Bitcoin: 0, Ethereum: 0}
+	hasher, err := mimc.NewMiMC(api)
+	if err != nil {
+		panic(err)
+	}
+	for _, account := range circuit.Accounts {
+		assertBalanceNonNegativeAndNonOverflow(api, account.Balance)
+		runningBalance = addBalance(api, runningBalance, account.Balance)
+	}
+	assertBalancesAreEqual(api, runningBalance, circuit.AssetSum)
+	if len(circuit.AccountBlindings) != len(circuit.Accounts) {
+		panic("the circuit does not have a blinding factor for each mid level proof")
+	}
+	blindings := make([][]frontend.Variable, len(circuit.AccountBlindings))
+	for i, blinding := range circuit.AccountBlindings {
+		blindings[i] = []frontend.Variable{blinding}
+	}
+	root := computeMerkleRootFromAccounts(api, hasher, circuit.Accounts, blindings, TreeDepth)
+	api.AssertIsEqual(root, circuit.MerkleRoot)
+
+	// each total is at most its reserves: both reserves and what remains of them after the total fit in 64 bits
+	assertBalanceNonNegativeAndNonOverflow(api, circuit.Reserves)
+	assertBalanceNonNegativeAndNonOverflow(api, Balance{
+		Bitcoin:  api.Sub(circuit.Reserves.Bitcoin, circuit.AssetSum.Bitcoin),
+		Ethereum: api.Sub(circuit.Reserves.Ethereum, circuit.AssetSum.Ethereum),
+	})
+
+	curve, err := twistededwards.NewEdCurve(api, tedwards.BN254)
+	if err != nil {
+		panic(err)
+	}
+	assertIsCommitment(api, curve, circuit.AssetSumCommitment.Bitcoin, circuit.AssetSum.Bitcoin, circuit.Blinding.Bitcoin)
+	assertIsCommitment(api, curve, circuit.AssetSumCommitment.Ethereum, circuit.AssetSum.Ethereum, circuit.Blinding.Ethereum)
+
+	// as in Circuit, the epoch context takes part in constraints so that the proof is bound to it
+	ranger := rangecheck.New(api)
+	ranger.Check(circuit.Epoch, 64)
+	ranger.Check(circuit.SnapshotTimestamp, 64)
+	api.AssertIsDifferent(circuit.IssuerIdHash, 0)
+	return nil
+}
+
+var (
+	generatorsOnce sync.Once
+	generatorG     edbn254.PointAffine
+	generatorH     edbn254.PointAffine
+)
+
+// pedersenGenerators returns the generators G and H of the commitments: G is the curve's base point and H is found
+// by hashing pedersenDomain and a counter to a y coordinate until it is that of a curve point, which is then
+// multiplied by the cofactor. Nobody knows the discrete logarithm of H to base G, so a commitment cannot be
+// opened to another value.
+func pedersenGenerators() (g, h edbn254.PointAffine) {
+	generatorsOnce.Do(func() {
+		params := edbn254.GetEdwardsCurve()
+		generatorG = params.Base
+		for counter := uint64(0); ; counter++ {
+			hash := sha256.Sum256(binary.BigEndian.AppendUint64([]byte(pedersenDomain), counter))
+			var y, one, num, den, x fr.Element
+			y.SetBigInt(new(big.Int).SetBytes(hash[:]))
+			one.SetOne()
+			num.Square(&y)
+			den.Mul(&num, &params.D)
+			num.Sub(&one, &num)
+			den.Sub(&params.A, &den)
+			x.Div(&num, &den)
+			if x.Legendre() != 1 {
+				continue
+			}
+			x.Sqrt(&x)
+			candidate := edbn254.NewPointAffine(x, y)
+			generatorH.ScalarMultiplication(&candidate, params.Cofactor.BigInt(new(big.Int)))
+			if candidate.IsOnCurve() && !generatorH.IsZero() {
+				return
+			}
+		}
+	})
+	return generatorG, generatorH
+}
+
+// GoCommitment holds the compressed Pedersen commitment of each asset.
+type GoCommitment struct {
+	Bitcoin  []byte
+	Ethereum []byte
+}
+
+func goCommit(value, blinding *big.Int) []byte {
+	g, h := pedersenGenerators()
+	var valueG, blindingH, commitment edbn254.PointAffine
+	valueG.ScalarMultiplication(&g, value)
+	blindingH.ScalarMultiplication(&h, blinding)
+	commitment.Add(&valueG, &blindingH)
+	return commitment.Marshal()
+}
+
+// GoCommitToBalance commits to each asset of balance with the blinding factor of the same asset in blinding.
+func GoCommitToBalance(balance GoBalance, blinding GoBalance) GoCommitment {
+	return GoCommitment{
+		Bitcoin:  goCommit(&balance.Bitcoin, &blinding.Bitcoin),
+		Ethereum: goCommit(&balance.Ethereum, &blinding.Ethereum),
+	}
+}
+
+// GoRandomBlinding returns new random blinding factors, one per asset.
+func GoRandomBlinding() GoBalance {
+	order := edbn254.GetEdwardsCurve().Order
+	var blinding GoBalance
+	for _, factor := range []*big.Int{&blinding.Bitcoin, &blinding.Ethereum} {
+		random, err := rand.Int(rand.Reader, &order)
+		if err != nil {
+			panic(err)
+		}
+		factor.Set(random)
+	}
+	return blinding
+}
+
+func decompressPoint(compressed []byte) (twistededwards.Point, error) {
+	var point edbn254.PointAffine
+	if _, err := point.SetBytes(compressed); err != nil {
+		return twistededwards.Point{}, err
+	}
+	if !point.IsOnCurve() {
+		return twistededwards.Point{}, fmt.Errorf("commitment %x is not a curve point", compressed)
+	}
+	return twistededwards.Point{X: point.X.BigInt(new(big.Int)), Y: point.Y.BigInt(new(big.Int))}, nil
+}
+
+// ConvertGoCommitmentToCommitment decompresses each asset's commitment into the circuit's public inputs.
+func ConvertGoCommitmentToCommitment(goCommitment GoCommitment) (commitment Commitment, err error) {
+	if commitment.Bitcoin, err = decompressPoint(goCommitment.Bitcoin); err != nil {
+		return commitment, err
+	}
+	commitment.Ethereum, err = decompressPoint(goCommitment.Ethereum)
+	return commitment, err
+}
+
+func (goCommitment *GoCommitment) Equals(other GoCommitment) bool {
+	return bytes.Equal(goCommitment.Bitcoin, other.Bitcoin) && bytes.Equal(goCommitment.Ethereum, other.Ethereum)
+}
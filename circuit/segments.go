@@ -0,0 +1,212 @@
+package circuit
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/rangecheck"
+)
+
+// SegmentedCircuitId identifies the circuits that also prove the subtotal of each segment; see SegmentedCircuit.
+const SegmentedCircuitId = "v7-mimc-bn254-depth10-btc-eth-epoch-context-segments"
+
+// SegmentCount is the number of segments the liabilities of a SegmentedCircuit are split into.
+const SegmentCount = 3
+
+// Segmenting is what a SegmentedCircuit takes as accounts.
+type Segmenting int
+
+const (
+	// NoSegments is any circuit but a SegmentedCircuit
+	NoSegments Segmenting = iota
+	// SegmentLeaves takes ledger accounts, each tagged with its segment
+	SegmentLeaves
+	// SegmentSubtrees takes lower level segmented proofs, each with its segment subtotals
+	SegmentSubtrees
+)
+
+// SegmentedCircuit is Circuit for ledgers whose accounts are split into SegmentCount segments. Each ledger account
+// is tagged with the index of its segment, which its leaf hashes after the balance, and the circuit sums the
+// balances of each segment into Subtotals. Lower level proofs stand for their subtotals, which the upper levels add
+// up. The subtotals are hashed after the asset sum in MerkleRootWithAssetSumHash, and every level proves they add
+// up to it. It does not count accounts.
+type SegmentedCircuit struct {
+	Accounts []Account `gnark:""`
+	// AccountSegments holds the segment of each ledger account, and AccountSubtotals the Subtotals of each lower
+	// level proof; only one of them is set, depending on what the circuit takes as accounts
+	AccountSegments            []frontend.Variable     `gnark:""`
+	AccountSubtotals           [][SegmentCount]Balance `gnark:""`
+	AssetSum                   Balance                 `gnark:""`
+	Subtotals                  [SegmentCount]Balance   `gnark:""`
+	MerkleRoot                 frontend.Variable       `gnark:",public"`
+	MerkleRootWithAssetSumHash frontend.Variable       `gnark:",public"`
+	Context                    []EpochContext          `gnark:",public"`
+	segmenting                 Segmenting
+}
+
+// NewSegmentedCircuit returns the segmented circuit for accountCount accounts of the given kind, binding the epoch
+// context as given.
+func NewSegmentedCircuit(accountCount int, segmenting Segmenting, binding Binding) *SegmentedCircuit {
+	circuit := &SegmentedCircuit{Accounts: make([]Account, accountCount), Context: newContext(binding), segmenting: segmenting}
+	switch segmenting {
+	case SegmentLeaves:
+		circuit.AccountSegments = make([]frontend.Variable, accountCount)
+	case SegmentSubtrees:
+		circuit.AccountSubtotals = make([][SegmentCount]Balance, accountCount)
+	default:
+		panic("a segmented circuit takes ledger accounts or segmented proofs")
+	}
+	return circuit
+}
+
+// subtotalExtras are what is hashed after the asset sum of a segmented proof.
+func subtotalExtras(subtotals [SegmentCount]Balance) []frontend.Variable {
+	extras := make([]frontend.Variable, 0, 2*SegmentCount)
+	for _, subtotal := range subtotals {
+		extras = append(extras, subtotal.Bitcoin, subtotal.Ethereum)
+	}
+	return extras
+}
+
+func (circuit *SegmentedCircuit) Define(api frontend.API) error {
+	if len(circuit.Accounts) > PowOfTwo(TreeDepth) {
+		panic("number of accounts exceeds the maximum number of leaves in the Merkle tree")
+	}
+	subtrees := circuit.segmenting == SegmentSubtrees
+	if (subtrees && len(circuit.AccountSubtotals) != len(circuit.Accounts)) || (!subtrees && len(circuit.AccountSegments) != len(circuit.Accounts)) {
+		panic("the circuit must have a segment or subtotals for each account")
+	}
+	hasher, err := mimc.NewMiMC(api)
+	if err != nil {
+		panic(err)
+	}
+	ranger := rangecheck.New(api)
+
+	var runningBalance = Balance{Bitcoin: 0, Ethereum: 0}
+	var runningSubtotals [SegmentCount]Balance
+	for k := range runningSubtotals {
+		runningSubtotals[k] = Balance{Bitcoin: 0, Ethereum: 0}
+	}
+	extras := make([][]frontend.Variable, len(circuit.Accounts))
+	for i, account := range circuit.Accounts {
+		assertBalanceNonNegativeAndNonOverflow(api, account.Balance)
+		runningBalance = addBalance(api, runningBalance, account.Balance)
+		if subtrees {
+			for k, subtotal := range circuit.AccountSubtotals[i] {
+				assertBalanceNonNegativeAndNonOverflow(api, subtotal)
+				runningSubtotals[k] = addBalance(api, runningSubtotals[k], subtotal)
+			}
+			extras[i] = subtotalExtras(circuit.AccountSubtotals[i])
+			continue
+		}
+		// exactly one segment matches the tag, so the tag is that of a segment and the balance adds to it alone
+		var matches frontend.Variable = 0
+		for k := range runningSubtotals {
+			isSegment := api.IsZero(api.Sub(circuit.AccountSegments[i], k))
+			matches = api.Add(matches, isSegment)
+			runningSubtotals[k] = addBalance(api, runningSubtotals[k], Balance{
+				Bitcoin:  api.Mul(isSegment, account.Balance.Bitcoin),
+				Ethereum: api.Mul(isSegment, account.Balance.Ethereum),
+			})
+		}
+		api.AssertIsEqual(matches, 1)
+		extras[i] = []frontend.Variable{circuit.AccountSegments[i]}
+	}
+	assertBalancesAreEqual(api, runningBalance, circuit.AssetSum)
+	var subtotalsSum = Balance{Bitcoin: 0, Ethereum: 0}
+	for k := range runningSubtotals {
+		assertBalancesAreEqual(api, runningSubtotals[k], circuit.Subtotals[k])
+		subtotalsSum = addBalance(api, subtotalsSum, circuit.Subtotals[k])
+	}
+	assertBalancesAreEqual(api, subtotalsSum, circuit.AssetSum)
+	root := computeMerkleRootFromAccounts(api, hasher, circuit.Accounts, extras, TreeDepth)
+	api.AssertIsEqual(root, circuit.MerkleRoot)
+	rootWithSum := hashAccount(hasher, Account{UserId: circuit.MerkleRoot, Balance: circuit.AssetSum}, subtotalExtras(circuit.Subtotals)...)
+	api.AssertIsEqual(rootWithSum, circuit.MerkleRootWithAssetSumHash)
+	assertEpochContext(api, ranger, circuit.Context)
+	return nil
+}
+
+// SegmentingOf tells what kind of segmented circuit proves accounts: none unless they are tagged with segments, as
+// ledger accounts, or have subtotals, as lower level proofs. The accounts must all or none be either.
+func SegmentingOf(accounts []GoAccount) Segmenting {
+	tagged, subtotaled := 0, 0
+	for _, account := range accounts {
+		if account.Balance.Segment != nil {
+			tagged++
+		}
+		if account.Balance.Segments != nil {
+			subtotaled++
+		}
+	}
+	switch {
+	case tagged == 0 && subtotaled == 0:
+		return NoSegments
+	case tagged == len(accounts) && subtotaled == 0:
+		return SegmentLeaves
+	case subtotaled == len(accounts) && tagged == 0:
+		return SegmentSubtrees
+	}
+	panic(fmt.Sprintf("%d of %d accounts have a segment and %d subtotals, but accounts must all have one or none", tagged, len(accounts), subtotaled))
+}
+
+// sumGoSegments sums the balances of accounts per segment, as a SegmentedCircuit does.
+func sumGoSegments(accounts []GoAccount) []GoBalance {
+	subtotals := make([]GoBalance, SegmentCount)
+	for _, account := range accounts {
+		if account.Balance.Segment == nil {
+			if len(account.Balance.Segments) != SegmentCount {
+				panic(fmt.Sprintf("lower level proof has %d segment subtotals rather than %d", len(account.Balance.Segments), SegmentCount))
+			}
+			for k := range subtotals {
+				subtotals[k].Bitcoin.Add(&subtotals[k].Bitcoin, &account.Balance.Segments[k].Bitcoin)
+				subtotals[k].Ethereum.Add(&subtotals[k].Ethereum, &account.Balance.Segments[k].Ethereum)
+			}
+			continue
+		}
+		if !account.Balance.Segment.IsInt64() || account.Balance.Segment.Sign() < 0 || account.Balance.Segment.Int64() >= SegmentCount {
+			panic(fmt.Sprintf("account has segment %s, but segments are numbered from 0 to %d", account.Balance.Segment.String(), SegmentCount-1))
+		}
+		k := account.Balance.Segment.Int64()
+		subtotals[k].Bitcoin.Add(&subtotals[k].Bitcoin, &account.Balance.Bitcoin)
+		subtotals[k].Ethereum.Add(&subtotals[k].Ethereum, &account.Balance.Ethereum)
+	}
+	return subtotals
+}
+
+// ConvertGoAccountSegments returns the AccountSegments of a circuit proving goAccounts, or nil if they have none.
+func ConvertGoAccountSegments(goAccounts []GoAccount) (segments []frontend.Variable) {
+	if SegmentingOf(goAccounts) != SegmentLeaves {
+		return nil
+	}
+	segments = make([]frontend.Variable, len(goAccounts))
+	for i, goAccount := range goAccounts {
+		segments[i] = new(big.Int).Set(goAccount.Balance.Segment)
+	}
+	return segments
+}
+
+// ConvertGoSubtotals returns the subtotals of a segmented asset sum as the circuit takes them.
+func ConvertGoSubtotals(goSubtotals []GoBalance) (subtotals [SegmentCount]Balance) {
+	if len(goSubtotals) != SegmentCount {
+		panic(fmt.Sprintf("%d segment subtotals rather than %d", len(goSubtotals), SegmentCount))
+	}
+	for k, subtotal := range goSubtotals {
+		subtotals[k] = ConvertGoBalanceToBalance(subtotal)
+	}
+	return subtotals
+}
+
+// ConvertGoAccountSubtotals returns the AccountSubtotals of a circuit proving goAccounts, or nil if they have none.
+func ConvertGoAccountSubtotals(goAccounts []GoAccount) (subtotals [][SegmentCount]Balance) {
+	if SegmentingOf(goAccounts) != SegmentSubtrees {
+		return nil
+	}
+	subtotals = make([][SegmentCount]Balance, len(goAccounts))
+	for i, goAccount := range goAccounts {
+		subtotals[i] = ConvertGoSubtotals(goAccount.Balance.Segments)
+	}
+	return subtotals
+}
@@ -0,0 +1,87 @@
+package circuit
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/test"
+)
+
+// segmentedAccounts are generated ledger accounts spread over the segments in turn.
+func segmentedAccounts() []GoAccount {
+	accounts, _, _, _ := GenerateTestData(count, 0)
+	for i := range accounts {
+		accounts[i].Balance.Segment = big.NewInt(int64(i % SegmentCount))
+	}
+	return accounts
+}
+
+func segmentedAssignment(accounts []GoAccount, assetSum GoBalance, segmenting Segmenting) *SegmentedCircuit {
+	merkleRoot := GoComputeMerkleRootFromAccounts(accounts)
+	return &SegmentedCircuit{
+		Accounts:                   ConvertGoAccountsToAccounts(accounts),
+		AccountSegments:            ConvertGoAccountSegments(accounts),
+		AccountSubtotals:           ConvertGoAccountSubtotals(accounts),
+		AssetSum:                   ConvertGoBalanceToBalance(assetSum),
+		Subtotals:                  ConvertGoSubtotals(assetSum.Segments),
+		MerkleRoot:                 merkleRoot,
+		MerkleRootWithAssetSumHash: GoComputeMiMCHashForAccount(GoAccount{UserId: merkleRoot, Balance: assetSum}),
+		Context:                    []EpochContext{{Epoch: 0, SnapshotTimestamp: 0, IssuerIdHash: GoComputeIssuerIdHash("test")}},
+		segmenting:                 segmenting,
+	}
+}
+
+func TestSegmentedCircuitWorks(t *testing.T) {
+	assert := test.NewAssert(t)
+	accounts := segmentedAccounts()
+	assetSum := SumGoAccountBalances(accounts)
+	assert.Equal(SegmentCount, len(assetSum.Segments))
+	second := new(big.Int)
+	for i := 1; i < count; i += SegmentCount {
+		second.Add(second, &accounts[i].Balance.Bitcoin)
+	}
+	assert.Equal(0, assetSum.Segments[1].Bitcoin.Cmp(second), "the second segment should hold every third account")
+
+	assert.ProverSucceeded(NewSegmentedCircuit(count, SegmentLeaves, BindsContext), segmentedAssignment(accounts, assetSum, SegmentLeaves),
+		test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestSegmentedCircuitDoesNotAcceptUnknownSegment(t *testing.T) {
+	assert := test.NewAssert(t)
+	accounts := segmentedAccounts()
+	assetSum := SumGoAccountBalances(accounts)
+	// an account outside every segment would be in the total but no subtotal
+	accounts[2].Balance.Segment = big.NewInt(SegmentCount)
+
+	assert.ProverFailed(NewSegmentedCircuit(count, SegmentLeaves, BindsContext), segmentedAssignment(accounts, assetSum, SegmentLeaves),
+		test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestSegmentedCircuitDoesNotAcceptMovedBalance(t *testing.T) {
+	assert := test.NewAssert(t)
+	accounts := segmentedAccounts()
+	assetSum := SumGoAccountBalances(accounts)
+	// the grand total is right, but one account's Bitcoin is counted in the wrong segment
+	moved := &accounts[0].Balance.Bitcoin
+	assetSum.Segments[0].Bitcoin.Sub(&assetSum.Segments[0].Bitcoin, moved)
+	assetSum.Segments[2].Bitcoin.Add(&assetSum.Segments[2].Bitcoin, moved)
+
+	assert.ProverFailed(NewSegmentedCircuit(count, SegmentLeaves, BindsContext), segmentedAssignment(accounts, assetSum, SegmentLeaves),
+		test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
+
+func TestSegmentedCircuitSumsSubtrees(t *testing.T) {
+	assert := test.NewAssert(t)
+	subtrees := make([]GoAccount, 2)
+	for i := range subtrees {
+		accounts := segmentedAccounts()
+		subtrees[i] = GoAccount{UserId: GoComputeMerkleRootFromAccounts(accounts), Balance: SumGoAccountBalances(accounts)}
+	}
+	assetSum := SumGoAccountBalances(subtrees)
+	assert.Equal(0, assetSum.Segments[0].Ethereum.Cmp(new(big.Int).Mul(&subtrees[0].Balance.Segments[0].Ethereum, big.NewInt(2))))
+
+	assert.ProverSucceeded(NewSegmentedCircuit(len(subtrees), SegmentSubtrees, BindsContext), segmentedAssignment(subtrees, assetSum, SegmentSubtrees),
+		test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+}
@@ -1,8 +1,11 @@
 package circuit
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"github.com/consensys/gnark-crypto/ecc"
 	mimcCrypto "github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/consensys/gnark/frontend"
 	"math/big"
 )
 
@@ -11,30 +14,84 @@ var ModBytes = len(ecc.BN254.ScalarField().Bytes())
 type GoBalance struct {
 	Bitcoin  big.Int
 	Ethereum big.Int
+	// AccountCount is how many ledger accounts the balance is that of, when it was proven with
+	// CountingCircuitId: 1 for a ledger account, 0 for padding and the total of a proof's accounts for its asset
+	// sum. It is nil otherwise, and then takes no part in the hash.
+	AccountCount *big.Int `json:",omitempty"`
+	// Gross and Prices are set on the asset sums of proofs of CrossMarginCircuitId, whose Bitcoin and Ethereum are
+	// net of the negative balances: Gross is the total of the positive balances alone, and Prices the price vector
+	// every account's net value was proven non-negative at. Both are hashed after the balances. Ledger accounts,
+	// even of cross-margin proofs, have neither.
+	Gross  *GoBalance `json:",omitempty"`
+	Prices *GoBalance `json:",omitempty"`
+	// Segment is the segment of a ledger account proven with SegmentedCircuitId, and Segments the subtotal of each
+	// segment of a proof's asset sum, in the order of their tags; see SegmentedCircuit. Both are hashed after the
+	// balances and are unset otherwise.
+	Segment  *big.Int    `json:",omitempty"`
+	Segments []GoBalance `json:",omitempty"`
+	// Blinding is the random factor hashed last with the asset sum of a proof of BlindedCircuitId, so that its
+	// MerkleRootWithAssetSumHash does not reveal the sum; see Blinding. It is unset otherwise.
+	Blinding *big.Int `json:",omitempty"`
 }
 
+// CircuitId identifies the circuit defined in this package: its hash, tree depth, asset set and public inputs.
+// It must change whenever the circuit changes in a way that older proofs would not verify against.
+const CircuitId = "v3-mimc-bn254-depth10-btc-eth-epoch-context"
+
+// CountingCircuitId identifies the circuits that also count the accounts of each proof; see Counting.
+const CountingCircuitId = "v4-mimc-bn254-depth10-btc-eth-epoch-context-account-count"
+
+// CircuitId is the id of proofs of circuits counting accounts as given.
+func (counting Counting) CircuitId() string {
+	if counting == NoCounting {
+		return CircuitId
+	}
+	return CountingCircuitId
+}
+
+// GoAccount is serialized on its own as a user's account file and, without Version and CircuitId,
+// as each account of a batch, where the batch's own version applies.
 type GoAccount struct {
-	UserId  []byte
-	Balance GoBalance
+	Version   int    `json:",omitempty"`
+	CircuitId string `json:",omitempty"`
+	UserId    []byte
+	Balance   GoBalance
 }
 
 func goConvertBalanceToBytes(balance GoBalance) (value []byte) {
 	value = make([]byte, 0)
 	value = append(value, padToModBytes(balance.Bitcoin.Bytes(), balance.Bitcoin.Sign() == -1)...)
 	value = append(value, padToModBytes(balance.Ethereum.Bytes(), balance.Ethereum.Sign() == -1)...)
+	if balance.AccountCount != nil {
+		value = append(value, padToModBytes(balance.AccountCount.Bytes(), false)...)
+	}
+	if balance.Gross != nil {
+		value = append(value, goConvertBalanceToBytes(*balance.Gross)...)
+	}
+	if balance.Prices != nil {
+		value = append(value, goConvertBalanceToBytes(*balance.Prices)...)
+	}
+	if balance.Segment != nil {
+		value = append(value, padToModBytes(balance.Segment.Bytes(), false)...)
+	}
+	for _, subtotal := range balance.Segments {
+		value = append(value, goConvertBalanceToBytes(subtotal)...)
+	}
+	if balance.Blinding != nil {
+		value = append(value, padToModBytes(balance.Blinding.Bytes(), false)...)
+	}
 
 	return value
 }
 
+// padToModBytes pads the big endian magnitude value to ModBytes. A negative value is encoded as the field element
+// it is in the circuit: the modulus minus its magnitude.
 func padToModBytes(value []byte, isNegative bool) (paddedValue []byte) {
-	paddedValue = make([]byte, ModBytes-len(value))
-	// sign extension
 	if isNegative {
-		for i := range paddedValue {
-			paddedValue[i] = 0xFF
-		}
-		paddedValue[0] = 0x0F // this is 254 bits not 256
+		negated := new(big.Int).Sub(ecc.BN254.ScalarField(), new(big.Int).SetBytes(value))
+		return negated.FillBytes(make([]byte, ModBytes))
 	}
+	paddedValue = make([]byte, ModBytes-len(value))
 	paddedValue = append(paddedValue, value...)
 	return paddedValue
 }
@@ -56,46 +113,31 @@ func GoComputeMiMCHashForAccount(account GoAccount) []byte {
 }
 
 func GoComputeMerkleRootFromAccounts(accounts []GoAccount) (rootHash []byte) {
-	hasher := mimcCrypto.NewMiMC()
-	nodes := make([][]byte, PowOfTwo(TreeDepth))
-	for i := 0; i < PowOfTwo(TreeDepth); i++ {
-		if i < len(accounts) {
-			nodes[i] = GoComputeMiMCHashForAccount(accounts[i])
-		} else {
-			nodes[i] = padToModBytes([]byte{}, false)
-		}
-	}
-	for i := TreeDepth - 1; i >= 0; i-- {
-		for j := 0; j < PowOfTwo(i); j++ {
-			hasher.Reset()
-			_, err := hasher.Write(nodes[j*2])
-			if err != nil {
-				panic(err)
-			}
-			_, err = hasher.Write(nodes[j*2+1])
-			if err != nil {
-				panic(err)
-			}
-			nodes[j] = hasher.Sum(nil)
-		}
+	hashes := make([]Hash, len(accounts))
+	for i, account := range accounts {
+		hashes[i] = GoComputeMiMCHashForAccount(account)
 	}
-	return nodes[0]
+	return goComputeMerkleRoot(hashes, TreeDepth)
 }
 
 type Hash = []byte
 
-// GoComputeMerkleRootFromHashes TODO: consolidate with GoComputeMerkleRootFromAccounts
 func GoComputeMerkleRootFromHashes(hashes []Hash) (rootHash []byte) {
+	return goComputeMerkleRoot(hashes, TreeDepth)
+}
+
+// goComputeMerkleRoot computes the root of the tree of the given depth whose leaves are hashes, padded with zeros.
+func goComputeMerkleRoot(hashes []Hash, depth int) (rootHash []byte) {
 	hasher := mimcCrypto.NewMiMC()
-	nodes := make([][]byte, PowOfTwo(TreeDepth))
-	for i := 0; i < PowOfTwo(TreeDepth); i++ {
+	nodes := make([][]byte, PowOfTwo(depth))
+	for i := 0; i < PowOfTwo(depth); i++ {
 		if i < len(hashes) {
 			nodes[i] = hashes[i]
 		} else {
 			nodes[i] = padToModBytes([]byte{}, false)
 		}
 	}
-	for i := TreeDepth - 1; i >= 0; i-- {
+	for i := depth - 1; i >= 0; i-- {
 		for j := 0; j < PowOfTwo(i); j++ {
 			hasher.Reset()
 			_, err := hasher.Write(nodes[j*2])
@@ -112,6 +154,14 @@ func GoComputeMerkleRootFromHashes(hashes []Hash) (rootHash []byte) {
 	return nodes[0]
 }
 
+// GoComputeIssuerIdHash maps an issuer identifier to the field element the circuit takes as its IssuerIdHash
+// public input: the SHA-256 hash of the identifier, reduced modulo the field.
+func GoComputeIssuerIdHash(issuerId string) []byte {
+	hash := sha256.Sum256([]byte(issuerId))
+	reduced := new(big.Int).Mod(new(big.Int).SetBytes(hash[:]), ecc.BN254.ScalarField())
+	return padToModBytes(reduced.Bytes(), false)
+}
+
 func ConvertGoBalanceToBalance(goBalance GoBalance) Balance {
 	return Balance{
 		Bitcoin:  padToModBytes(goBalance.Bitcoin.Bytes(), goBalance.Bitcoin.Sign() == -1),
@@ -134,6 +184,32 @@ func ConvertGoAccountsToAccounts(goAccounts []GoAccount) (accounts []Account) {
 	return accounts
 }
 
+// ConvertGoAccountCounts returns the AccountCounts of a circuit counting goAccounts, or nil if they are not counted.
+func ConvertGoAccountCounts(goAccounts []GoAccount) (counts []frontend.Variable) {
+	if !areCounted(goAccounts) {
+		return nil
+	}
+	counts = make([]frontend.Variable, len(goAccounts))
+	for i, goAccount := range goAccounts {
+		counts[i] = new(big.Int).Set(goAccount.Balance.AccountCount)
+	}
+	return counts
+}
+
+// areCounted tells whether accounts carry account counts, which they must all or none do.
+func areCounted(accounts []GoAccount) bool {
+	counted := 0
+	for _, account := range accounts {
+		if account.Balance.AccountCount != nil {
+			counted++
+		}
+	}
+	if counted != 0 && counted != len(accounts) {
+		panic(fmt.Sprintf("%d of %d accounts have an account count, but accounts must all have one or none", counted, len(accounts)))
+	}
+	return counted != 0
+}
+
 // strictly for testing
 func SumGoAccountBalancesIncludingNegatives(accounts []GoAccount) GoBalance {
 	assetSum := GoBalance{Bitcoin: *big.NewInt(0), Ethereum: *big.NewInt(0)}
@@ -149,15 +225,36 @@ func SumGoAccountBalancesIncludingNegatives(accounts []GoAccount) GoBalance {
 	return assetSum
 }
 
+// strictly for testing: SumGoAccountBalancesIncludingNegatives reduced modulo the scalar field, so that the sums are
+// the field elements the circuit adds up to, and can be hashed
+func SumGoAccountBalancesInField(accounts []GoAccount) GoBalance {
+	assetSum := SumGoAccountBalancesIncludingNegatives(accounts)
+	assetSum.Bitcoin.Mod(&assetSum.Bitcoin, ecc.BN254.ScalarField())
+	assetSum.Ethereum.Mod(&assetSum.Ethereum, ecc.BN254.ScalarField())
+	return assetSum
+}
+
 func SumGoAccountBalances(accounts []GoAccount) GoBalance {
 	assetSum := GoBalance{Bitcoin: *big.NewInt(0), Ethereum: *big.NewInt(0)}
 	for _, account := range accounts {
+		if account.Balance.Gross != nil || account.Balance.Prices != nil {
+			panic("use SumGoMarginBalances for cross-margin balances")
+		}
 		if account.Balance.Bitcoin.Sign() == -1 || account.Balance.Ethereum.Sign() == -1 {
 			panic("use SumGoAccountBalancesIncludingNegatives for negative balances")
 		}
 		assetSum.Bitcoin.Add(&assetSum.Bitcoin, &account.Balance.Bitcoin)
 		assetSum.Ethereum.Add(&assetSum.Ethereum, &account.Balance.Ethereum)
 	}
+	if areCounted(accounts) {
+		assetSum.AccountCount = big.NewInt(0)
+		for _, account := range accounts {
+			assetSum.AccountCount.Add(assetSum.AccountCount, account.Balance.AccountCount)
+		}
+	}
+	if SegmentingOf(accounts) != NoSegments {
+		assetSum.Segments = sumGoSegments(accounts)
+	}
 	return assetSum
 }
 
@@ -165,7 +262,9 @@ func GenerateTestData(count int, seed int) (accounts []GoAccount, assetSum GoBal
 	for i := 0; i < count; i++ {
 		iWithSeed := (i + seed) * (seed + 1)
 		btcCount, ethCount := int64(iWithSeed+45*iWithSeed+39), int64(iWithSeed*2+iWithSeed+1001)
-		accounts = append(accounts, GoAccount{UserId: []byte("foo"), Balance: GoBalance{Bitcoin: *big.NewInt(btcCount), Ethereum: *big.NewInt(ethCount)}})
+		// user ids are unique across the batches generated with different seeds
+		userId := []byte(fmt.Sprintf("foo-%d-%d", seed, i))
+		accounts = append(accounts, GoAccount{UserId: userId, Balance: GoBalance{Bitcoin: *big.NewInt(btcCount), Ethereum: *big.NewInt(ethCount)}})
 	}
 	goAccountBalanceSum := SumGoAccountBalances(accounts)
 	merkleRoot = GoComputeMerkleRootFromAccounts(accounts)
@@ -174,5 +273,35 @@ func GenerateTestData(count int, seed int) (accounts []GoAccount, assetSum GoBal
 }
 
 func (GoBalance *GoBalance) Equals(other GoBalance) bool {
+	if (GoBalance.AccountCount == nil) != (other.AccountCount == nil) {
+		return false
+	}
+	if GoBalance.AccountCount != nil && GoBalance.AccountCount.Cmp(other.AccountCount) != 0 {
+		return false
+	}
+	if !equalOptionalBalances(GoBalance.Gross, other.Gross) || !equalOptionalBalances(GoBalance.Prices, other.Prices) {
+		return false
+	}
+	if (GoBalance.Segment == nil) != (other.Segment == nil) || (GoBalance.Segment != nil && GoBalance.Segment.Cmp(other.Segment) != 0) {
+		return false
+	}
+	if (GoBalance.Blinding == nil) != (other.Blinding == nil) || (GoBalance.Blinding != nil && GoBalance.Blinding.Cmp(other.Blinding) != 0) {
+		return false
+	}
+	if len(GoBalance.Segments) != len(other.Segments) {
+		return false
+	}
+	for i := range GoBalance.Segments {
+		if !GoBalance.Segments[i].Equals(other.Segments[i]) {
+			return false
+		}
+	}
 	return GoBalance.Bitcoin.Cmp(&other.Bitcoin) == 0 && GoBalance.Ethereum.Cmp(&other.Ethereum) == 0
 }
+
+func equalOptionalBalances(a, b *GoBalance) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equals(*b)
+}
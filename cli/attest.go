@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+var attestCmd = &cobra.Command{
+	Use:   "attest",
+	Short: "Signs issuer attestations and auditor endorsements of an epoch's proofs",
+}
+
+var attestKeygenCmd = &cobra.Command{
+	Use:   "keygen [path/to/key]",
+	Short: "Generates an Ed25519 issuer or auditor key pair",
+	Long:  "Generates an Ed25519 issuer or auditor key pair, writing the hex encoded private key to [path].key and the public key to [path].pub. The path defaults to 'issuer'.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "issuer"
+		if len(args) == 1 {
+			path = args[0]
+		}
+		publicKey, privateKey, err := core.GenerateSigningKey()
+		if err != nil {
+			fmt.Println("Error generating key:", err)
+			os.Exit(1)
+		}
+		err = core.WriteSigningKeys(path, publicKey, privateKey)
+		if err != nil {
+			fmt.Println("Error writing key:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %s.key and %s.pub\n", path, path)
+	},
+}
+
+var attestSignCmd = &cobra.Command{
+	Use:   "sign [path/to/publicdir]",
+	Short: "Signs the top level proof, the manifest of all proofs and the published statement",
+	Long: "Signs the canonical serialization of the top level proof, a manifest of every proof and the published statement " +
+		"in the given directory (by default 'out/public/') with the issuer key, and writes the attestation to " +
+		"attestation.json in that directory. Verifiers check it with --issuer-key.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		proofsDir := "out/public"
+		if len(args) == 1 {
+			proofsDir = args[0]
+		}
+		keyFile, _ := cmd.Flags().GetString("key")
+		privateKey, err := core.ReadPrivateKey(keyFile)
+		if err != nil {
+			fmt.Println("Error reading issuer key:", err)
+			os.Exit(1)
+		}
+		attestation, err := core.SignAttestation(proofsDir, privateKey)
+		if err != nil {
+			fmt.Println("Error signing attestation:", err)
+			os.Exit(1)
+		}
+		attestationFile := core.AttestationFile(proofsDir)
+		err = core.WriteAttestationToFile(attestationFile, attestation)
+		if err != nil {
+			fmt.Println("Error writing attestation:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Attested %d proofs in %s\n", len(attestation.Manifest.Proofs), attestationFile)
+	},
+}
+
+func addAttestationFlags(cmd *cobra.Command) {
+	cmd.Flags().String("issuer-key", "", "Path to the issuer's public key; verification fails unless the proofs are attested with it")
+	cmd.Flags().String("attestation", "", "Path to the issuer's attestation (default attestation.json alongside the top level proof)")
+	cmd.Flags().String("auditor-keys", "", "Path to the auditors' public keys, one per line; verification fails unless enough of them endorsed the proofs")
+	cmd.Flags().Int("auditor-threshold", 0, "Number of auditors that must endorse the proofs (default all of --auditor-keys)")
+	cmd.Flags().String("endorsements", "", "Path to the combined auditor endorsements (default endorsements.json alongside the top level proof)")
+}
+
+// attestationOptions returns the options requiring an issuer attestation and auditor endorsements, if an issuer
+// key or auditor keys were given. Missing attestation or endorsement files are not an error here, so that
+// verification reports the proofs as unattested.
+func attestationOptions(cmd *cobra.Command, proofsDir string) ([]core.VerifyOption, error) {
+	options := make([]core.VerifyOption, 0)
+	if keyFile, _ := cmd.Flags().GetString("issuer-key"); keyFile != "" {
+		issuerKey, err := core.ReadPublicKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		attestationFile, _ := cmd.Flags().GetString("attestation")
+		if attestationFile == "" {
+			attestationFile = core.AttestationFile(proofsDir)
+		}
+		attestation, err := core.ReadAttestationFromFile(attestationFile)
+		if errors.Is(err, os.ErrNotExist) {
+			options = append(options, core.WithIssuerKey(issuerKey, nil))
+		} else if err != nil {
+			return nil, err
+		} else {
+			options = append(options, core.WithIssuerKey(issuerKey, &attestation))
+		}
+	}
+	if keysFile, _ := cmd.Flags().GetString("auditor-keys"); keysFile != "" {
+		auditorKeys, err := core.ReadAuditorKeys(keysFile)
+		if err != nil {
+			return nil, err
+		}
+		threshold, _ := cmd.Flags().GetInt("auditor-threshold")
+		if threshold <= 0 {
+			threshold = len(auditorKeys)
+		}
+		endorsementsFile, _ := cmd.Flags().GetString("endorsements")
+		if endorsementsFile == "" {
+			endorsementsFile = core.EndorsementsFile(proofsDir)
+		}
+		endorsements, err := core.ReadEndorsementsFromFile(endorsementsFile)
+		if errors.Is(err, os.ErrNotExist) {
+			options = append(options, core.WithAuditorEndorsements(auditorKeys, threshold, nil))
+		} else if err != nil {
+			return nil, err
+		} else {
+			options = append(options, core.WithAuditorEndorsements(auditorKeys, threshold, &endorsements))
+		}
+	}
+	return options, nil
+}
+
+var attestEndorseCmd = &cobra.Command{
+	Use:   "endorse [BatchCount]",
+	Short: "Fully verifies the proofs in 'out/public/' as an auditor and endorses the published statement",
+	Long: "Runs the full verification of 'verify' against the statement published in 'out/public/statement.json' (or --statement) " +
+		"and, only if every check passes, signs the statement's top level roots and totals with the auditor key. Only the " +
+		"public proofs are needed, not a user's account. " +
+		"The report is printed and the endorsement is written to --out.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := reportFormat(cmd)
+		batchCount, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Println("Error parsing batchCount:", err)
+			os.Exit(1)
+		}
+		keyFile, _ := cmd.Flags().GetString("key")
+		privateKey, err := core.ReadPrivateKey(keyFile)
+		if err != nil {
+			fmt.Println("Error reading auditor key:", err)
+			os.Exit(1)
+		}
+		statementFile, _ := cmd.Flags().GetString("statement")
+		statement, err := core.ReadStatementFromFile(statementFile)
+		if err != nil {
+			fmt.Println("Error reading published statement:", err)
+			os.Exit(1)
+		}
+		workers, _ := cmd.Flags().GetInt("workers")
+		endorsement, report := core.Endorse(batchCount, statement, privateKey, core.WithWorkers(workers))
+		renderReport(format, report)
+
+		outFile, _ := cmd.Flags().GetString("out")
+		err = core.WriteEndorsementToFile(outFile, endorsement)
+		if err != nil {
+			fmt.Println("Error writing endorsement:", err)
+			os.Exit(1)
+		}
+		if format == outputText {
+			fmt.Println("Wrote endorsement to", outFile)
+		}
+	},
+}
+
+var attestCombineCmd = &cobra.Command{
+	Use:   "combine [path/to/endorsement.json]...",
+	Short: "Combines auditors' endorsements of a statement into one file to publish with the proofs",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		endorsements := make([]core.Endorsement, len(args))
+		for i, file := range args {
+			var err error
+			endorsements[i], err = core.ReadEndorsementFromFile(file)
+			if err != nil {
+				fmt.Printf("Error reading %s: %s\n", file, err)
+				os.Exit(1)
+			}
+		}
+		combined, err := core.CombineEndorsements(endorsements)
+		if err != nil {
+			fmt.Println("Error combining endorsements:", err)
+			os.Exit(1)
+		}
+		outFile, _ := cmd.Flags().GetString("out")
+		err = core.WriteEndorsementsToFile(outFile, combined)
+		if err != nil {
+			fmt.Println("Error writing endorsements:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Combined endorsements by %d auditors into %s\n", len(combined.Endorsements), outFile)
+	},
+}
+
+func init() {
+	attestSignCmd.Flags().String("key", "issuer.key", "Path to the issuer's private key")
+	addOutputFlag(attestEndorseCmd)
+	attestEndorseCmd.Flags().String("key", "auditor.key", "Path to the auditor's private key")
+	attestEndorseCmd.Flags().String("statement", "out/public/statement.json", "Path to the statement published by the exchange")
+	attestEndorseCmd.Flags().String("out", "endorsement.json", "Path to write the endorsement to")
+	attestEndorseCmd.Flags().Int("workers", runtime.NumCPU(), "Number of proof files to verify concurrently")
+	attestCombineCmd.Flags().String("out", "out/public/endorsements.json", "Path to write the combined endorsements to")
+	attestCmd.AddCommand(attestKeygenCmd)
+	attestCmd.AddCommand(attestSignCmd)
+	attestCmd.AddCommand(attestEndorseCmd)
+	attestCmd.AddCommand(attestCombineCmd)
+	rootCmd.AddCommand(attestCmd)
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Recomputes the published proofs from the secret ledger and reports any mismatch",
+	Long: "Recomputes every account leaf, batch merkle root, MerkleRootWithAssetSumHash and asset sum from the secret data " +
+		"and compares them against the published bottom level proofs, then checks the mid and top level proofs aggregate " +
+		"the recomputed batches. Ledger accounts that are in no published proof are reported. The SNARKs are not verified; " +
+		"use verify for that. Padded or shuffled accounts are placed with the seed of the epoch of the published statement, " +
+		"kept in the secret directory.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := reportFormat(cmd)
+		secretDir, _ := cmd.Flags().GetString("secret-dir")
+		publicDir, _ := cmd.Flags().GetString("public-dir")
+		var epoch uint64
+		shuffle, _ := cmd.Flags().GetBool("shuffle")
+		padTo, _ := cmd.Flags().GetInt("pad-to")
+		if shuffle || padTo != 0 {
+			statement, err := core.ReadStatementFromDir(publicDir)
+			if err != nil {
+				fmt.Println("Error reading the published statement for the epoch's seed:", err)
+				os.Exit(1)
+			}
+			epoch = statement.Epoch
+		}
+		renderReport(format, core.Audit(secretDir, publicDir, placementFlags(cmd, secretDir, epoch, false)))
+	},
+}
+
+func init() {
+	auditCmd.Flags().String("secret-dir", defaultSecretDir, "directory holding the secret data batches")
+	auditCmd.Flags().String("public-dir", "out/public", "directory holding the published proofs")
+	addPlacementFlags(auditCmd)
+	addOutputFlag(auditCmd)
+	rootCmd.AddCommand(auditCmd)
+}
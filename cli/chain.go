@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+var chainCmd = &cobra.Command{
+	Use:   "chain",
+	Short: "Checks the chain of published epochs",
+}
+
+var chainVerifyCmd = &cobra.Command{
+	Use:   "verify [path/to/epochsdir]",
+	Short: "Verifies that a directory of epochs forms an unbroken hash chain",
+	Long: "Walks a directory holding one directory of public proofs per epoch. Each epoch's top level proof must be valid " +
+		"and match its statement, and each statement must follow the previous epoch's statement: its epoch number is one " +
+		"higher, its timestamp is later and it records the hash of the previous statement.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := reportFormat(cmd)
+		renderReport(format, core.VerifyChain(args[0]))
+	},
+}
+
+func init() {
+	addOutputFlag(chainVerifyCmd)
+	chainCmd.AddCommand(chainVerifyCmd)
+	rootCmd.AddCommand(chainCmd)
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert [path/to/inputdir] [path/to/outputdir]",
+	Short: "Converts proof files between the JSON and compact binary encodings",
+	Long: "Converts every bottom, mid and top level proof file in the input directory to the format given by --to and writes " +
+		"them to the output directory. Binary proofs reference their verifying key by hash, and each distinct key is written " +
+		"once to 'verifying_keys.bin' in the output directory. Input files may be in either format, and the statement, leaf index and other " +
+		"published files are copied unchanged.",
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, err := cmd.Flags().GetString("to")
+		if err != nil {
+			fmt.Println("Error parsing format:", err)
+			return
+		}
+		err = os.MkdirAll(args[1], 0o755)
+		if err != nil {
+			fmt.Println("Error creating output directory:", err)
+			os.Exit(1)
+		}
+		written, err := core.ConvertProofFiles(args[0], args[1], core.ProofFormat(format))
+		if err != nil {
+			fmt.Println("Error converting proofs:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %d files to %s\n", len(written), args[1])
+	},
+}
+
+func init() {
+	convertCmd.Flags().String("to", string(core.ProofFormatBinary), "Format to convert to: 'binary' or 'json'")
+	rootCmd.AddCommand(convertCmd)
+}
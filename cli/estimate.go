@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+var estimateCmd = &cobra.Command{
+	Use:   "estimate [EpochAccounts]",
+	Short: "Estimates the constraints, key sizes, memory and proving time for the chosen parameters",
+	Long: "Compiles a circuit for --accounts accounts of --assets assets per batch in a tree of --depth levels and reports " +
+		"its constraints, wires and key sizes. It then runs the setup and one benchmark proof on this machine, and projects " +
+		"how long proving an epoch of EpochAccounts accounts would take, each mid level proof holding as many bottom level " +
+		"proofs as the tree has leaves. Mid and top level proofs are priced by a benchmark of a circuit of that many accounts, " +
+		"and setups are left out with --keys-kept.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := reportFormat(cmd)
+		epochAccounts, err := strconv.Atoi(args[0])
+		if err != nil || epochAccounts < 1 {
+			fmt.Println("Error parsing EpochAccounts:", args[0])
+			os.Exit(1)
+		}
+		accounts, _ := cmd.Flags().GetInt("accounts")
+		depth, _ := cmd.Flags().GetInt("depth")
+		assets, _ := cmd.Flags().GetInt("assets")
+		keysKept, _ := cmd.Flags().GetBool("keys-kept")
+		if depth < 1 || accounts < 1 || accounts > circuit.PowOfTwo(depth) {
+			fmt.Printf("%d accounts per batch do not fit in a tree of depth %d\n", accounts, depth)
+			os.Exit(1)
+		}
+		if assets < 1 {
+			fmt.Println("--assets must be at least 1")
+			os.Exit(1)
+		}
+		if epochAccounts > core.MaxEpochAccounts(accounts, depth) {
+			fmt.Printf("an epoch of %d accounts does not fit in three levels of trees of depth %d\n", epochAccounts, depth)
+			os.Exit(1)
+		}
+		if err = writeEstimate(os.Stdout, format, core.EstimateProving(accounts, depth, assets, epochAccounts, keysKept)); err != nil {
+			fmt.Println("Error writing estimate:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func writeEstimate(w io.Writer, format string, estimate core.Estimate) error {
+	if format == outputJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(estimate)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"accounts per batch", fmt.Sprint(estimate.AccountsPerBatch)},
+		{"tree depth", fmt.Sprint(estimate.TreeDepth)},
+		{"assets", fmt.Sprint(estimate.Assets)},
+		{"constraints", fmt.Sprint(estimate.Constraints)},
+		{"wires (public, secret, internal)", fmt.Sprintf("%d, %d, %d", estimate.PublicWires, estimate.SecretWires, estimate.InternalWires)},
+		{"proving key", formatBytes(uint64(estimate.ProvingKeyBytes))},
+		{"verifying key", formatBytes(uint64(estimate.VerifyingKeyBytes))},
+		{"peak heap", formatBytes(estimate.PeakHeapBytes)},
+		{"compile", estimate.CompileDuration.Round(time.Millisecond).String()},
+		{"setup", estimate.SetupDuration.Round(time.Millisecond).String()},
+		{"proof", estimate.ProofDuration.Round(time.Millisecond).String()},
+		{"mid and top level setup", estimate.UpperLevelSetupDuration.Round(time.Millisecond).String()},
+		{"mid and top level proof", estimate.UpperLevelProofDuration.Round(time.Millisecond).String()},
+		{"epoch accounts", fmt.Sprint(estimate.EpochAccounts)},
+		{"proofs (bottom, mid, top)", fmt.Sprintf("%d, %d, 1", estimate.BottomLevelProofs, estimate.MidLevelProofs)},
+		{"projected proving time", estimate.ProjectedDuration.Round(time.Second).String()},
+	}
+	for _, row := range rows {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1]); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func init() {
+	estimateCmd.Flags().Int("accounts", circuit.PowOfTwo(circuit.TreeDepth), "Accounts per batch")
+	estimateCmd.Flags().Int("depth", circuit.TreeDepth, "Depth of each proof's merkle tree")
+	estimateCmd.Flags().Int("assets", circuit.AssetCount, "Assets each account holds a balance of")
+	estimateCmd.Flags().Bool("keys-kept", false, "Project proving with keys kept from an earlier epoch in prove --keys-dir, without any setup")
+	addOutputFlag(estimateCmd)
+	rootCmd.AddCommand(estimateCmd)
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [path/to/inputdir] [path/to/outputdir]",
+	Short: "Rewrites proof, secret, statement and account files at the current schema version",
+	Long: "Rewrites every proof, secret batch, published statement and user account file in the input directory at the current " +
+		"schema version into the output directory. Files from any earlier version are read with that version's reader, and proofs " +
+		"keep the circuit id they were made with so they are still verified against that circuit. Other files are copied unchanged.",
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := os.MkdirAll(args[1], 0o755)
+		if err != nil {
+			fmt.Println("Error creating output directory:", err)
+			os.Exit(1)
+		}
+		written, err := core.MigrateFiles(args[0], args[1])
+		if err != nil {
+			fmt.Println("Error migrating files:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %d files to %s at schema version %d\n", len(written), args[1], core.SchemaVersion)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+}
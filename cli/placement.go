@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+// defaultSecretDir is where prove reads the secret data from and keeps each epoch's seed.
+const defaultSecretDir = "out/secret"
+
+func addPlacementFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("shuffle", false, "Place the accounts at positions across all batches given by the epoch's secret seed, so that batch and leaf indices do not reveal the ledger's order")
+	cmd.Flags().Int("pad-to", 0, "Pad each batch to this many accounts (at most 1024) with zero balance dummy accounts derived from the epoch's secret seed, so that the number of accounts is not revealed")
+}
+
+// placementFlags reads how the accounts of epoch are placed, with the epoch's seed kept in secretDir. Unless create
+// is set, the seed must already exist; otherwise a new one is written if there is none.
+func placementFlags(cmd *cobra.Command, secretDir string, epoch uint64, create bool) core.Placement {
+	var placement core.Placement
+	placement.Shuffle, _ = cmd.Flags().GetBool("shuffle")
+	placement.PadTo, _ = cmd.Flags().GetInt("pad-to")
+	if !placement.Shuffle && placement.PadTo == 0 {
+		return placement
+	}
+	seedFile := core.EpochSeedFile(secretDir, epoch)
+	seed, err := core.ReadEpochSeedFromFile(seedFile)
+	if create && os.IsNotExist(err) {
+		seed = core.GenerateEpochSeed()
+		err = core.WriteEpochSeedToFile(seedFile, seed)
+	}
+	if err != nil {
+		fmt.Printf("Error with the seed of epoch %d: %v\n", epoch, err)
+		os.Exit(1)
+	}
+	placement.Seed = seed
+	return placement
+}
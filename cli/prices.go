@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+func addPriceFlags(cmd *cobra.Command) {
+	cmd.Flags().String("bitcoin-price", "", "Price of a satoshi in a quote unit of your choice; with --ethereum-price, the ledger holds margin accounts whose balances may be negative but whose net value at the prices may not")
+	cmd.Flags().String("ethereum-price", "", "Price of a wei in the quote unit of --bitcoin-price")
+	cmd.MarkFlagsRequiredTogether("bitcoin-price", "ethereum-price")
+}
+
+// priceOptions returns the option proving a cross-margin ledger at the given prices, if they were given.
+func priceOptions(cmd *cobra.Command) []core.ProveOption {
+	if !cmd.Flags().Changed("bitcoin-price") {
+		return nil
+	}
+	bitcoin, err := decodeBigIntFlag(cmd, "bitcoin-price")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	ethereum, err := decodeBigIntFlag(cmd, "ethereum-price")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return []core.ProveOption{core.WithCrossMargin(circuit.GoBalance{Bitcoin: *bitcoin, Ethereum: *ethereum})}
+}
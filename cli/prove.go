@@ -2,8 +2,10 @@ package cli
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 
+	"bitgo.com/proof_of_reserves/circuit"
 	"bitgo.com/proof_of_reserves/core"
 	"github.com/spf13/cobra"
 )
@@ -11,18 +13,116 @@ import (
 var proveCmd = &cobra.Command{
 	Use:   "prove [BatchCount]",
 	Short: "Generates proofs using the secret data in 'out/secret/'",
-	Long:  "Generates proofs using the secret data in 'out/secret/'. This function takes 1 argument: the number of batches.",
-	Args:  cobra.ExactArgs(1),
+	Long: "Generates proofs using the secret data in 'out/secret/'. This function takes 1 argument: the number of batches. " +
+		"The statement to publish for the epoch is written to 'out/public/statement.json'. Unless this is the first epoch, " +
+		"pass the previous epoch's statement with --previous-statement to link the new statement to it. With --dry-run, " +
+		"each circuit is only checked to be solved by its batch's witness, without a setup or proving, and nothing is written.",
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		batchCount, err := strconv.Atoi(args[0])
 		if err != nil {
 			fmt.Println("Error parsing batchCount:", err)
 			return
 		}
-		core.Prove(batchCount)
+		epoch, err := cmd.Flags().GetUint64("epoch")
+		if err != nil {
+			fmt.Println("Error parsing epoch:", err)
+			return
+		}
+		var previous *core.PublishedStatement
+		if previousFile, _ := cmd.Flags().GetString("previous-statement"); previousFile != "" {
+			statement, err := core.ReadStatementFromFile(previousFile)
+			if err != nil {
+				fmt.Println("Error reading previous statement:", err)
+				os.Exit(1)
+			}
+			previous = &statement
+			if !cmd.Flags().Changed("epoch") {
+				epoch = previous.Epoch + 1
+			}
+		}
+		snapshotTime, err := parseTimeFlag(cmd, "snapshot-time")
+		if err != nil {
+			fmt.Println("Error parsing snapshot time:", err)
+			os.Exit(1)
+		}
+		issuerId, _ := cmd.Flags().GetString("issuer-id")
+		if issuerId == "" {
+			fmt.Println("--issuer-id must name the issuer")
+			os.Exit(1)
+		}
+		context := core.NewEpochContext(epoch, snapshotTime, issuerId)
+		options := make([]core.ProveOption, 0)
+		if countAccounts, _ := cmd.Flags().GetBool("count-accounts"); countAccounts {
+			options = append(options, core.WithAccountCount())
+		}
+		if cmd.Flags().Changed("bitcoin-reserves") {
+			bitcoin, err := decodeBigIntFlag(cmd, "bitcoin-reserves")
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			ethereum, err := decodeBigIntFlag(cmd, "ethereum-reserves")
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			options = append(options, core.WithHiddenTotal(circuit.GoBalance{Bitcoin: *bitcoin, Ethereum: *ethereum}))
+		}
+		options = append(options, priceOptions(cmd)...)
+		options = append(options, segmentOptions(cmd)...)
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			// a dry run writes nothing, so the seed of a padded or shuffled epoch must already exist
+			options = append(options, core.WithPlacement(placementFlags(cmd, defaultSecretDir, epoch, false)))
+			renderReport(reportFormat(cmd), core.DryRun(batchCount, context, options...))
+			return
+		}
+		keysDir, _ := cmd.Flags().GetString("keys-dir")
+		if keysDir != "" {
+			options = append(options, core.WithPersistentKeys(keysDir))
+		}
+		var reuse core.ReuseReport
+		reuseDir, _ := cmd.Flags().GetString("reuse")
+		if reuseDir != "" {
+			if keysDir == "" {
+				fmt.Println("--reuse requires --keys-dir, so that reused and new proofs share their keys")
+				os.Exit(1)
+			}
+			options = append(options, core.WithReusedProofs(reuseDir, &reuse))
+		}
+		options = append(options, core.WithPlacement(placementFlags(cmd, defaultSecretDir, epoch, true)))
+		core.Prove(batchCount, context, previous, options...)
+		if reuseDir != "" {
+			for _, reproven := range reuse.Reproven {
+				fmt.Printf("proved %s again: %s\n", reproven.File, reproven.Reason)
+			}
+			fmt.Printf("Reused %d proofs and proved %d\n", len(reuse.Reused), len(reuse.Reproven))
+		}
 	},
 }
 
 func init() {
+	proveCmd.Flags().Uint64("epoch", 0, "Epoch number, proven in the top level proof and recorded in the published statement (default one after --previous-statement, or 0)")
+	proveCmd.Flags().String("previous-statement", "", "Path to the statement published for the previous epoch")
+	proveCmd.Flags().String("snapshot-time", "", "When the balances in 'out/secret/' were snapshotted, as RFC 3339 (default now); proven in the top level proof")
+	proveCmd.Flags().String("issuer-id", "", "Identifier of the issuer; its hash is proven in the top level proof")
+	proveCmd.MarkFlagRequired("issuer-id")
+	proveCmd.Flags().String("keys-dir", "", "Directory to keep the proving and verifying keys in and reuse them from, rather than running a new setup each time")
+	proveCmd.Flags().String("reuse", "", "Public directory of an earlier epoch whose unchanged proofs are reused (requires --keys-dir); padded or shuffled batches are always proven again, as their placement seed is new each epoch")
+	addPlacementFlags(proveCmd)
+	proveCmd.Flags().Bool("count-accounts", false, "Prove and publish the number of ledger accounts, which padding does not hide, with the asset sum")
+	proveCmd.Flags().String("bitcoin-reserves", "", "Bitcoin reserves in base units; with --ethereum-reserves, the totals are published only as commitments, proven at most the reserves")
+	proveCmd.Flags().String("ethereum-reserves", "", "Ethereum reserves in base units; see --bitcoin-reserves")
+	proveCmd.MarkFlagsRequiredTogether("bitcoin-reserves", "ethereum-reserves")
+	proveCmd.MarkFlagsMutuallyExclusive("bitcoin-reserves", "count-accounts")
+	addPriceFlags(proveCmd)
+	proveCmd.MarkFlagsMutuallyExclusive("bitcoin-price", "count-accounts")
+	proveCmd.MarkFlagsMutuallyExclusive("bitcoin-price", "bitcoin-reserves")
+	addSegmentFlag(proveCmd)
+	proveCmd.MarkFlagsMutuallyExclusive("segments", "count-accounts")
+	proveCmd.MarkFlagsMutuallyExclusive("segments", "bitcoin-reserves")
+	proveCmd.MarkFlagsMutuallyExclusive("segments", "bitcoin-price")
+	proveCmd.Flags().Bool("dry-run", false, "Only check that every batch, and the mid and top levels, satisfy their circuits, and report the first failing constraint of each")
+	addOutputFlag(proveCmd)
 	rootCmd.AddCommand(proveCmd)
 }
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/consensys/gnark/logger"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputText = "text"
+	outputJson = "json"
+)
+
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", outputText, "Report format: 'text' or 'json'")
+}
+
+func writeReport(w io.Writer, format string, report core.VerificationReport) error {
+	switch format {
+	case outputJson:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case outputText:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, check := range report.Checks {
+			status := "PASS"
+			if !check.Passed {
+				status = "FAIL"
+			}
+			_, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", status, check.Kind, check.File, check.Duration.Round(time.Microsecond), check.Error)
+			if err != nil {
+				return err
+			}
+		}
+		err := tw.Flush()
+		if err != nil {
+			return err
+		}
+		if report.Passed() {
+			_, err = fmt.Fprintf(w, "Verification succeeded! %d checks passed in %s\n", len(report.Checks), report.Duration.Round(time.Millisecond))
+		} else {
+			_, err = fmt.Fprintf(w, "Verification failed! %d of %d checks failed\n", len(report.Failures()), len(report.Checks))
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// reportFormat reads the command's --output flag. For JSON output gnark's logging is moved to
+// stderr so that stdout only carries the report.
+func reportFormat(cmd *cobra.Command) string {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		fmt.Println("Error reading output flag:", err)
+		os.Exit(1)
+	}
+	if format != outputText && format != outputJson {
+		fmt.Printf("Unknown output format %q, expected '%s' or '%s'\n", format, outputText, outputJson)
+		os.Exit(1)
+	}
+	if format == outputJson {
+		logger.SetOutput(os.Stderr)
+	}
+	return format
+}
+
+// renderReport prints the report in the given format and exits with a non-zero code if any check failed.
+func renderReport(format string, report core.VerificationReport) {
+	err := writeReport(os.Stdout, format, report)
+	if err != nil {
+		fmt.Println("Error writing report:", err)
+		os.Exit(1)
+	}
+	if !report.Passed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"bitgo.com/proof_of_reserves/core"
+	"bitgo.com/proof_of_reserves/reserves"
+	"github.com/spf13/cobra"
+)
+
+var reservesCmd = &cobra.Command{
+	Use:   "reserves ([path/to/addresses.json] | --challenge)",
+	Short: "Computes the reserves held at the custody addresses and writes a signed reserves statement",
+	Long: "Reads a JSON list of custody addresses, each an Asset ('Bitcoin' or 'Ethereum') and an Address, and totals what " +
+		"they hold. Each address must have a Signature of the epoch's challenge, printed by --challenge: " +
+		"a base64 legacy signmessage signature for Bitcoin addresses and a hex EIP-191 signature for Ethereum addresses. " +
+		"The holdings are read from a chain snapshot: a --snapshot file of Bitcoin UTXOs and Ethereum balances, or nodes answering " +
+		"listunspent (--bitcoin-rpc) and eth_getBalance (--ethereum-rpc) over JSON-RPC; a snapshot file lists each output " +
+		"once. The totals, in satoshis and wei, are written as a reserves statement recording the block of each chain " +
+		"they were read at, linked to the hash of the epoch's published --statement and signed with the issuer --key. Pass the reserves statement to 'solvency' to compare it with the liabilities.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		statementFile, _ := cmd.Flags().GetString("statement")
+		liabilities, err := core.ReadStatementFromFile(statementFile)
+		if err != nil {
+			fmt.Println("Error reading published statement:", err)
+			os.Exit(1)
+		}
+		if challenge, _ := cmd.Flags().GetBool("challenge"); challenge {
+			fmt.Println(reserves.Challenge(liabilities))
+			return
+		}
+		if len(args) != 1 {
+			fmt.Println("The path to the custody addresses is required")
+			os.Exit(1)
+		}
+		addresses, err := reserves.ReadCustodyAddresses(args[0])
+		if err != nil {
+			fmt.Println("Error reading custody addresses:", err)
+			os.Exit(1)
+		}
+		var snapshot reserves.Snapshot
+		if snapshotFile, _ := cmd.Flags().GetString("snapshot"); snapshotFile != "" {
+			if snapshot, err = reserves.ReadFileSnapshot(snapshotFile); err != nil {
+				fmt.Println("Error reading snapshot:", err)
+				os.Exit(1)
+			}
+		} else {
+			bitcoinUrl, _ := cmd.Flags().GetString("bitcoin-rpc")
+			ethereumUrl, _ := cmd.Flags().GetString("ethereum-rpc")
+			ethereumBlock, _ := cmd.Flags().GetString("ethereum-block")
+			snapshot = reserves.NewRpcSnapshot(bitcoinUrl, ethereumUrl, ethereumBlock)
+		}
+		keyFile, _ := cmd.Flags().GetString("key")
+		privateKey, err := core.ReadPrivateKey(keyFile)
+		if err != nil {
+			fmt.Println("Error reading issuer key:", err)
+			os.Exit(1)
+		}
+		statement, err := reserves.NewStatement(addresses, snapshot, liabilities, privateKey)
+		if err != nil {
+			fmt.Println("Error computing reserves:", err)
+			os.Exit(1)
+		}
+		outFile, _ := cmd.Flags().GetString("out")
+		if err = core.WriteReservesStatementToFile(outFile, statement); err != nil {
+			fmt.Println("Error writing reserves statement:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Reserves of epoch %d: Bitcoin %s, Ethereum %s\n", statement.Epoch, statement.Reserves.Bitcoin.String(), statement.Reserves.Ethereum.String())
+		for _, block := range statement.Blocks {
+			fmt.Printf("%s read at block %d (%s)\n", block.Asset, block.Height, block.Hash)
+		}
+		fmt.Printf("Wrote %s\n", outFile)
+	},
+}
+
+func init() {
+	reservesCmd.Flags().String("snapshot", "", "Path to a snapshot file of Bitcoin UTXOs and Ethereum balances")
+	reservesCmd.Flags().String("bitcoin-rpc", "", "URL of a Bitcoin node's JSON-RPC interface, credentials included, to list unspent outputs from")
+	reservesCmd.Flags().String("ethereum-rpc", "", "URL of an Ethereum node's JSON-RPC interface to read balances from")
+	reservesCmd.Flags().String("ethereum-block", "latest", "Block number, hex encoded, to read Ethereum balances at")
+	reservesCmd.MarkFlagsMutuallyExclusive("snapshot", "bitcoin-rpc")
+	reservesCmd.MarkFlagsMutuallyExclusive("snapshot", "ethereum-rpc")
+	reservesCmd.MarkFlagsMutuallyExclusive("snapshot", "ethereum-block")
+	reservesCmd.Flags().String("statement", "out/public/statement.json", "Path to the published statement of the epoch the reserves are linked to")
+	reservesCmd.Flags().String("key", "issuer.key", "Path to the issuer's private key")
+	reservesCmd.Flags().Bool("challenge", false, "Only print the challenge of the --statement epoch that each custody address must sign")
+	reservesCmd.Flags().String("out", "out/public/reserves.json", "Path to write the reserves statement to")
+	rootCmd.AddCommand(reservesCmd)
+}
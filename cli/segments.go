@@ -0,0 +1,18 @@
+package cli
+
+import (
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+func addSegmentFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("segments", false, "The ledger's accounts are tagged with their Segment (0 retail, 1 institutional, 2 house), whose subtotals are proven and published with the asset sum")
+}
+
+// segmentOptions returns the option proving the subtotal of each segment, if --segments was given.
+func segmentOptions(cmd *cobra.Command) []core.ProveOption {
+	if segments, _ := cmd.Flags().GetBool("segments"); segments {
+		return []core.ProveOption{core.WithSegments()}
+	}
+	return nil
+}
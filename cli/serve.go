@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"bitgo.com/proof_of_reserves/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [path/to/publicdir]",
+	Short: "Serves an epoch's public proofs and verifies users' proof paths over HTTP",
+	Long: "Serves the public proofs in the given directory (by default 'out/public/') over HTTP, reading only local files. Endpoints:\n" +
+		"  GET  /statement             the published top level statement\n" +
+		"  GET  /inclusion/{leafHash}  the bottom, mid and top level proofs on the path of a hex encoded account leaf hash\n" +
+		"  POST /verify                verifies the proof path of the posted account JSON and returns the verification report\n" +
+		"The directory must hold the published statement.json. Requests are rate limited per client IP and time out.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		proofsDir := "out/public"
+		if len(args) == 1 {
+			proofsDir = args[0]
+		}
+		addr, _ := cmd.Flags().GetString("addr")
+		rate, _ := cmd.Flags().GetFloat64("rate")
+		burst, _ := cmd.Flags().GetInt("burst")
+		s, err := server.New(proofsDir, rate, burst)
+		if err != nil {
+			fmt.Println("Error loading proofs:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Serving %s on %s\n", proofsDir, addr)
+		err = s.HTTPServer(addr).ListenAndServe()
+		if err != nil {
+			fmt.Println("Error serving:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("addr", "localhost:8080", "Address to listen on")
+	serveCmd.Flags().Float64("rate", 5, "Requests per second allowed per client IP")
+	serveCmd.Flags().Int("burst", 20, "Requests a client IP may make at once before being rate limited")
+	rootCmd.AddCommand(serveCmd)
+}
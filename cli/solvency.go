@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+
+	"bitgo.com/proof_of_reserves/core"
+	"bitgo.com/proof_of_reserves/reserves"
+	"github.com/spf13/cobra"
+)
+
+var solvencyCmd = &cobra.Command{
+	Use:   "solvency [path/to/reserves.json]",
+	Short: "Certifies that the reserves cover the liabilities proven by the top level proof",
+	Long: "Verifies every proof in the given --proofs-dir (by default 'out/public/'), pinned to the statement published there " +
+		"and to the epoch context of the given reserves statement, and compares the liabilities the top level proof " +
+		"proves with the per asset reserves of the statement. If the proof publishes its " +
+		"totals, they are compared openly; if it hides them, its SNARK proves they are at most the reserves it publishes, " +
+		"which must be at most the stated reserves. The reserves statement must be linked to the statement published in the " +
+		"proofs directory and signed: with --issuer-key by the issuer, and otherwise by the key it names, which only shows " +
+		"it was not altered since. Each custody address the statement lists must have signed the challenge of the epoch of " +
+		"the published statement. The solvency certificate, with each asset's liabilities, reserves and " +
+		"coverage ratio, is written to solvency_certificate.json in the proofs directory unless --certificate is given.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := reportFormat(cmd)
+		statement, err := core.ReadReservesStatementFromFile(args[0])
+		if err != nil {
+			fmt.Println("Error reading reserves statement:", err)
+			os.Exit(1)
+		}
+		proofsDir, _ := cmd.Flags().GetString("proofs-dir")
+		var issuerKey ed25519.PublicKey
+		if keyFile, _ := cmd.Flags().GetString("issuer-key"); keyFile != "" {
+			if issuerKey, err = core.ReadPublicKey(keyFile); err != nil {
+				fmt.Println("Error reading issuer key:", err)
+				os.Exit(1)
+			}
+		} else if format == outputText {
+			fmt.Printf("No --issuer-key given: the reserves statement is only checked against the key it names, %x\n", statement.IssuerPublicKey)
+		}
+		certificate, report := core.CertifySolvency(proofsDir, statement, issuerKey, reserves.VerifyStatementOwnership)
+		if report.Passed() {
+			certificateFile, _ := cmd.Flags().GetString("certificate")
+			if certificateFile == "" {
+				certificateFile = core.SolvencyCertificateFile(proofsDir)
+			}
+			if err = core.WriteSolvencyCertificateToFile(certificateFile, certificate); err != nil {
+				fmt.Println("Error writing solvency certificate:", err)
+				os.Exit(1)
+			}
+			if format == outputText {
+				for _, asset := range certificate.Assets {
+					liabilities, coverage := "hidden", asset.CoverageRatio
+					if asset.Liabilities != nil {
+						liabilities = asset.Liabilities.String()
+					} else {
+						coverage = "at least " + coverage
+					}
+					if asset.GrossLiabilities != nil {
+						liabilities = fmt.Sprintf("%s net (%s gross, coverage %s)", liabilities, asset.GrossLiabilities.String(), asset.GrossCoverageRatio)
+					}
+					fmt.Printf("%s: liabilities %s, reserves %s, coverage %s\n", asset.Asset, liabilities, asset.Reserves.String(), coverage)
+				}
+				fmt.Printf("Wrote %s\n", certificateFile)
+			}
+		}
+		renderReport(format, report)
+	},
+}
+
+func init() {
+	solvencyCmd.Flags().String("proofs-dir", "out/public", "Directory of the public proofs holding the top level proof")
+	solvencyCmd.Flags().String("issuer-key", "", "Path to the issuer's public key; the reserves statement must be signed with it")
+	solvencyCmd.Flags().String("certificate", "", "Path to write the solvency certificate to (default solvency_certificate.json in --proofs-dir)")
+	addOutputFlag(solvencyCmd)
+	rootCmd.AddCommand(solvencyCmd)
+}
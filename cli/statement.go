@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+var statementFieldFlags = []string{"epoch", "snapshot-timestamp", "issuer-id", "merkle-root", "merkle-root-with-asset-sum-hash", "bitcoin-total", "ethereum-total"}
+
+func addStatementFlags(cmd *cobra.Command) {
+	cmd.Flags().String("statement", "", "Path to the statement published by the exchange; verification fails if the proofs differ from it")
+	cmd.Flags().Uint64("epoch", 0, "Published epoch (instead of --statement)")
+	cmd.Flags().Uint64("snapshot-timestamp", 0, "Published snapshot time, in seconds since the Unix epoch, or 0 for epochs proven before it was published (instead of --statement)")
+	cmd.Flags().String("issuer-id", "", "Identifier of the issuer of the published statement, or empty for epochs proven before it was published (instead of --statement)")
+	cmd.Flags().String("merkle-root", "", "Published top level merkle root, hex encoded (instead of --statement)")
+	cmd.Flags().String("merkle-root-with-asset-sum-hash", "", "Published top level merkle root with asset sum hash, hex encoded (instead of --statement)")
+	cmd.Flags().String("bitcoin-total", "", "Published total Bitcoin liabilities in base units (instead of --statement)")
+	cmd.Flags().String("ethereum-total", "", "Published total Ethereum liabilities in base units (instead of --statement)")
+	cmd.Flags().String("account-count", "", "Published number of ledger accounts, for epochs proven with their account count (instead of --statement)")
+	cmd.MarkFlagsMutuallyExclusive("statement", "account-count")
+	for _, flag := range statementFieldFlags {
+		cmd.MarkFlagsMutuallyExclusive("statement", flag)
+	}
+	cmd.MarkFlagsRequiredTogether(statementFieldFlags...)
+}
+
+func decodeHexFlag(cmd *cobra.Command, name string) ([]byte, error) {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %w", name, err)
+	}
+	return decoded, nil
+}
+
+func decodeBigIntFlag(cmd *cobra.Command, name string) (*big.Int, error) {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil {
+		return nil, err
+	}
+	decoded, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return nil, fmt.Errorf("--%s: %q is not a base 10 integer", name, value)
+	}
+	return decoded, nil
+}
+
+// statementOptions returns the option pinning verification to a published statement, if one was given.
+func statementOptions(cmd *cobra.Command) ([]core.VerifyOption, error) {
+	if path, _ := cmd.Flags().GetString("statement"); path != "" {
+		statement, err := core.ReadStatementFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []core.VerifyOption{core.WithPublishedStatement(statement)}, nil
+	}
+	statement, err := flagStatement(cmd)
+	if err != nil || statement == nil {
+		return nil, err
+	}
+	return []core.VerifyOption{core.WithPublishedStatement(*statement)}, nil
+}
+
+// flagStatement returns the published statement given as flags, or nil if none was given.
+func flagStatement(cmd *cobra.Command) (*core.PublishedStatement, error) {
+	if !cmd.Flags().Changed("merkle-root") {
+		return nil, nil
+	}
+
+	var statement core.PublishedStatement
+	var err error
+	statement.Epoch, err = cmd.Flags().GetUint64("epoch")
+	if err != nil {
+		return nil, err
+	}
+	statement.SnapshotTimestamp, err = cmd.Flags().GetUint64("snapshot-timestamp")
+	if err != nil {
+		return nil, err
+	}
+	issuerId, err := cmd.Flags().GetString("issuer-id")
+	if err != nil {
+		return nil, err
+	}
+	if issuerId != "" {
+		statement.IssuerIdHash = circuit.GoComputeIssuerIdHash(issuerId)
+	}
+	statement.MerkleRoot, err = decodeHexFlag(cmd, "merkle-root")
+	if err != nil {
+		return nil, err
+	}
+	statement.MerkleRootWithAssetSumHash, err = decodeHexFlag(cmd, "merkle-root-with-asset-sum-hash")
+	if err != nil {
+		return nil, err
+	}
+	bitcoin, err := decodeBigIntFlag(cmd, "bitcoin-total")
+	if err != nil {
+		return nil, err
+	}
+	ethereum, err := decodeBigIntFlag(cmd, "ethereum-total")
+	if err != nil {
+		return nil, err
+	}
+	statement.AssetSum = &circuit.GoBalance{Bitcoin: *bitcoin, Ethereum: *ethereum}
+	if cmd.Flags().Changed("account-count") {
+		if statement.AssetSum.AccountCount, err = decodeBigIntFlag(cmd, "account-count"); err != nil {
+			return nil, err
+		}
+	}
+	return &statement, nil
+}
+
+// parseTimeFlag reads an RFC 3339 time flag, defaulting to now when it is not set.
+func parseTimeFlag(cmd *cobra.Command, name string) (time.Time, error) {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil || value == "" {
+		return time.Now(), err
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return parsed, fmt.Errorf("--%s: %w", name, err)
+	}
+	return parsed, nil
+}
+
+var expectedEpochFlags = []string{"expected-epoch", "expected-snapshot-time", "expected-issuer-id"}
+
+func addExpectedEpochFlags(cmd *cobra.Command) {
+	cmd.Flags().Uint64("expected-epoch", 0, "Epoch the proofs must have been made for")
+	cmd.Flags().String("expected-snapshot-time", "", "Snapshot time, as RFC 3339, the proofs must have been made for")
+	cmd.Flags().String("expected-issuer-id", "", "Identifier of the issuer the proofs must have been made by")
+	cmd.MarkFlagsRequiredTogether(expectedEpochFlags...)
+}
+
+// expectedEpochContext returns the epoch context the proofs must have been made for, or nil if none was given.
+func expectedEpochContext(cmd *cobra.Command) (*core.EpochContext, error) {
+	if !cmd.Flags().Changed("expected-epoch") {
+		return nil, nil
+	}
+	epoch, err := cmd.Flags().GetUint64("expected-epoch")
+	if err != nil {
+		return nil, err
+	}
+	snapshotTime, err := parseTimeFlag(cmd, "expected-snapshot-time")
+	if err != nil {
+		return nil, err
+	}
+	issuerId, err := cmd.Flags().GetString("expected-issuer-id")
+	if err != nil {
+		return nil, err
+	}
+	context := core.NewEpochContext(epoch, snapshotTime, issuerId)
+	return &context, nil
+}
+
+// expectedEpochOptions returns the option requiring the proofs be made for an epoch context, if one was given.
+func expectedEpochOptions(cmd *cobra.Command) ([]core.VerifyOption, error) {
+	context, err := expectedEpochContext(cmd)
+	if err != nil || context == nil {
+		return nil, err
+	}
+	return []core.VerifyOption{core.WithExpectedEpoch(*context)}, nil
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/consensys/gnark/test"
+	"github.com/spf13/cobra"
+)
+
+// statementCommand is a command with the statement flags, parsed from args.
+func statementCommand(t *testing.T, args ...string) *cobra.Command {
+	cmd := &cobra.Command{Use: "test"}
+	addStatementFlags(cmd)
+	addExpectedEpochFlags(cmd)
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestStatementFromFlags(t *testing.T) {
+	assert := test.NewAssert(t)
+	snapshotTime := time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)
+	context := core.NewEpochContext(5, snapshotTime, "BitGo")
+	totals := []string{"--merkle-root", "0x0102", "--merkle-root-with-asset-sum-hash", "0304", "--bitcoin-total", "10", "--ethereum-total", "20"}
+
+	cmd := statementCommand(t, append([]string{"--epoch", "5", "--snapshot-timestamp", "1790726400", "--issuer-id", "BitGo"}, totals...)...)
+	assert.NoError(cmd.ValidateFlagGroups())
+	statement, err := flagStatement(cmd)
+	assert.NoError(err)
+	assert.Equal(context, statement.EpochContext, "the statement should bind the epoch context the proofs are made for")
+	assert.Equal([]byte{1, 2}, statement.MerkleRoot)
+	assert.Equal(int64(20), statement.AssetSum.Ethereum.Int64())
+
+	// the snapshot time and issuer are not taken from the expected epoch context
+	cmd = statementCommand(t, append([]string{"--epoch", "5", "--expected-epoch", "5", "--expected-snapshot-time", "2026-09-30T00:00:00Z", "--expected-issuer-id", "BitGo"}, totals...)...)
+	assert.ErrorContains(cmd.ValidateFlagGroups(), "snapshot-timestamp", "a statement without its snapshot time and issuer should be refused")
+
+	cmd = statementCommand(t, append([]string{"--epoch", "1", "--snapshot-timestamp", "0", "--issuer-id", ""}, totals...)...)
+	assert.NoError(cmd.ValidateFlagGroups())
+	statement, err = flagStatement(cmd)
+	assert.NoError(err)
+	assert.Equal(core.EpochContext{Epoch: 1}, statement.EpochContext, "epochs proven before the epoch context have none")
+
+	statement, err = flagStatement(statementCommand(t))
+	assert.NoError(err)
+	assert.Nil(statement)
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks the secret data for problems before proving",
+	Long: "Scans every batch of secret data and reports each problem that would make proving fail or produce wrong proofs, " +
+		"with its file, account index and user id: duplicate user ids across all batches, negative balances, balances " +
+		"outside the circuit's 64 bit range, user ids that would wrap modulo the field, wrong AssetSums and batches of more " +
+		"than 1024 accounts. With --bitcoin-price and --ethereum-price, the ledger is one of margin accounts: negative " +
+		"balances are allowed and accounts of negative net value at the prices are reported instead. With --segments, " +
+		"accounts not tagged with one of the segments are reported.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := reportFormat(cmd)
+		secretDir, _ := cmd.Flags().GetString("secret-dir")
+		validation := core.ValidateLedger(secretDir, append(priceOptions(cmd), segmentOptions(cmd)...)...)
+		if err := writeValidation(os.Stdout, format, validation); err != nil {
+			fmt.Println("Error writing report:", err)
+			os.Exit(1)
+		}
+		if !validation.Passed() {
+			os.Exit(1)
+		}
+	},
+}
+
+func writeValidation(w io.Writer, format string, validation core.LedgerValidation) error {
+	if format == outputJson {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(validation)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, issue := range validation.Issues {
+		index := "-"
+		if issue.Index >= 0 {
+			index = fmt.Sprint(issue.Index)
+		}
+		_, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", issue.Kind, issue.File, index, hex.EncodeToString(issue.UserId), issue.Message)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	var err error
+	switch {
+	case validation.Batches == 0:
+		_, err = fmt.Fprintln(w, "Validation failed! No secret data was found")
+	case validation.Passed():
+		_, err = fmt.Fprintf(w, "Validation succeeded! %d accounts in %d batches are ready to prove\n", validation.Accounts, validation.Batches)
+	default:
+		_, err = fmt.Fprintf(w, "Validation failed! %d issues in %d accounts in %d batches\n", len(validation.Issues), validation.Accounts, validation.Batches)
+	}
+	return err
+}
+
+func init() {
+	validateCmd.Flags().String("secret-dir", defaultSecretDir, "directory holding the secret data batches")
+	addPriceFlags(validateCmd)
+	addSegmentFlag(validateCmd)
+	addOutputFlag(validateCmd)
+	rootCmd.AddCommand(validateCmd)
+}
@@ -3,6 +3,9 @@ package cli
 import (
 	"bitgo.com/proof_of_reserves/circuit"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 
 	"bitgo.com/proof_of_reserves/core"
@@ -15,19 +18,41 @@ var verifyCmd = &cobra.Command{
 	Long:  "Verifies proofs using the public data in 'out/public/' and the user data in 'out/user/'. This function takes 1 argument: the number of batches.",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		format := reportFormat(cmd)
 		batchCount, err := strconv.Atoi(args[0])
 		if err != nil {
 			fmt.Println("Error parsing batchCount:", err)
 			return
 		}
+		options, err := statementOptions(cmd)
+		if err != nil {
+			fmt.Println("Error reading published statement:", err)
+			os.Exit(1)
+		}
+		epochOptions, err := expectedEpochOptions(cmd)
+		if err != nil {
+			fmt.Println("Error parsing expected epoch:", err)
+			os.Exit(1)
+		}
+		options = append(options, epochOptions...)
+		attestation, err := attestationOptions(cmd, "out/public")
+		if err != nil {
+			fmt.Println("Error reading attestations:", err)
+			os.Exit(1)
+		}
+		options = append(options, attestation...)
+		workers, err := cmd.Flags().GetInt("workers")
+		if err != nil {
+			fmt.Println("Error parsing workers:", err)
+			return
+		}
 		account := core.ReadDataFromFile[circuit.GoAccount]("out/user/test_account.json")
-		core.Verify(batchCount, account)
-		println("Verification succeeded!")
+		renderReport(format, core.Verify(batchCount, account, append(options, core.WithWorkers(workers))...))
 	},
 }
 
 var userVerifyCmd = &cobra.Command{
-	Use:   "userverify [path/to/useraccount.json] [path/to/bottomlevelproof.json] [path/to/midlevelproof.json] [path/to/toplevelproof.json]",
+	Use:   "userverify ([path/to/useraccount.json] [path/to/bottomlevelproof.json] [path/to/midlevelproof.json] [path/to/toplevelproof.json] | --account path/to/useraccount.json --proofs-dir path/to/public)",
 	Short: "Verify your account was included in the proofs and the proofs are sufficient.",
 	Long: "This is intended to be the main verification path, requiring O(log n) time to verify proof of solvency. " +
 		"This verification path verifies that \n" +
@@ -38,19 +63,67 @@ var userVerifyCmd = &cobra.Command{
 		"5) The chain of proofs is valid (i.e., your account was included in the asset sum for the low level proof, " +
 		"the low level proof was included in the asset sum for the mid level proof, " +
 		"the mid level proof was included in the asset sum for the high level proof, and " +
-		"there were no accounts with overflowing balances or negative balances included in any of the asset sums.",
-	Args: cobra.ExactArgs(4),
+		"there were no accounts with overflowing balances or negative balances included in any of the asset sums.\n\n" +
+		"Instead of the four files, --account and --proofs-dir can be given to find the bottom level proof containing " +
+		"your account in a directory of public proofs and follow it up to the top level proof.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Changed("proofs-dir") {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(4)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		format := reportFormat(cmd)
+		options, err := statementOptions(cmd)
+		if err != nil {
+			fmt.Println("Error reading published statement:", err)
+			os.Exit(1)
+		}
+		epochOptions, err := expectedEpochOptions(cmd)
+		if err != nil {
+			fmt.Println("Error parsing expected epoch:", err)
+			os.Exit(1)
+		}
+		options = append(options, epochOptions...)
+		proofsDir, _ := cmd.Flags().GetString("proofs-dir")
+		if proofsDir == "" {
+			proofsDir = filepath.Dir(args[3])
+		}
+		attestation, err := attestationOptions(cmd, proofsDir)
+		if err != nil {
+			fmt.Println("Error reading attestations:", err)
+			os.Exit(1)
+		}
+		options = append(options, attestation...)
+		if cmd.Flags().Changed("proofs-dir") {
+			accountFile, _ := cmd.Flags().GetString("account")
+			userAccount := core.ReadDataFromFile[circuit.GoAccount](accountFile)
+			renderReport(format, core.VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(userAccount), proofsDir, options...))
+			return
+		}
 		userAccount := core.ReadDataFromFile[circuit.GoAccount](args[0])
 		bottomLevelProof := core.ReadDataFromFile[core.CompletedProof](args[1])
 		midLevelProof := core.ReadDataFromFile[core.CompletedProof](args[2])
 		topLevelProof := core.ReadDataFromFile[core.CompletedProof](args[3])
-		core.VerifyProofPath(circuit.GoComputeMiMCHashForAccount(userAccount), bottomLevelProof, midLevelProof, topLevelProof)
-		println("Verification path succeeded!")
+		report := core.VerifyProofPath(circuit.GoComputeMiMCHashForAccount(userAccount), bottomLevelProof, midLevelProof, topLevelProof,
+			append(options, core.WithProofPathFiles(args[1], args[2], args[3]))...)
+		renderReport(format, report)
 	},
 }
 
 func init() {
+	addOutputFlag(verifyCmd)
+	addOutputFlag(userVerifyCmd)
+	addStatementFlags(verifyCmd)
+	addStatementFlags(userVerifyCmd)
+	addAttestationFlags(verifyCmd)
+	addAttestationFlags(userVerifyCmd)
+	addExpectedEpochFlags(verifyCmd)
+	addExpectedEpochFlags(userVerifyCmd)
+	verifyCmd.Flags().Int("workers", runtime.NumCPU(), "Number of proof files to verify concurrently")
+	userVerifyCmd.Flags().String("account", "", "Path to your account file, used with --proofs-dir")
+	userVerifyCmd.Flags().String("proofs-dir", "", "Directory of public proofs to find your proof path in, used with --account")
+	userVerifyCmd.MarkFlagsRequiredTogether("account", "proofs-dir")
 	rootCmd.AddCommand(verifyCmd)
 	rootCmd.AddCommand(userVerifyCmd)
 }
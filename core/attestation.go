@@ -0,0 +1,241 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	attestationName = "attestation.json"
+
+	// attestationDomain separates attestation signatures from any other use of the issuer key
+	attestationDomain = "bgproof attestation v1\n"
+)
+
+// ManifestEntry records the hash of the canonical serialization of a proof, so it does not depend on
+// whether the proof file is JSON or binary.
+type ManifestEntry struct {
+	Name string
+	Hash []byte
+}
+
+// Manifest lists every bottom, mid and top level proof of an epoch, sorted by name.
+type Manifest struct {
+	Proofs []ManifestEntry
+}
+
+// Attestation is an issuer's Ed25519 signature over the canonical serialization of the top level proof,
+// the manifest of all proofs and the published statement of an epoch.
+type Attestation struct {
+	Version         int
+	IssuerPublicKey []byte
+	Statement       PublishedStatement
+	Manifest        Manifest
+	TopLevelProof   []byte
+	Signature       []byte
+}
+
+// attestedContent is what an attestation signs, serialized with deterministic CBOR.
+type attestedContent struct {
+	TopLevelProof []byte
+	Manifest      Manifest
+	Statement     PublishedStatement
+}
+
+// canonicalProofHash is the SHA-256 hash of the deterministic CBOR encoding of proof.
+func canonicalProofHash(proof CompletedProof) []byte {
+	// the hash must not change when a proof is migrated to a newer schema version
+	proof.Version = 0
+	encoded, err := cborEncoding.Marshal(&proof)
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(encoded)
+	return hash[:]
+}
+
+func proofName(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+}
+
+// NewManifest hashes every bottom, mid and top level proof in proofsDir.
+func NewManifest(proofsDir string) (manifest Manifest, err error) {
+	manifest.Proofs = make([]ManifestEntry, 0)
+	for _, name := range []string{bottomLevelProofName, midLevelProofName, topLevelProofName} {
+		files, err := globProofFiles(proofsDir, name)
+		if err != nil {
+			return manifest, err
+		}
+		for _, file := range files {
+			proof, err := readCompletedProof(file)
+			if err != nil {
+				return manifest, fmt.Errorf("reading %s: %w", file, err)
+			}
+			manifest.Proofs = append(manifest.Proofs, ManifestEntry{Name: proofName(file), Hash: canonicalProofHash(proof)})
+		}
+	}
+	sort.Slice(manifest.Proofs, func(i, j int) bool { return manifest.Proofs[i].Name < manifest.Proofs[j].Name })
+	return manifest, nil
+}
+
+func (manifest Manifest) hashOf(name string) []byte {
+	for _, entry := range manifest.Proofs {
+		if entry.Name == name {
+			return entry.Hash
+		}
+	}
+	return nil
+}
+
+func (manifest Manifest) containsHash(hash []byte) bool {
+	for _, entry := range manifest.Proofs {
+		if bytes.Equal(entry.Hash, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+func (attestation Attestation) signedMessage() []byte {
+	encoded, err := cborEncoding.Marshal(attestedContent{
+		TopLevelProof: attestation.TopLevelProof,
+		Manifest:      attestation.Manifest,
+		Statement:     attestation.Statement,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte(attestationDomain), encoded...)
+}
+
+// SignAttestation attests to the proofs and published statement in proofsDir with the issuer's key.
+func SignAttestation(proofsDir string, privateKey ed25519.PrivateKey) (attestation Attestation, err error) {
+	statement, err := ReadStatementFromDir(proofsDir)
+	if err != nil {
+		return attestation, err
+	}
+	topLevelProof, err := ReadTopLevelProof(proofsDir)
+	if err != nil {
+		return attestation, err
+	}
+	manifest, err := NewManifest(proofsDir)
+	if err != nil {
+		return attestation, err
+	}
+	attestation = Attestation{
+		Version:         SchemaVersion,
+		IssuerPublicKey: privateKey.Public().(ed25519.PublicKey),
+		Statement:       statement,
+		Manifest:        manifest,
+		TopLevelProof:   canonicalProofHash(topLevelProof),
+	}
+	attestation.Signature = ed25519.Sign(privateKey, attestation.signedMessage())
+	return attestation, nil
+}
+
+func ReadAttestationFromFile(filePath string) (attestation Attestation, err error) {
+	err = readVersionedJson(filePath, &attestation)
+	return attestation, err
+}
+
+func WriteAttestationToFile(filePath string, attestation Attestation) error {
+	return writeJson(filePath, &attestation)
+}
+
+// AttestationFile is where the attestation of the proofs in proofsDir is kept.
+func AttestationFile(proofsDir string) string {
+	return filepath.Join(proofsDir, attestationName)
+}
+
+// verifyAttestationSignature checks the attestation is signed by the issuer and attests to the top level
+// proof with the given canonical hash.
+func verifyAttestationSignature(attestation Attestation, issuerKey ed25519.PublicKey, topLevelProof CompletedProof, topLevelHash []byte) {
+	if !bytes.Equal(attestation.IssuerPublicKey, issuerKey) {
+		panic(fmt.Sprintf("proofs are attested by %x, not the expected issuer %x", attestation.IssuerPublicKey, []byte(issuerKey)))
+	}
+	if !ed25519.Verify(issuerKey, attestation.signedMessage(), attestation.Signature) {
+		panic("attestation signature is invalid")
+	}
+	if !bytes.Equal(attestation.TopLevelProof, topLevelHash) {
+		panic("top level proof is not the attested one")
+	}
+	verifyTopLayerProofMatchesStatement(topLevelProof, attestation.Statement)
+}
+
+// verifyProofsAreAttested checks each proof, identified by the file it was read from, is the one listed
+// in the manifest under that name. When complete is set the manifest must list no other proofs.
+func verifyProofsAreAttested(attestation Attestation, files []string, hashes [][]byte, complete bool) {
+	for i, file := range files {
+		hash := attestation.Manifest.hashOf(proofName(file))
+		if hash == nil && !complete {
+			// proofs given directly to VerifyProofPath need not have their published names
+			if attestation.Manifest.containsHash(hashes[i]) {
+				continue
+			}
+			panic(fmt.Sprintf("%s is not in the attested manifest", file))
+		}
+		if !bytes.Equal(hash, hashes[i]) {
+			panic(fmt.Sprintf("%s does not match the attested manifest", file))
+		}
+	}
+	if complete && len(files) != len(attestation.Manifest.Proofs) {
+		panic(fmt.Sprintf("attested manifest lists %d proofs but %d were verified", len(attestation.Manifest.Proofs), len(files)))
+	}
+}
+
+// WithIssuerKey makes verification fail unless the proofs are attested by the issuer's key. A nil
+// attestation, as when none was published, fails verification.
+func WithIssuerKey(issuerKey ed25519.PublicKey, attestation *Attestation) VerifyOption {
+	return func(config *verifyConfig) {
+		config.issuerKey = issuerKey
+		config.attestation = attestation
+	}
+}
+
+// GenerateSigningKey generates an Ed25519 key pair, as used by issuers for attestations and auditors for endorsements.
+func GenerateSigningKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(nil)
+}
+
+// WriteSigningKeys writes the hex encoded public key to path.pub and private key seed to path.key.
+func WriteSigningKeys(path string, publicKey ed25519.PublicKey, privateKey ed25519.PrivateKey) error {
+	err := os.WriteFile(path+".key", []byte(hex.EncodeToString(privateKey.Seed())+"\n"), 0o600)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".pub", []byte(hex.EncodeToString(publicKey)+"\n"), 0o644)
+}
+
+func readHexKey(filePath string, size int) ([]byte, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("%s: expected a %d byte key, got %d bytes", filePath, size, len(key))
+	}
+	return key, nil
+}
+
+func ReadPublicKey(filePath string) (ed25519.PublicKey, error) {
+	return readHexKey(filePath, ed25519.PublicKeySize)
+}
+
+func ReadPrivateKey(filePath string) (ed25519.PrivateKey, error) {
+	seed, err := readHexKey(filePath, ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
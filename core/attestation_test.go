@@ -0,0 +1,118 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark/test"
+)
+
+// signTestProofs lays out the testdata proofs with a statement in 'out/public/' and attests to them.
+func signTestProofs(t *testing.T) (ed25519.PublicKey, Attestation) {
+	copyTestProofsToPublicDir(t)
+	err := WriteStatementToFile(statementFile, NewPublishedStatement(1, ReadDataFromFile[CompletedProof](topLevelProofPrefix+"0.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, privateKey, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := SignAttestation(publicDir, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publicKey, attestation
+}
+
+func TestVerifyWithIssuerKey(t *testing.T) {
+	assert := test.NewAssert(t)
+	account := ReadDataFromFile[ProofElements]("testdata/test_data_0.json").Accounts[0]
+	issuerKey, attestation := signTestProofs(t)
+	assert.Equal(4, len(attestation.Manifest.Proofs))
+
+	report := Verify(2, account, WithIssuerKey(issuerKey, &attestation))
+	assert.True(report.Passed())
+	assert.Equal(CheckAttestation, report.Checks[len(report.Checks)-1].Kind)
+
+	report = Verify(2, account, WithIssuerKey(issuerKey, nil))
+	assert.False(report.Passed(), "should fail when the proofs are unsigned")
+	assert.Equal(CheckAttestation, report.Failures()[0].Kind)
+
+	otherKey, _, err := GenerateSigningKey()
+	assert.NoError(err)
+	report = Verify(2, account, WithIssuerKey(otherKey, &attestation))
+	assert.False(report.Passed(), "should fail when the proofs are signed by another key")
+
+	forged := attestation
+	forged.Statement.Epoch = 2
+	report = Verify(2, account, WithIssuerKey(issuerKey, &forged))
+	assert.False(report.Passed(), "should fail when the signed content is altered")
+
+	incomplete := attestation
+	incomplete.Manifest.Proofs = attestation.Manifest.Proofs[1:]
+	assert.Panics(func() {
+		verifyProofsAreAttested(incomplete, []string{"test_proof_0.json"}, [][]byte{attestation.Manifest.Proofs[0].Hash}, true)
+	})
+}
+
+func TestVerifyProofPathWithIssuerKey(t *testing.T) {
+	assert := test.NewAssert(t)
+	issuerKey, attestation := signTestProofs(t)
+
+	report := VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithIssuerKey(issuerKey, &attestation))
+	assert.True(report.Passed())
+
+	// a valid, self-consistent proof set that was not attested
+	report = VerifyProofPath(altProofLower0.AccountLeaves[0], altProofLower0, altProofMid, altProofTop, WithIssuerKey(issuerKey, &attestation))
+	assert.False(report.Passed())
+	assert.Equal(CheckAttestation, report.Failures()[0].Kind)
+}
+
+func TestAttestationSurvivesConversion(t *testing.T) {
+	assert := test.NewAssert(t)
+	issuerKey, attestation := signTestProofs(t)
+	binaryDir := t.TempDir()
+	_, err := ConvertProofFiles(publicDir, binaryDir, ProofFormatBinary)
+	assert.NoError(err)
+
+	manifest, err := NewManifest(binaryDir)
+	assert.NoError(err)
+	assert.Equal(attestation.Manifest, manifest, "the manifest should not depend on the proof file format")
+
+	path := filepath.Join(t.TempDir(), attestationName)
+	assert.NoError(WriteAttestationToFile(path, attestation))
+	read, err := ReadAttestationFromFile(path)
+	assert.NoError(err)
+	report := VerifyProofPathInDir(proofLower0.AccountLeaves[0], binaryDir, WithIssuerKey(issuerKey, &read))
+	assert.True(report.Passed())
+}
+
+func TestAttestationSurvivesSchemaMigration(t *testing.T) {
+	assert := test.NewAssert(t)
+	account := ReadDataFromFile[ProofElements]("testdata/test_data_0.json").Accounts[0]
+	issuerKey, attestation := signTestProofs(t)
+
+	// the proofs are rewritten as the schema version before, and read as a later schema version would read them
+	files, err := filepath.Glob(filepath.Join(publicDir, "*_proof_*.json"))
+	assert.NoError(err)
+	assert.Equal(len(attestation.Manifest.Proofs), len(files))
+	for _, file := range files {
+		proof, err := readCompletedProof(file)
+		assert.NoError(err)
+		migrated := proof
+		migrated.Version = SchemaVersion + 1
+		assert.Equal(attestation.Manifest.hashOf(proofName(file)), canonicalProofHash(migrated), "the hash of %s should not depend on its schema version", file)
+		proof.Version = SchemaVersion - 1
+		assert.NoError(writeJson(file, &proof))
+	}
+	b, err := os.ReadFile(files[0])
+	assert.NoError(err)
+	assert.Contains(string(b), fmt.Sprintf(`"Version": %d`, SchemaVersion-1))
+
+	report := Verify(2, account, WithIssuerKey(issuerKey, &attestation))
+	assert.True(report.Passed(), report.Failures())
+}
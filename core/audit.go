@@ -0,0 +1,296 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+// auditListLimit caps how many mismatching leaves or missing accounts an audit check lists in its error.
+const auditListLimit = 10
+
+// countIndexedFiles counts the files name0, name1, ... in dir, in either proof format, up to the first one missing.
+func countIndexedFiles(dir string, name string) int {
+	count := 0
+	for {
+		if _, err := os.Stat(resolveProofFile(proofFilePath(filepath.Join(dir, name), count))); err != nil {
+			return count
+		}
+		count++
+	}
+}
+
+func listHex(values [][]byte, total int) string {
+	listed := make([]string, 0, auditListLimit)
+	for i := 0; i < len(values) && i < auditListLimit; i++ {
+		listed = append(listed, hex.EncodeToString(values[i]))
+	}
+	if total > len(listed) {
+		listed = append(listed, fmt.Sprintf("and %d more", total-len(listed)))
+	}
+	return strings.Join(listed, ", ")
+}
+
+// verifyLeavesMatchAccounts checks a bottom level proof publishes exactly the leaves of the batch's accounts, in order.
+func verifyLeavesMatchAccounts(accounts []circuit.GoAccount, proof CompletedProof) {
+	leaves := computeAccountLeavesFromAccounts(accounts)
+	mismatches := make([]string, 0)
+	count := 0
+	for i := 0; i < len(leaves) || i < len(proof.AccountLeaves); i++ {
+		if i < len(leaves) && i < len(proof.AccountLeaves) && bytes.Equal(leaves[i], proof.AccountLeaves[i]) {
+			continue
+		}
+		count++
+		if len(mismatches) < auditListLimit {
+			mismatches = append(mismatches, fmt.Sprint(i))
+		}
+	}
+	if count > 0 {
+		panic(fmt.Sprintf("%d leaves differ from the ledger (%d accounts, %d published leaves), at indices %s",
+			count, len(leaves), len(proof.AccountLeaves), strings.Join(mismatches, ", ")))
+	}
+}
+
+// auditedBatch is what an audit recomputes from a batch of secret data.
+type auditedBatch struct {
+	merkleRoot                 []byte
+	merkleRootWithAssetSumHash []byte
+	assetSum                   circuit.GoBalance
+}
+
+// recomputeBatch recomputes the batch of elements, whose asset sum was proven blinded with blinding unless it is nil.
+func recomputeBatch(elements ProofElements, blinding *big.Int) auditedBatch {
+	batch := auditedBatch{
+		merkleRoot: circuit.GoComputeMerkleRootFromAccounts(elements.Accounts),
+		assetSum:   sumBalances(elements.Accounts, elements.AssetSum),
+	}
+	blinded := batch.assetSum
+	blinded.Blinding = blinding
+	batch.merkleRootWithAssetSumHash = circuit.GoComputeMiMCHashForAccount(circuit.GoAccount{UserId: batch.merkleRoot, Balance: blinded})
+	return batch
+}
+
+// verifyBatchRoot checks the batch root recomputed from the ledger is both the one recorded with the secret data and the published one.
+func verifyBatchRoot(elements ProofElements, batch auditedBatch, proof CompletedProof) {
+	if elements.MerkleRoot != nil && !bytes.Equal(elements.MerkleRoot, batch.merkleRoot) {
+		panic("ledger accounts do not hash to the merkle root recorded with them")
+	}
+	if !bytes.Equal(batch.merkleRoot, proof.MerkleRoot) {
+		panic(fmt.Sprintf("recomputed merkle root %x differs from the published %x", batch.merkleRoot, proof.MerkleRoot))
+	}
+}
+
+// verifyBatchAssetSum checks the asset sum recomputed from the ledger is the one recorded with the secret data and,
+// through MerkleRootWithAssetSumHash, the one committed to by the published proof.
+func verifyBatchAssetSum(elements ProofElements, batch auditedBatch, proof CompletedProof) {
+	if elements.AssetSum != nil && !elements.AssetSum.Equals(batch.assetSum) {
+		panic(fmt.Sprintf("ledger balances sum to (%s) but (%s) is recorded with them", describeBalance(batch.assetSum), describeBalance(*elements.AssetSum)))
+	}
+	if proof.AssetSum != nil && !proof.AssetSum.Equals(batch.assetSum) {
+		panic("published asset sum differs from the ledger balances")
+	}
+	if !bytes.Equal(batch.merkleRootWithAssetSumHash, proof.MerkleRootWithAssetSumHash) {
+		panic("published merkle root with asset sum hash does not commit to the ledger balances")
+	}
+}
+
+// Audit recomputes every leaf, batch root, MerkleRootWithAssetSumHash and asset sum from the secret data in
+// secretDir and compares them against the proofs published in publicDir, then recomputes the mid and top levels
+// from the batches. It also reports ledger accounts that are in no published bottom level proof. The accounts are
+// first placed as the prover placed them, which needs the epoch's seed if they were padded or shuffled, and the
+// batches of an epoch hiding its totals are hashed with the blinding factors kept with the opening of its
+// commitment. Audit does not check the SNARKs; run Verify for that.
+func Audit(secretDir string, publicDir string, placement Placement) VerificationReport {
+	report := newVerificationReport()
+	batchCount := countIndexedFiles(secretDir, secretDataName)
+	proofCount := countIndexedFiles(publicDir, bottomLevelProofName)
+	report.runCheck(CheckProofSetLayout, publicDir, func() {
+		if batchCount == 0 {
+			panic(fmt.Sprintf("no secret data found in %s", secretDir))
+		}
+		if batchCount != proofCount {
+			panic(fmt.Sprintf("%d batches of secret data but %d bottom level proofs", batchCount, proofCount))
+		}
+	})
+
+	secretFiles := make([]string, batchCount)
+	ledger := make([]ProofElements, batchCount)
+	read := true
+	for i := range ledger {
+		secretFiles[i] = proofFilePath(filepath.Join(secretDir, secretDataName), i)
+		read = report.runCheckIfFails(CheckProofFile, secretFiles[i], func() {
+			if err := readVersionedJson(secretFiles[i], &ledger[i]); err != nil {
+				panic(err)
+			}
+		}) && read
+	}
+	if !read {
+		return report.finish()
+	}
+	// the ledger's accounts are counted if the prover counted them, as the bottom level proofs' circuit tells
+	firstProof, err := readCompletedProof(resolveProofFile(proofFilePath(filepath.Join(publicDir, bottomLevelProofName), 0)))
+	if err == nil && circuits[firstProof.CircuitId].countsAccounts {
+		ledger = CountAccounts(ledger)
+	}
+	// and priced at the prices the bottom level proofs were proven at, if they are cross-margin proofs
+	var prices *circuit.GoBalance
+	if err == nil && circuits[firstProof.CircuitId].crossMargin {
+		prices = firstProof.Prices
+	}
+	// and split into segments if they were proven with them
+	segmented := err == nil && circuits[firstProof.CircuitId].segments
+	// and hashed with the blinding factors kept with the opening of the hidden totals if their sums were blinded
+	blinded := err == nil && circuits[firstProof.CircuitId].blindsSum
+	batchBlindings := make([]*big.Int, batchCount)
+	if !report.runCheckIfFails(CheckAuditAssetSum, secretDir, func() {
+		if blinded {
+			opening, err := ReadAssetSumOpeningFromFile(filepath.Join(secretDir, assetSumOpeningName))
+			if err != nil {
+				panic(err)
+			}
+			if len(opening.BatchBlindings) != batchCount {
+				panic(fmt.Sprintf("the asset sum opening has %d batch blinding factors for %d batches", len(opening.BatchBlindings), batchCount))
+			}
+			batchBlindings = opening.BatchBlindings
+		}
+		if prices != nil {
+			ledger = PriceAccounts(ledger, *prices)
+		}
+		if segmented {
+			ledger = SegmentAccounts(ledger)
+		}
+		ledger = placement.apply(ledger)
+	}) {
+		return report.finish()
+	}
+
+	publishedLeaves := make(map[string]bool)
+	unpublished := make([]circuit.GoAccount, 0)
+	batches := make([]CompletedProof, 0, batchCount)
+	var total circuit.GoBalance
+	for i, elements := range ledger {
+		secretFile := secretFiles[i]
+		proofFile := resolveProofFile(proofFilePath(filepath.Join(publicDir, bottomLevelProofName), i))
+		var proof CompletedProof
+		if !report.runCheckIfFails(CheckProofFile, proofFile, func() {
+			var err error
+			if proof, err = readCompletedProof(proofFile); err != nil {
+				panic(err)
+			}
+		}) {
+			continue
+		}
+
+		var batch auditedBatch
+		if !report.runCheckIfFails(CheckAuditAssetSum, secretFile, func() { batch = recomputeBatch(elements, batchBlindings[i]) }) {
+			continue
+		}
+		report.runCheck(CheckAuditLeaves, proofFile, func() { verifyLeavesMatchAccounts(elements.Accounts, proof) })
+		report.runCheck(CheckAuditBatchRoot, proofFile, func() { verifyBatchRoot(elements, batch, proof) })
+		report.runCheck(CheckAuditAssetSum, proofFile, func() { verifyBatchAssetSum(elements, batch, proof) })
+
+		for _, leaf := range proof.AccountLeaves {
+			publishedLeaves[string(leaf)] = true
+		}
+		for _, account := range elements.Accounts {
+			if findInclusionInProofs(circuit.GoComputeMiMCHashForAccount(account), []CompletedProof{proof}) == -1 {
+				// the account may still have been published in another batch, which is checked once all are read
+				unpublished = append(unpublished, account)
+			}
+		}
+		batches = append(batches, CompletedProof{
+			EpochContext:               proof.EpochContext,
+			MerkleRoot:                 batch.merkleRoot,
+			MerkleRootWithAssetSumHash: batch.merkleRootWithAssetSumHash,
+		})
+		total.Bitcoin.Add(&total.Bitcoin, &batch.assetSum.Bitcoin)
+		total.Ethereum.Add(&total.Ethereum, &batch.assetSum.Ethereum)
+		if batch.assetSum.AccountCount != nil {
+			if total.AccountCount == nil {
+				total.AccountCount = new(big.Int)
+			}
+			total.AccountCount.Add(total.AccountCount, batch.assetSum.AccountCount)
+		}
+		if batch.assetSum.Gross != nil {
+			if total.Gross == nil {
+				total.Gross, total.Prices = new(circuit.GoBalance), batch.assetSum.Prices
+			}
+			total.Gross.Bitcoin.Add(&total.Gross.Bitcoin, &batch.assetSum.Gross.Bitcoin)
+			total.Gross.Ethereum.Add(&total.Gross.Ethereum, &batch.assetSum.Gross.Ethereum)
+		}
+		addSegments(&total, batch.assetSum)
+	}
+
+	report.runCheck(CheckAuditLedgerInclusion, secretDir, func() {
+		missing := make([][]byte, 0)
+		count := 0
+		for _, account := range unpublished {
+			if !publishedLeaves[string(circuit.GoComputeMiMCHashForAccount(account))] {
+				count++
+				if len(missing) < auditListLimit {
+					missing = append(missing, account.UserId)
+				}
+			}
+		}
+		if count > 0 {
+			panic(fmt.Sprintf("%d ledger accounts are in no published bottom level proof: user ids %s", count, listHex(missing, count)))
+		}
+	})
+
+	report.auditUpperLevels(secretDir, publicDir, batches, total)
+	return report.finish()
+}
+
+// auditUpperLevels checks the published mid and top level proofs aggregate the batches recomputed from the ledger.
+// A top level proof hiding its totals is checked against the opening of its commitment kept in secretDir.
+func (report *VerificationReport) auditUpperLevels(secretDir string, publicDir string, batches []CompletedProof, total circuit.GoBalance) {
+	midLevelProofs := make([]CompletedProof, 0)
+	for i, batch := range batchProofs(batches, 1024) {
+		midLevelFile := resolveProofFile(proofFilePath(filepath.Join(publicDir, midLevelProofName), i))
+		var midLevelProof CompletedProof
+		read := report.runCheckIfFails(CheckProofFile, midLevelFile, func() {
+			var err error
+			if midLevelProof, err = readCompletedProof(midLevelFile); err != nil {
+				panic(err)
+			}
+		})
+		if !read {
+			continue
+		}
+		report.runCheck(CheckChildToParent, midLevelFile, func() { verifyLowerLayerProofsLeadToUpperLayerProof(batch, midLevelProof) })
+		midLevelProofs = append(midLevelProofs, midLevelProof)
+	}
+
+	topLevelFile := resolveProofFile(proofFilePath(filepath.Join(publicDir, topLevelProofName), 0))
+	var topLevelProof CompletedProof
+	read := report.runCheckIfFails(CheckProofFile, topLevelFile, func() {
+		var err error
+		if topLevelProof, err = readCompletedProof(topLevelFile); err != nil {
+			panic(err)
+		}
+	})
+	if !read {
+		return
+	}
+	report.runCheck(CheckChildToParent, topLevelFile, func() { verifyLowerLayerProofsLeadToUpperLayerProof(midLevelProofs, topLevelProof) })
+	report.runCheck(CheckTopLevelSum, topLevelFile, func() {
+		verifyTopLayerProofMatchesAssetSum(topLevelProof)
+		if circuits[topLevelProof.CircuitId].hidesTotal {
+			opening, err := ReadAssetSumOpeningFromFile(filepath.Join(secretDir, assetSumOpeningName))
+			if err != nil {
+				panic(err)
+			}
+			verifyAssetSumOpening(topLevelProof, opening, total)
+			return
+		}
+		if !topLevelProof.AssetSum.Equals(total) {
+			panic(fmt.Sprintf("published total (%s) differs from the ledger total (%s)", describeBalance(*topLevelProof.AssetSum), describeBalance(total)))
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+// writeAuditDirs lays out the testdata secret data and proofs as a secret and a public directory.
+func writeAuditDirs(t *testing.T) (secret string, public string) {
+	secret, public = t.TempDir(), t.TempDir()
+	copyTestProofs(t, public)
+	for _, name := range []string{"test_data_0.json", "test_data_1.json"} {
+		b, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(secret, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return secret, public
+}
+
+// rewriteSecretData changes the accounts of a batch of secret data, keeping the recorded root and sum.
+func rewriteSecretData(t *testing.T, secret string, index int, change func([]circuit.GoAccount) []circuit.GoAccount) {
+	file := proofFilePath(filepath.Join(secret, secretDataName), index)
+	elements := ReadDataFromFile[ProofElements](file)
+	elements.Accounts = change(elements.Accounts)
+	if err := writeJson(file, elements); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func failedKinds(report VerificationReport) map[CheckKind]bool {
+	kinds := make(map[CheckKind]bool)
+	for _, failure := range report.Failures() {
+		kinds[failure.Kind] = true
+	}
+	return kinds
+}
+
+func TestAudit(t *testing.T) {
+	assert := test.NewAssert(t)
+	secret, public := writeAuditDirs(t)
+
+	report := Audit(secret, public, Placement{})
+	assert.True(report.Passed(), report.Failures())
+	kinds := make(map[CheckKind]bool)
+	for _, check := range report.Checks {
+		kinds[check.Kind] = true
+	}
+	for _, kind := range []CheckKind{CheckAuditLeaves, CheckAuditBatchRoot, CheckAuditAssetSum, CheckAuditLedgerInclusion, CheckChildToParent, CheckTopLevelSum} {
+		assert.True(kinds[kind], "audit should run %s checks", kind)
+	}
+}
+
+func TestAuditDetectsChangedBalance(t *testing.T) {
+	assert := test.NewAssert(t)
+	secret, public := writeAuditDirs(t)
+	rewriteSecretData(t, secret, 1, func(accounts []circuit.GoAccount) []circuit.GoAccount {
+		accounts[3].Balance.Bitcoin.Add(&accounts[3].Balance.Bitcoin, big.NewInt(1))
+		return accounts
+	})
+
+	kinds := failedKinds(Audit(secret, public, Placement{}))
+	assert.True(kinds[CheckAuditLeaves])
+	assert.True(kinds[CheckAuditBatchRoot])
+	assert.True(kinds[CheckAuditAssetSum])
+	assert.True(kinds[CheckAuditLedgerInclusion], "the changed account is in no published proof")
+	assert.True(kinds[CheckTopLevelSum])
+}
+
+func TestAuditDetectsMissingAccount(t *testing.T) {
+	assert := test.NewAssert(t)
+	secret, public := writeAuditDirs(t)
+	rewriteSecretData(t, secret, 0, func(accounts []circuit.GoAccount) []circuit.GoAccount {
+		missing := circuit.GoAccount{UserId: []byte{0x42}, Balance: circuit.GoBalance{Bitcoin: *big.NewInt(5), Ethereum: *big.NewInt(0)}}
+		return append(accounts, missing)
+	})
+
+	report := Audit(secret, public, Placement{})
+	assert.False(report.Passed())
+	for _, failure := range report.Failures() {
+		if failure.Kind == CheckAuditLedgerInclusion {
+			assert.Contains(failure.Error, "1 ledger accounts")
+			assert.Contains(failure.Error, "42")
+			return
+		}
+	}
+	t.Fatal("the missing account was not reported")
+}
+
+func TestAuditReportsNegativeBalances(t *testing.T) {
+	assert := test.NewAssert(t)
+	secret, public := writeAuditDirs(t)
+	rewriteSecretData(t, secret, 0, func(accounts []circuit.GoAccount) []circuit.GoAccount {
+		accounts[0].Balance.Ethereum.SetInt64(-1)
+		return accounts
+	})
+
+	var report VerificationReport
+	assert.NotPanics(func() { report = Audit(secret, public, Placement{}) })
+	assert.True(failedKinds(report)[CheckAuditAssetSum])
+}
+
+func TestAuditRequiresMatchingBatches(t *testing.T) {
+	assert := test.NewAssert(t)
+	secret, public := writeAuditDirs(t)
+	assert.NoError(os.Remove(filepath.Join(public, "test_proof_1.json")))
+	assert.True(failedKinds(Audit(secret, public, Placement{}))[CheckProofSetLayout])
+	assert.False(Audit(t.TempDir(), public, Placement{}).Passed())
+}
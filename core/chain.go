@@ -0,0 +1,78 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+type chainEpoch struct {
+	dir       string
+	statement PublishedStatement
+}
+
+// readChainEpochs reads the statement of every epoch directory in epochsDir, sorted by epoch.
+func (report *VerificationReport) readChainEpochs(epochsDir string) []chainEpoch {
+	entries, err := os.ReadDir(epochsDir)
+	if err != nil {
+		report.runCheck(CheckProofFile, epochsDir, func() { panic(err) })
+		return nil
+	}
+	epochs := make([]chainEpoch, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(epochsDir, entry.Name())
+		var statement PublishedStatement
+		read := report.runCheckIfFails(CheckProofFile, filepath.Join(dir, statementName), func() {
+			var err error
+			statement, err = ReadStatementFromDir(dir)
+			if err != nil {
+				panic(err)
+			}
+		})
+		if read {
+			epochs = append(epochs, chainEpoch{dir: dir, statement: statement})
+		}
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i].statement.Epoch < epochs[j].statement.Epoch })
+	return epochs
+}
+
+// VerifyChain walks a directory holding one directory of public proofs per epoch and checks the epochs
+// form an unbroken chain: each top level proof is valid and matches its epoch's statement, and each
+// statement follows the one before it in epoch number and time and records its hash.
+func VerifyChain(epochsDir string) VerificationReport {
+	report := newVerificationReport()
+	epochs := report.readChainEpochs(epochsDir)
+	report.runCheck(CheckEpochChain, epochsDir, func() {
+		if len(epochs) == 0 {
+			panic(fmt.Sprintf("no epochs found in %s", epochsDir))
+		}
+	})
+
+	for i, epoch := range epochs {
+		statementFile := filepath.Join(epoch.dir, statementName)
+		topLevelFile := resolveProofFile(proofFilePath(filepath.Join(epoch.dir, topLevelProofName), 0))
+		var topLevelProof CompletedProof
+		read := report.runCheckIfFails(CheckProofFile, topLevelFile, func() {
+			var err error
+			topLevelProof, err = readCompletedProof(topLevelFile)
+			if err != nil {
+				panic(err)
+			}
+		})
+		if read {
+			report.verifyProofChecks(topLevelProof, topLevelFile)
+			report.runCheck(CheckTopLevelSum, topLevelFile, func() { verifyTopLayerProofMatchesAssetSum(topLevelProof) })
+			report.runCheck(CheckStatement, topLevelFile, func() { verifyTopLayerProofMatchesStatement(topLevelProof, epoch.statement) })
+		}
+		if i > 0 {
+			previous := epochs[i-1].statement
+			report.runCheck(CheckEpochChain, statementFile, func() { verifyStatementFollows(previous, epoch.statement) })
+		}
+	}
+	return report.finish()
+}
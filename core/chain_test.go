@@ -0,0 +1,117 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/consensys/gnark/test"
+)
+
+// writeTestChain writes count epoch directories holding the testdata top level proof and a linked statement.
+func writeTestChain(t *testing.T, count int) (epochsDir string, statements []PublishedStatement) {
+	epochsDir = t.TempDir()
+	b, err := os.ReadFile("testdata/test_top_level_proof_0.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var previous *PublishedStatement
+	for i := 0; i < count; i++ {
+		statement := linkStatement(NewPublishedStatement(uint64(i+1), proofTop), previous, start.AddDate(0, i, 0))
+		dir := filepath.Join(epochsDir, statement.Timestamp.Format("2006-01"))
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "test_top_level_proof_0.json"), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteStatementToFile(filepath.Join(dir, statementName), statement); err != nil {
+			t.Fatal(err)
+		}
+		statements = append(statements, statement)
+		previous = &statements[i]
+	}
+	return epochsDir, statements
+}
+
+func TestVerifyChain(t *testing.T) {
+	assert := test.NewAssert(t)
+	epochsDir, statements := writeTestChain(t, 3)
+	assert.Nil(statements[0].PreviousStatementHash)
+	assert.Equal(statements[0].Hash(), statements[1].PreviousStatementHash)
+
+	report := VerifyChain(epochsDir)
+	assert.True(report.Passed())
+	links := 0
+	for _, check := range report.Checks {
+		if check.Kind == CheckEpochChain {
+			links++
+		}
+	}
+	assert.Equal(3, links, "the chain should be checked as a whole and at each of the 2 links")
+}
+
+func TestVerifyChainDetectsRewrittenEpoch(t *testing.T) {
+	assert := test.NewAssert(t)
+	epochsDir, statements := writeTestChain(t, 3)
+
+	rewritten := statements[1]
+	rewritten.Timestamp = rewritten.Timestamp.Add(time.Hour)
+	assert.NoError(WriteStatementToFile(filepath.Join(epochsDir, "2026-02", statementName), rewritten))
+
+	report := VerifyChain(epochsDir)
+	assert.False(report.Passed())
+	assert.Equal(1, len(report.Failures()))
+	assert.Equal(CheckEpochChain, report.Failures()[0].Kind)
+	assert.Equal(filepath.Join(epochsDir, "2026-03", statementName), report.Failures()[0].File)
+}
+
+func TestVerifyChainDetectsDroppedEpoch(t *testing.T) {
+	assert := test.NewAssert(t)
+	epochsDir, _ := writeTestChain(t, 3)
+	assert.NoError(os.RemoveAll(filepath.Join(epochsDir, "2026-02")))
+
+	report := VerifyChain(epochsDir)
+	assert.False(report.Passed())
+	assert.Equal(CheckEpochChain, report.Failures()[0].Kind)
+}
+
+func TestVerifyChainRequiresEpochs(t *testing.T) {
+	assert := test.NewAssert(t)
+	assert.False(VerifyChain(t.TempDir()).Passed())
+}
+
+func TestStatementFollows(t *testing.T) {
+	assert := test.NewAssert(t)
+	now := time.Now()
+	first := linkStatement(NewPublishedStatement(1, proofTop), nil, now)
+	second := linkStatement(NewPublishedStatement(2, proofTop), &first, now.Add(time.Minute))
+	assert.NotPanics(func() { verifyStatementFollows(first, second) })
+
+	migrated := first
+	migrated.Version = 0
+	assert.Equal(first.Hash(), migrated.Hash(), "the hash should not depend on the schema version")
+
+	sameTime := linkStatement(NewPublishedStatement(2, proofTop), &first, now)
+	assert.Panics(func() { verifyStatementFollows(first, sameTime) }, "timestamps must increase")
+	assert.Panics(func() { verifyEpochFollows(first, 3) }, "epochs must not be skipped")
+}
+
+func TestEpochIsCheckedForCircuitsThatBindIt(t *testing.T) {
+	assert := test.NewAssert(t)
+	statement := NewPublishedStatement(5, proofTop)
+	assert.NotPanics(func() { verifyTopLayerProofMatchesStatement(proofTop, statement) }, "v1 proofs do not prove an epoch")
+
+	epochProof := proofTop
+	epochProof.CircuitId = circuitIdV2
+	epochProof.Epoch = 4
+	assert.Panics(func() { verifyTopLayerProofMatchesStatement(epochProof, statement) })
+	epochProof.Epoch = 5
+	assert.NotPanics(func() { verifyTopLayerProofMatchesStatement(epochProof, statement) })
+
+	child := proofMid
+	child.Epoch = 4
+	assert.Panics(func() { verifyLowerLayerProofsLeadToUpperLayerProof([]CompletedProof{child}, epochProof) })
+}
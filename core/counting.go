@@ -0,0 +1,87 @@
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"math/big"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+// CountAccounts counts every account of the batches as one ledger account, and adds their number to the batch's
+// recorded asset sum, so that the proofs made from them prove how many ledger accounts the epoch has. Dummy accounts
+// added afterwards by padding count for none. The recorded asset sums are otherwise left as they are.
+func CountAccounts(batches []ProofElements) []ProofElements {
+	counted := make([]ProofElements, len(batches))
+	for i, batch := range batches {
+		counted[i] = batch
+		counted[i].Accounts = make([]circuit.GoAccount, len(batch.Accounts))
+		for j, account := range batch.Accounts {
+			account.Balance.AccountCount = big.NewInt(1)
+			counted[i].Accounts[j] = account
+		}
+		if batch.AssetSum != nil {
+			assetSum := *batch.AssetSum
+			assetSum.AccountCount = big.NewInt(int64(len(batch.Accounts)))
+			counted[i].AssetSum = &assetSum
+		}
+		// the recorded roots did not commit to the counts
+		counted[i].MerkleRoot, counted[i].MerkleRootWithAssetSumHash = nil, nil
+	}
+	return counted
+}
+
+// WithAccountCount proves the number of ledger accounts along with the asset sum: every proof is made with
+// circuit.CountingCircuitId or its unbound variant, and the top level proof's AssetSum, like the published
+// statement's, has the AccountCount of the epoch. Padding does not count, so the count reveals how many accounts the
+// ledger has. The user's account file, if there is one, is given its AccountCount of 1, as their leaf hashes it.
+func WithAccountCount() ProveOption {
+	return func(config *proveConfig) {
+		config.countAccounts = true
+	}
+}
+
+// countings is how the bottom level proofs, and the mid and top level proofs, count accounts.
+func (config *proveConfig) countings() (bottomLevel circuit.Counting, upperLevels circuit.Counting) {
+	if !config.countAccounts {
+		return circuit.NoCounting, circuit.NoCounting
+	}
+	return circuit.CountLeaves, circuit.CountSubtrees
+}
+
+// prepare counts, prices or segments and places the ledger's batches as configured, ready for the bottom level proofs.
+func (config *proveConfig) prepare(batches []ProofElements) []ProofElements {
+	if config.countAccounts {
+		batches = CountAccounts(batches)
+	}
+	if config.prices != nil {
+		batches = PriceAccounts(batches, *config.prices)
+	}
+	if config.segments {
+		batches = SegmentAccounts(batches)
+	} else {
+		verifyUnsegmented(batches)
+	}
+	return config.placement.apply(batches)
+}
+
+// recordUserAccountCount rewrites the user's account file, if there is one, as the leaf the epoch publishes for
+// them: with an AccountCount of 1 if the epoch counts accounts, and without one otherwise.
+func recordUserAccountCount(file string, counted bool) {
+	var account circuit.GoAccount
+	err := readVersionedJson(file, &account)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+	account.Version = SchemaVersion
+	account.Balance.AccountCount = nil
+	if counted {
+		account.Balance.AccountCount = big.NewInt(1)
+	}
+	if err = writeJson(file, &account); err != nil {
+		panic(err)
+	}
+}
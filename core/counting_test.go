@@ -0,0 +1,47 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+func TestCountAccounts(t *testing.T) {
+	assert := test.NewAssert(t)
+	ledger := []ProofElements{generatedBatch(3, 11)}
+	counted := PadAccounts(CountAccounts(ledger), []byte("seed"), 5)
+	assert.Nil(ledger[0].Accounts[0].Balance.AccountCount, "the ledger should be left as it is")
+	for i, account := range counted[0].Accounts {
+		if i < 3 {
+			assert.Equal(int64(1), account.Balance.AccountCount.Int64())
+		} else {
+			assert.Equal(int64(0), account.Balance.AccountCount.Int64(), "padding should not count")
+		}
+	}
+	assert.Equal(int64(3), counted[0].AssetSum.AccountCount.Int64())
+	assert.True(counted[0].AssetSum.Equals(circuit.SumGoAccountBalances(counted[0].Accounts)))
+}
+
+// countedLeaf is the leaf of a ledger account proven with its account count.
+func countedLeaf(account circuit.GoAccount) circuit.GoAccount {
+	account.Balance.AccountCount = big.NewInt(1)
+	return account
+}
+
+func checkAccountCount(assert *test.Assert, ledger []ProofElements, _ []CompletedProof, top CompletedProof, statement PublishedStatement) {
+	assert.Equal(int64(7), top.AssetSum.AccountCount.Int64(), "only the ledger accounts should count")
+	assert.Equal(int64(7), statement.AssetSum.AccountCount.Int64())
+
+	// a user's leaf counts their account
+	account := ledger[1].Accounts[0]
+	assert.False(VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(account), publicDir).Passed())
+	userAccount := ReadDataFromFile[circuit.GoAccount](userAccountFile)
+	assert.Equal(circuit.GoComputeMiMCHashForAccount(countedLeaf(account)), circuit.GoComputeMiMCHashForAccount(userAccount), "the user's file should have their count")
+
+	statement.AssetSum.AccountCount = big.NewInt(8)
+	assert.False(VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(countedLeaf(account)), publicDir, WithPublishedStatement(statement)).Passed())
+	top.AssetSum.AccountCount = nil
+	assert.Panics(func() { verifyTopLayerProofMatchesAssetSum(top) }, "a counting proof must publish its count")
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"fmt"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+)
+
+// WithCrossMargin proves a ledger of margin accounts, whose balances may be negative, with
+// circuit.CrossMarginCircuitId: each account's net value at prices, in a common quote unit per base unit of each
+// asset, is proven not to be negative. The top level proof's AssetSum, like the published statement's, holds the
+// net liabilities of each asset, with the gross total of the positive balances and prices. The account count and
+// hidden totals cannot be proven for cross-margin ledgers.
+func WithCrossMargin(prices circuit.GoBalance) ProveOption {
+	return func(config *proveConfig) {
+		config.prices = &prices
+	}
+}
+
+// PriceAccounts checks every account of the batches is worth at least nothing at prices, and adds the gross total
+// of their positive balances and the prices to each batch's recorded asset sum, so that the batches are proven with
+// the cross-margin circuit. The recorded net asset sums are otherwise left as they are.
+func PriceAccounts(batches []ProofElements, prices circuit.GoBalance) []ProofElements {
+	priced := make([]ProofElements, len(batches))
+	for i, batch := range batches {
+		for j, account := range batch.Accounts {
+			if account.Balance.AccountCount != nil {
+				panic("cross-margin accounts cannot be counted")
+			}
+			if value := circuit.GoNetValue(account.Balance, prices); value.Sign() < 0 {
+				panic(fmt.Sprintf("account %d of batch %d (user id %x) has a net value of %s at the prices", j, i, account.UserId, value.String()))
+			}
+		}
+		priced[i] = batch
+		if batch.AssetSum != nil {
+			assetSum := *batch.AssetSum
+			assetSum.Gross = circuit.SumGoMarginBalances(batch.Accounts, prices).Gross
+			assetSum.Prices = &prices
+			priced[i].AssetSum = &assetSum
+		}
+		// the recorded root with the asset sum did not commit to the gross total and prices
+		priced[i].MerkleRootWithAssetSumHash = nil
+	}
+	return priced
+}
+
+// sumBalances sums accounts as the circuit proving them into assetSum does: at its prices if it has them.
+func sumBalances(accounts []circuit.GoAccount, assetSum *circuit.GoBalance) circuit.GoBalance {
+	if assetSum != nil && assetSum.Prices != nil {
+		return circuit.SumGoMarginBalances(accounts, *assetSum.Prices)
+	}
+	sum := circuit.SumGoAccountBalances(accounts)
+	if assetSum != nil {
+		// a blinding factor is chosen rather than summed
+		sum.Blinding = assetSum.Blinding
+	}
+	return sum
+}
+
+func newCrossMarginWitness(elements ProofElements, context *EpochContext) witness.Witness {
+	witnessInput := circuit.CrossMarginCircuit{
+		Accounts:                   circuit.ConvertGoAccountsToAccounts(elements.Accounts),
+		AccountGross:               circuit.ConvertGoAccountGross(elements.Accounts),
+		AssetSum:                   circuit.ConvertGoBalanceToBalance(*elements.AssetSum),
+		GrossSum:                   circuit.ConvertGoBalanceToBalance(*elements.AssetSum.Gross),
+		MerkleRoot:                 elements.MerkleRoot,
+		MerkleRootWithAssetSumHash: elements.MerkleRootWithAssetSumHash,
+		Context:                    context.witness(),
+		Prices:                     circuit.ConvertGoBalanceToBalance(*elements.AssetSum.Prices),
+	}
+	witness, err := frontend.NewWitness(&witnessInput, ecc.BN254.ScalarField())
+	if err != nil {
+		panic(err)
+	}
+	return witness
+}
+
+// verifyCrossMarginSum checks a cross-margin top level proof publishes the gross total along with the net one, and
+// the prices its SNARK proves.
+func verifyCrossMarginSum(topLayerProof CompletedProof) {
+	if topLayerProof.AssetSum.Gross == nil || topLayerProof.AssetSum.Prices == nil {
+		panic("top layer proof does not publish the gross total and prices of its cross-margin asset sum")
+	}
+	if topLayerProof.Prices == nil || !topLayerProof.Prices.Equals(*topLayerProof.AssetSum.Prices) {
+		panic("top layer proof publishes other prices than its asset sum was proven at")
+	}
+}
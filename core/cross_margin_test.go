@@ -0,0 +1,56 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+var testPrices = circuit.GoBalance{Bitcoin: *big.NewInt(30), Ethereum: *big.NewInt(1)}
+
+// marginBatch is a generated batch whose first account borrows all the Ethereum its Bitcoin is worth at testPrices.
+func marginBatch(count int, seed int) ProofElements {
+	batch := generatedBatch(count, seed)
+	borrower := &batch.Accounts[0].Balance
+	borrower.Ethereum.Neg(circuit.GoNetValue(circuit.GoBalance{Bitcoin: borrower.Bitcoin}, testPrices))
+	assetSum := circuit.SumGoMarginBalances(batch.Accounts, testPrices)
+	assetSum.Gross, assetSum.Prices = nil, nil
+	batch.AssetSum = &assetSum
+	batch.MerkleRoot, batch.MerkleRootWithAssetSumHash = nil, nil
+	return batch
+}
+
+func checkCrossMargin(assert *test.Assert, ledger []ProofElements, bottomLevelProofs []CompletedProof, top CompletedProof, statement PublishedStatement) {
+	for _, proof := range append(bottomLevelProofs, top) {
+		assert.True(proof.Prices.Equals(testPrices))
+	}
+	borrowed := new(big.Int).Neg(new(big.Int).Add(&ledger[0].Accounts[0].Balance.Ethereum, &ledger[1].Accounts[0].Balance.Ethereum))
+	assert.Equal(0, new(big.Int).Sub(&top.AssetSum.Gross.Ethereum, &top.AssetSum.Ethereum).Cmp(borrowed), "the gross total should leave out what is borrowed")
+	assert.Equal(0, top.AssetSum.Gross.Bitcoin.Cmp(&top.AssetSum.Bitcoin))
+
+	// the borrower's leaf is their signed balance, proven at the published prices
+	borrower := ledger[1].Accounts[0]
+	otherPrices := circuit.GoBalance{Bitcoin: *big.NewInt(31), Ethereum: *big.NewInt(1)}
+	statement.AssetSum.Prices = &otherPrices
+	assert.False(VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(borrower), publicDir, WithPublishedStatement(statement)).Passed())
+	top.Prices = &otherPrices
+	assert.Panics(func() { verifyTopLayerProofMatchesAssetSum(top) }, "the prices proven must be those of the asset sum")
+}
+
+func TestCrossMarginRejectsNegativeNetValue(t *testing.T) {
+	assert := test.NewAssert(t)
+	ledger := []ProofElements{marginBatch(4, 31), marginBatch(3, 32)}
+	context := NewEpochContext(1, time.Now(), "BitGo")
+
+	// accounts worth less than nothing cannot be proven
+	ledger[0].Accounts[0].Balance.Ethereum.Sub(&ledger[0].Accounts[0].Balance.Ethereum, big.NewInt(1))
+	ledger[0].AssetSum.Ethereum.Sub(&ledger[0].AssetSum.Ethereum, big.NewInt(1))
+	writeSecretData(t, ledger...)
+	assert.Equal([]IssueKind{IssueNetValue}, issueKinds(ValidateLedger(secretDir, WithCrossMargin(testPrices))))
+	assert.Panics(func() { Prove(2, context, nil, WithCrossMargin(testPrices)) })
+	assert.False(DryRun(2, context, WithCrossMargin(testPrices)).Passed())
+	assert.Panics(func() { Prove(2, context, nil, WithCrossMargin(testPrices), WithAccountCount()) })
+}
@@ -0,0 +1,259 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+const leafIndexName = "leaf_index.json"
+
+// LeafIndex maps hex encoded account leaf hashes to the index of the bottom level proof that contains them.
+// It is written alongside the public proofs so that a user's bottom level proof can be found without
+// reading every bottom level proof file.
+type LeafIndex map[string]int
+
+func newLeafIndex(bottomLevelProofs []CompletedProof) LeafIndex {
+	index := make(LeafIndex)
+	for i, proof := range bottomLevelProofs {
+		for _, leaf := range proof.AccountLeaves {
+			index[hex.EncodeToString(leaf)] = i
+		}
+	}
+	return index
+}
+
+func writeLeafIndex(proofsDir string, bottomLevelProofs []CompletedProof) {
+	err := writeJson(filepath.Join(proofsDir, leafIndexName), newLeafIndex(bottomLevelProofs))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ProofPathFiles are the files holding the proofs on the path from an account leaf to the top level proof.
+type ProofPathFiles struct {
+	Bottom string
+	Mid    string
+	Top    string
+}
+
+func proofContainsLeaf(filePath string, leaf circuit.Hash) (bool, error) {
+	proof, err := readCompletedProof(filePath)
+	if err != nil {
+		return false, err
+	}
+	return findInclusionInProofs(leaf, []CompletedProof{proof}) != -1, nil
+}
+
+// findProofContainingLeaf returns the first of candidates whose account leaves include leaf.
+func findProofContainingLeaf(candidates []string, leaf circuit.Hash) (string, error) {
+	for _, candidate := range candidates {
+		found, err := proofContainsLeaf(candidate, leaf)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no proof contains leaf %x", leaf)
+}
+
+// findBottomLevelProof looks accountHash up in the leaf index of proofsDir, falling back to scanning every bottom
+// level proof when no index was published or when it is stale or wrong about the leaf.
+func findBottomLevelProof(proofsDir string, accountHash circuit.Hash) (string, error) {
+	var index LeafIndex
+	if err := readJson(filepath.Join(proofsDir, leafIndexName), &index); err == nil {
+		if i, ok := index[hex.EncodeToString(accountHash)]; ok {
+			// the index only narrows the search, the proof itself must still contain the leaf
+			indexed := resolveProofFile(proofFilePath(filepath.Join(proofsDir, bottomLevelProofName), i))
+			if found, err := proofContainsLeaf(indexed, accountHash); err == nil && found {
+				return indexed, nil
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	candidates, err := globProofFiles(proofsDir, bottomLevelProofName)
+	if err != nil {
+		return "", err
+	}
+	return findProofContainingLeaf(candidates, accountHash)
+}
+
+// FindProofPath locates the bottom level proof containing accountHash in proofsDir, then follows the
+// MerkleRootWithAssetSumHash links up through the mid level proofs to the top level proof.
+func FindProofPath(accountHash circuit.Hash, proofsDir string) (files ProofPathFiles, err error) {
+	files.Bottom, err = findBottomLevelProof(proofsDir, accountHash)
+	if err != nil {
+		return files, err
+	}
+	bottomLevelProof, err := readCompletedProof(files.Bottom)
+	if err != nil {
+		return files, err
+	}
+
+	midCandidates, err := globProofFiles(proofsDir, midLevelProofName)
+	if err != nil {
+		return files, err
+	}
+	files.Mid, err = findProofContainingLeaf(midCandidates, bottomLevelProof.MerkleRootWithAssetSumHash)
+	if err != nil {
+		return files, fmt.Errorf("finding mid level proof for %s: %w", files.Bottom, err)
+	}
+	midLevelProof, err := readCompletedProof(files.Mid)
+	if err != nil {
+		return files, err
+	}
+
+	topCandidates, err := globProofFiles(proofsDir, topLevelProofName)
+	if err != nil {
+		return files, err
+	}
+	files.Top, err = findProofContainingLeaf(topCandidates, midLevelProof.MerkleRootWithAssetSumHash)
+	if err != nil {
+		return files, fmt.Errorf("finding top level proof for %s: %w", files.Mid, err)
+	}
+	return files, nil
+}
+
+// ProofPathIndex maps every account leaf of the public proofs in a directory to the files of its proof path,
+// so that serving many users does not scan the directory for each of them.
+type ProofPathIndex struct {
+	proofsDir string
+	paths     map[string]ProofPathFiles
+}
+
+// proofsByLeaf reads the proofs whose names start with name in proofsDir and maps each of their account leaves to
+// the first file holding it, as FindProofPath finds it.
+func proofsByLeaf(proofsDir string, name string) (map[string]string, []CompletedProof, []string, error) {
+	files, err := globProofFiles(proofsDir, name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	byLeaf := make(map[string]string)
+	proofs := make([]CompletedProof, len(files))
+	for i, file := range files {
+		proofs[i], err = readCompletedProof(file)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, leaf := range proofs[i].AccountLeaves {
+			if _, ok := byLeaf[hex.EncodeToString(leaf)]; !ok {
+				byLeaf[hex.EncodeToString(leaf)] = file
+			}
+		}
+	}
+	return byLeaf, proofs, files, nil
+}
+
+// IndexProofPaths reads every proof in proofsDir once and indexes the proof path of each account leaf, following
+// the MerkleRootWithAssetSumHash links as FindProofPath does. Leaves whose path does not reach a top level proof
+// are left out.
+func IndexProofPaths(proofsDir string) (ProofPathIndex, error) {
+	index := ProofPathIndex{proofsDir: proofsDir, paths: make(map[string]ProofPathFiles)}
+	_, bottomLevelProofs, bottomFiles, err := proofsByLeaf(proofsDir, bottomLevelProofName)
+	if err != nil {
+		return index, err
+	}
+	midByLeaf, midLevelProofs, midFiles, err := proofsByLeaf(proofsDir, midLevelProofName)
+	if err != nil {
+		return index, err
+	}
+	topByLeaf, _, _, err := proofsByLeaf(proofsDir, topLevelProofName)
+	if err != nil {
+		return index, err
+	}
+	topOfMid := make(map[string]string)
+	for i, midLevelProof := range midLevelProofs {
+		if top, ok := topByLeaf[hex.EncodeToString(midLevelProof.MerkleRootWithAssetSumHash)]; ok {
+			topOfMid[midFiles[i]] = top
+		}
+	}
+	for i, bottomLevelProof := range bottomLevelProofs {
+		mid, ok := midByLeaf[hex.EncodeToString(bottomLevelProof.MerkleRootWithAssetSumHash)]
+		if !ok {
+			continue
+		}
+		top, ok := topOfMid[mid]
+		if !ok {
+			continue
+		}
+		for _, leaf := range bottomLevelProof.AccountLeaves {
+			if _, ok := index.paths[hex.EncodeToString(leaf)]; !ok {
+				index.paths[hex.EncodeToString(leaf)] = ProofPathFiles{Bottom: bottomFiles[i], Mid: mid, Top: top}
+			}
+		}
+	}
+	return index, nil
+}
+
+// Find returns the files of the proof path of accountHash.
+func (index ProofPathIndex) Find(accountHash circuit.Hash) (ProofPathFiles, error) {
+	files, ok := index.paths[hex.EncodeToString(accountHash)]
+	if !ok {
+		return files, fmt.Errorf("no proof in %s contains leaf %x", index.proofsDir, accountHash)
+	}
+	return files, nil
+}
+
+// VerifyProofPath verifies the indexed proof path of accountHash as VerifyProofPathInDir does.
+func (index ProofPathIndex) VerifyProofPath(accountHash circuit.Hash, options ...VerifyOption) VerificationReport {
+	return verifyDiscoveredProofPath(accountHash, index.proofsDir, index.Find, options)
+}
+
+// ReadProofPath reads the proofs of files, failing rather than panicking on a missing or malformed one.
+func ReadProofPath(files ProofPathFiles) (bottomLevelProof, midLevelProof, topLevelProof CompletedProof, err error) {
+	if bottomLevelProof, err = readCompletedProof(files.Bottom); err != nil {
+		return
+	}
+	if midLevelProof, err = readCompletedProof(files.Mid); err != nil {
+		return
+	}
+	topLevelProof, err = readCompletedProof(files.Top)
+	return
+}
+
+// ReadTopLevelProof reads the top level proof in proofsDir.
+func ReadTopLevelProof(proofsDir string) (CompletedProof, error) {
+	return readCompletedProof(resolveProofFile(proofFilePath(filepath.Join(proofsDir, topLevelProofName), 0)))
+}
+
+// VerifyProofPathInDir finds the proofs on the path from accountHash to the top level proof in proofsDir
+// and verifies that path as VerifyProofPath does.
+func VerifyProofPathInDir(accountHash circuit.Hash, proofsDir string, options ...VerifyOption) VerificationReport {
+	find := func(accountHash circuit.Hash) (ProofPathFiles, error) {
+		return FindProofPath(accountHash, proofsDir)
+	}
+	return verifyDiscoveredProofPath(accountHash, proofsDir, find, options)
+}
+
+// verifyDiscoveredProofPath verifies the proof path of accountHash in proofsDir that find discovers.
+func verifyDiscoveredProofPath(accountHash circuit.Hash, proofsDir string, find func(circuit.Hash) (ProofPathFiles, error), options []VerifyOption) VerificationReport {
+	report := newVerificationReport()
+	var files ProofPathFiles
+	var bottomLevelProof, midLevelProof, topLevelProof CompletedProof
+	found := report.runCheck(CheckProofDiscovery, proofsDir, func() {
+		var err error
+		files, err = find(accountHash)
+		if err != nil {
+			panic(err)
+		}
+		// a malformed proof on the path fails discovery rather than crashing the verifier
+		bottomLevelProof, midLevelProof, topLevelProof, err = ReadProofPath(files)
+		if err != nil {
+			panic(err)
+		}
+	})
+	if !found {
+		return report.finish()
+	}
+
+	config := newVerifyConfig(append(options, WithProofPathFiles(files.Bottom, files.Mid, files.Top)))
+	report.verifyProofPath(config, accountHash, bottomLevelProof, midLevelProof, topLevelProof)
+	return report.finish()
+}
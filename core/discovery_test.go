@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark/test"
+)
+
+func TestFindProofPath(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+	copyTestProofs(t, dir)
+
+	// without an index every bottom level proof is scanned
+	files, err := FindProofPath(proofLower1.AccountLeaves[2], dir)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dir, "test_proof_1.json"), files.Bottom)
+	assert.Equal(filepath.Join(dir, "test_mid_level_proof_0.json"), files.Mid)
+	assert.Equal(filepath.Join(dir, "test_top_level_proof_0.json"), files.Top)
+
+	// with an index the bottom level proof is looked up
+	writeLeafIndex(dir, []CompletedProof{proofLower0, proofLower1})
+	files, err = FindProofPath(proofLower0.AccountLeaves[5], dir)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dir, "test_proof_0.json"), files.Bottom)
+
+	_, err = FindProofPath([]byte{0x12, 0x34}, dir)
+	assert.Error(err, "should not find an account that is not in the proofs")
+}
+
+func TestFindProofPathDoesNotTrustIndex(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+	copyTestProofs(t, dir)
+
+	// an index pointing at the wrong bottom level proof is not followed, every bottom level proof is scanned instead
+	index := newLeafIndex([]CompletedProof{proofLower1, proofLower0})
+	assert.NoError(writeJson(filepath.Join(dir, leafIndexName), index))
+	files, err := FindProofPath(proofLower0.AccountLeaves[0], dir)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dir, "test_proof_0.json"), files.Bottom)
+
+	// and so is a stale index, missing the leaf or pointing past the last proof
+	stale := newLeafIndex([]CompletedProof{proofLower0})
+	stale[hex.EncodeToString(proofLower0.AccountLeaves[1])] = 7
+	assert.NoError(writeJson(filepath.Join(dir, leafIndexName), stale))
+	for _, leaf := range [][]byte{proofLower1.AccountLeaves[0], proofLower0.AccountLeaves[1]} {
+		files, err = FindProofPath(leaf, dir)
+		assert.NoError(err)
+		assert.Equal(filepath.Join(dir, "test_mid_level_proof_0.json"), files.Mid)
+	}
+	_, err = FindProofPath([]byte{0x12, 0x34}, dir)
+	assert.Error(err, "should not find an account that is in neither the index nor the proofs")
+}
+
+func TestVerifyProofPathInDir(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+	copyTestProofs(t, dir)
+	writeLeafIndex(dir, []CompletedProof{proofLower0, proofLower1})
+
+	report := VerifyProofPathInDir(proofLower1.AccountLeaves[0], dir)
+	assert.True(report.Passed())
+	assert.Equal(CheckProofDiscovery, report.Checks[0].Kind)
+
+	report = VerifyProofPathInDir([]byte{0x12, 0x34}, dir)
+	assert.False(report.Passed())
+	assert.Equal(1, len(report.Checks))
+	assert.Equal(CheckProofDiscovery, report.Failures()[0].Kind)
+
+	// a malformed proof fails discovery rather than crashing the verifier
+	assert.NoError(os.WriteFile(filepath.Join(dir, "test_mid_level_proof_0.json"), []byte("{"), 0644))
+	assert.NotPanics(func() { report = VerifyProofPathInDir(proofLower1.AccountLeaves[0], dir) })
+	assert.False(report.Passed())
+	assert.Equal(CheckProofDiscovery, report.Failures()[0].Kind)
+}
+
+func TestIndexProofPaths(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+	copyTestProofs(t, dir)
+
+	index, err := IndexProofPaths(dir)
+	assert.NoError(err)
+	for _, leaf := range [][]byte{proofLower0.AccountLeaves[3], proofLower1.AccountLeaves[0]} {
+		indexed, err := index.Find(leaf)
+		assert.NoError(err)
+		found, err := FindProofPath(leaf, dir)
+		assert.NoError(err)
+		assert.Equal(found, indexed, "the index should hold the path FindProofPath discovers")
+	}
+	_, err = index.Find([]byte{0x12, 0x34})
+	assert.Error(err)
+	assert.True(index.VerifyProofPath(proofLower1.AccountLeaves[0]).Passed())
+	assert.False(index.VerifyProofPath([]byte{0x12, 0x34}).Passed())
+}
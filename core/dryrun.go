@@ -0,0 +1,107 @@
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend/cs"
+)
+
+// randomCommitment stands in for the commitment groth16.Prove computes for the circuit's range checks. Any
+// random challenge checks them, with overwhelming probability, without the proving key.
+func randomCommitment(field *big.Int, _ []*big.Int, outputs []*big.Int) error {
+	challenge, err := rand.Int(rand.Reader, field)
+	if err != nil {
+		return err
+	}
+	outputs[0].Add(challenge, big.NewInt(1)).Mod(outputs[0], field)
+	return nil
+}
+
+// solveWitness checks the witness for elements satisfies the circuit counting accounts as given, bound to the epoch
+// context unless it is nil, without a setup or a proof. The error names the first constraint that is not satisfied.
+func solveWitness(elements ProofElements, context *EpochContext, counting circuit.Counting) {
+	elements = completeProofElements(elements)
+	isSolved(shapeOf(elements, counting, context), newWitness(elements, context))
+}
+
+// isSolved checks witness satisfies the circuit of the given shape.
+func isSolved(shape circuitShape, witness witness.Witness) {
+	if err := compileCircuit(shape).IsSolved(witness,
+		solver.OverrideHint(solver.GetHintID(cs.Bsb22CommitmentComputePlaceholder), randomCommitment)); err != nil {
+		panic(fmt.Errorf("witness of %d accounts does not solve the circuit: %w", shape.accountCount, err))
+	}
+}
+
+// solvedRoots is the part of the proof for elements that the next level's proof takes as an account.
+func solvedRoots(elements ProofElements) CompletedProof {
+	elements = completeProofElements(elements)
+	return CompletedProof{
+		MerkleRoot:                 elements.MerkleRoot,
+		MerkleRootWithAssetSumHash: elements.MerkleRootWithAssetSumHash,
+		AssetSum:                   elements.AssetSum,
+	}
+}
+
+// DryRun checks that every batch of secret data, and the mid and top levels built from them, would prove for the
+// epoch context with the given options, by solving each circuit's constraints rather than proving it. Each proof
+// that Prove would write is one check in the report. The mid and top levels are only checked once every batch
+// solves. Options that only concern the proving keys or reuse have no effect.
+func DryRun(batchCount int, context EpochContext, options ...ProveOption) VerificationReport {
+	config := proveConfig{}
+	for _, option := range options {
+		option(&config)
+	}
+	bottomLevelCounting, upperLevelCounting := config.countings()
+	report := newVerificationReport()
+	ledger := make([]ProofElements, batchCount)
+	read := true
+	for i := range ledger {
+		file := proofFilePath(secretDataPrefix, i)
+		read = report.runCheckIfFails(CheckProofFile, file, func() {
+			if err := readVersionedJson(file, &ledger[i]); err != nil {
+				panic(err)
+			}
+		}) && read
+	}
+	if !read || !report.runCheckIfFails(CheckWitness, secretDir, func() { ledger = config.prepare(ledger) }) {
+		return report.finish()
+	}
+	bottomLevelRoots := make([]CompletedProof, 0, batchCount)
+	for i, elements := range ledger {
+		elements = config.blind(elements)
+		if report.runCheck(CheckWitness, proofFilePath(bottomLevelProofPrefix, i), func() { solveWitness(elements, nil, bottomLevelCounting) }) {
+			bottomLevelRoots = append(bottomLevelRoots, solvedRoots(elements))
+		}
+	}
+	if len(bottomLevelRoots) != batchCount {
+		return report.finish()
+	}
+
+	midLevelRoots := make([]CompletedProof, 0)
+	for i, batch := range batchProofs(bottomLevelRoots, 1024) {
+		var elements ProofElements
+		if report.runCheck(CheckWitness, proofFilePath(midLevelProofPrefix, i), func() {
+			elements = config.blind(nextLevelProofElements(batch))
+			solveWitness(elements, nil, upperLevelCounting)
+		}) {
+			midLevelRoots = append(midLevelRoots, solvedRoots(elements))
+		}
+	}
+	if len(midLevelRoots) != midLevelProofCount(batchCount) {
+		return report.finish()
+	}
+
+	report.runCheck(CheckWitness, proofFilePath(topLevelProofPrefix, 0), func() {
+		if config.reserves != nil {
+			solveHiddenTotalWitness(nextLevelProofElements(midLevelRoots), context, *config.reserves)
+		} else {
+			solveWitness(nextLevelProofElements(midLevelRoots), &context, upperLevelCounting)
+		}
+	})
+	return report.finish()
+}
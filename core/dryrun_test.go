@@ -0,0 +1,101 @@
+package core
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+// writeSecretData writes the batches as 'out/secret/' in a temporary working directory.
+func writeSecretData(t *testing.T, batches ...ProofElements) {
+	dir := t.TempDir()
+	for _, sub := range []string{secretDir, publicDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			panic(err)
+		}
+	})
+	for i, elements := range batches {
+		if err := writeJson(proofFilePath(secretDataPrefix, i), elements); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func generatedBatch(count int, seed int) ProofElements {
+	accounts, assetSum, merkleRoot, merkleRootWithAssetSumHash := circuit.GenerateTestData(count, seed)
+	return ProofElements{
+		Version:                    SchemaVersion,
+		Accounts:                   accounts,
+		AssetSum:                   &assetSum,
+		MerkleRoot:                 merkleRoot,
+		MerkleRootWithAssetSumHash: merkleRootWithAssetSumHash,
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	assert := test.NewAssert(t)
+	writeSecretData(t, generatedBatch(8, 11), generatedBatch(8, 12))
+
+	report := DryRun(2, NewEpochContext(1, time.Now(), "BitGo"))
+	assert.True(report.Passed(), report.Failures())
+	witnesses := make([]string, 0)
+	for _, check := range report.Checks {
+		if check.Kind == CheckWitness {
+			witnesses = append(witnesses, check.File)
+		}
+	}
+	assert.Equal([]string{
+		proofFilePath(bottomLevelProofPrefix, 0), proofFilePath(bottomLevelProofPrefix, 1),
+		proofFilePath(midLevelProofPrefix, 0), proofFilePath(topLevelProofPrefix, 0),
+	}, witnesses)
+	_, err := os.Stat(proofFilePath(bottomLevelProofPrefix, 0))
+	assert.True(os.IsNotExist(err), "a dry run should not write proofs")
+}
+
+func TestDryRunReportsFailingConstraint(t *testing.T) {
+	assert := test.NewAssert(t)
+	wrongSum := generatedBatch(8, 12)
+	wrongSum.AssetSum.Ethereum.Add(&wrongSum.AssetSum.Ethereum, big.NewInt(1))
+	wrongSum.MerkleRootWithAssetSumHash = nil
+	writeSecretData(t, generatedBatch(8, 11), wrongSum)
+
+	report := DryRun(2, NewEpochContext(1, time.Now(), "BitGo"))
+	assert.False(report.Passed())
+	failures := report.Failures()
+	assert.Equal(1, len(failures), "the upper levels should not be checked once a batch fails")
+	assert.Equal(CheckWitness, failures[0].Kind)
+	assert.Equal(proofFilePath(bottomLevelProofPrefix, 1), failures[0].File)
+	assert.True(strings.Contains(failures[0].Error, "constraint #"), failures[0].Error)
+}
+
+func TestDryRunReportsOverflowingBalance(t *testing.T) {
+	assert := test.NewAssert(t)
+	overflowing := generatedBatch(8, 12)
+	overflowing.Accounts[2].Balance.Bitcoin.Lsh(big.NewInt(1), 64)
+	overflowing.MerkleRoot, overflowing.MerkleRootWithAssetSumHash = nil, nil
+	sum := circuit.SumGoAccountBalances(overflowing.Accounts)
+	overflowing.AssetSum = &sum
+	writeSecretData(t, overflowing)
+
+	report := DryRun(1, NewEpochContext(1, time.Now(), "BitGo"))
+	assert.False(report.Passed())
+	assert.Equal(proofFilePath(bottomLevelProofPrefix, 0), report.Failures()[0].File)
+}
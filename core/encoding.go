@@ -0,0 +1,350 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/fxamacker/cbor/v2"
+)
+
+type ProofFormat string
+
+const (
+	ProofFormatJson   ProofFormat = "json"
+	ProofFormatBinary ProofFormat = "binary"
+
+	jsonExtension   = ".json"
+	binaryExtension = ".bin"
+
+	// binary proof files share the VKs they reference through this file in the same directory
+	verifyingKeysName = "verifying_keys.bin"
+)
+
+var (
+	binaryProofMagic = []byte("BGPF")
+	binaryKeysMagic  = []byte("BGVK")
+)
+
+var cborEncoding = func() cbor.EncMode {
+	mode, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+// binaryProof is the CBOR encoding of a CompletedProof. The SNARK is stored as raw bytes rather than
+// base64, and the VK is replaced by the SHA-256 hash of its raw bytes, resolved from the verifying keys file.
+type binaryProof struct {
+	Version                    int                   `cbor:"7,keyasint,omitempty"`
+	CircuitId                  string                `cbor:"8,keyasint,omitempty"`
+	Proof                      []byte                `cbor:"1,keyasint"`
+	VKHash                     []byte                `cbor:"2,keyasint"`
+	AccountLeaves              []AccountLeaf         `cbor:"3,keyasint"`
+	MerkleRoot                 []byte                `cbor:"4,keyasint"`
+	MerkleRootWithAssetSumHash []byte                `cbor:"5,keyasint"`
+	AssetSum                   *circuit.GoBalance    `cbor:"6,keyasint,omitempty"`
+	Epoch                      uint64                `cbor:"9,keyasint,omitempty"`
+	SnapshotTimestamp          uint64                `cbor:"10,keyasint,omitempty"`
+	IssuerIdHash               []byte                `cbor:"11,keyasint,omitempty"`
+	AssetSumCommitment         *circuit.GoCommitment `cbor:"12,keyasint,omitempty"`
+	Reserves                   *circuit.GoBalance    `cbor:"13,keyasint,omitempty"`
+	Prices                     *circuit.GoBalance    `cbor:"14,keyasint,omitempty"`
+}
+
+// verifyingKeys maps the hex encoded SHA-256 hash of a raw VK to the raw VK.
+type verifyingKeys map[string][]byte
+
+func encodeBinaryProof(proof CompletedProof, keys verifyingKeys) ([]byte, error) {
+	rawProof, err := base64.StdEncoding.DecodeString(proof.Proof)
+	if err != nil {
+		return nil, err
+	}
+	rawVK, err := base64.StdEncoding.DecodeString(proof.VK)
+	if err != nil {
+		return nil, err
+	}
+	vkHash := sha256.Sum256(rawVK)
+	keys[hex.EncodeToString(vkHash[:])] = rawVK
+
+	encoded, err := cborEncoding.Marshal(binaryProof{
+		Version:                    proof.Version,
+		CircuitId:                  proof.CircuitId,
+		Proof:                      rawProof,
+		VKHash:                     vkHash[:],
+		AccountLeaves:              proof.AccountLeaves,
+		MerkleRoot:                 proof.MerkleRoot,
+		MerkleRootWithAssetSumHash: proof.MerkleRootWithAssetSumHash,
+		AssetSum:                   proof.AssetSum,
+		Epoch:                      proof.Epoch,
+		SnapshotTimestamp:          proof.SnapshotTimestamp,
+		IssuerIdHash:               proof.IssuerIdHash,
+		AssetSumCommitment:         proof.AssetSumCommitment,
+		Reserves:                   proof.Reserves,
+		Prices:                     proof.Prices,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, binaryProofMagic...), encoded...), nil
+}
+
+func decodeBinaryProof(data []byte, keys verifyingKeys) (proof CompletedProof, err error) {
+	var decoded binaryProof
+	err = cbor.Unmarshal(bytes.TrimPrefix(data, binaryProofMagic), &decoded)
+	if err != nil {
+		return proof, err
+	}
+	rawVK, ok := keys[hex.EncodeToString(decoded.VKHash)]
+	if !ok {
+		return proof, fmt.Errorf("verifying key %x not found in %s", decoded.VKHash, verifyingKeysName)
+	}
+	if vkHash := sha256.Sum256(rawVK); !bytes.Equal(vkHash[:], decoded.VKHash) {
+		return proof, fmt.Errorf("verifying key stored under %x in %s hashes to %x", decoded.VKHash, verifyingKeysName, vkHash)
+	}
+	proof = CompletedProof{
+		Version:                    decoded.Version,
+		CircuitId:                  decoded.CircuitId,
+		Proof:                      base64.StdEncoding.EncodeToString(decoded.Proof),
+		VK:                         base64.StdEncoding.EncodeToString(rawVK),
+		AccountLeaves:              decoded.AccountLeaves,
+		MerkleRoot:                 decoded.MerkleRoot,
+		MerkleRootWithAssetSumHash: decoded.MerkleRootWithAssetSumHash,
+		AssetSum:                   decoded.AssetSum,
+		AssetSumCommitment:         decoded.AssetSumCommitment,
+		Reserves:                   decoded.Reserves,
+		Prices:                     decoded.Prices,
+		EpochContext: EpochContext{
+			Epoch:             decoded.Epoch,
+			SnapshotTimestamp: decoded.SnapshotTimestamp,
+			IssuerIdHash:      decoded.IssuerIdHash,
+		},
+	}
+	return proof, upgradeSchema(decoded.Version, &proof)
+}
+
+func writeVerifyingKeys(filePath string, keys verifyingKeys) error {
+	encoded, err := cborEncoding.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(append([]byte{}, binaryKeysMagic...), encoded...), 0o644)
+}
+
+// verifyingKeysFile holds the keys read from a verifying keys file, as long as the file is unchanged.
+type verifyingKeysFile struct {
+	keys    verifyingKeys
+	size    int64
+	modTime time.Time
+}
+
+// verifying keys files are read once per directory, as every binary proof in it references them, and again
+// whenever they are rewritten
+var cachedVerifyingKeysFiles = struct {
+	sync.Mutex
+	files map[string]verifyingKeysFile
+}{files: make(map[string]verifyingKeysFile)}
+
+func readVerifyingKeys(filePath string) (verifyingKeys, error) {
+	filePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	cachedVerifyingKeysFiles.Lock()
+	defer cachedVerifyingKeysFiles.Unlock()
+	if cached, ok := cachedVerifyingKeysFiles.files[filePath]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.keys, nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, binaryKeysMagic) {
+		return nil, fmt.Errorf("%s is not a verifying keys file", filePath)
+	}
+	var keys verifyingKeys
+	err = cbor.Unmarshal(data[len(binaryKeysMagic):], &keys)
+	if err != nil {
+		return nil, err
+	}
+	cachedVerifyingKeysFiles.files[filePath] = verifyingKeysFile{keys: keys, size: info.Size(), modTime: info.ModTime()}
+	return keys, nil
+}
+
+func detectProofFormat(data []byte) ProofFormat {
+	if bytes.HasPrefix(data, binaryProofMagic) {
+		return ProofFormatBinary
+	}
+	return ProofFormatJson
+}
+
+// readCompletedProof reads a proof file in either format, detected from its contents.
+func readCompletedProof(filePath string) (proof CompletedProof, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return proof, err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			panic(err)
+		}
+	}(file)
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return proof, err
+	}
+	if detectProofFormat(data) == ProofFormatJson {
+		return proof, decodeVersionedJson(data, &proof)
+	}
+	keys, err := readVerifyingKeys(filepath.Join(filepath.Dir(filePath), verifyingKeysName))
+	if err != nil {
+		return proof, err
+	}
+	return decodeBinaryProof(data, keys)
+}
+
+// resolveProofFile returns filePath, or the same file with the binary extension if only that exists.
+func resolveProofFile(filePath string) string {
+	if _, err := os.Stat(filePath); err == nil || !strings.HasSuffix(filePath, jsonExtension) {
+		return filePath
+	}
+	binaryPath := strings.TrimSuffix(filePath, jsonExtension) + binaryExtension
+	if _, err := os.Stat(binaryPath); err == nil {
+		return binaryPath
+	}
+	return filePath
+}
+
+// globProofFiles lists the proof files in dir whose names start with name, in either format. A proof written in both
+// formats is listed once, in the format resolveProofFile picks.
+func globProofFiles(dir string, name string) ([]string, error) {
+	files := make([]string, 0)
+	listed := make(map[string]bool)
+	for _, extension := range []string{jsonExtension, binaryExtension} {
+		matches, err := filepath.Glob(filepath.Join(dir, name+"*"+extension))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			base := strings.TrimSuffix(match, extension)
+			if !listed[base] {
+				listed[base] = true
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+// proofFileWriter writes proof files to outDir in either format, collecting the VKs of binary proofs
+// so that finish can write each one once to the verifying keys file.
+type proofFileWriter struct {
+	outDir string
+	keys   verifyingKeys
+}
+
+func newProofFileWriter(outDir string) *proofFileWriter {
+	return &proofFileWriter{outDir: outDir, keys: make(verifyingKeys)}
+}
+
+// write writes proof to outDir under base, the file name without extension, and returns the path written.
+func (writer *proofFileWriter) write(base string, format ProofFormat, proof CompletedProof) (outFile string, err error) {
+	if format == ProofFormatBinary {
+		outFile = filepath.Join(writer.outDir, base+binaryExtension)
+		var encoded []byte
+		encoded, err = encodeBinaryProof(proof, writer.keys)
+		if err == nil {
+			err = os.WriteFile(outFile, encoded, 0o644)
+		}
+	} else {
+		outFile = filepath.Join(writer.outDir, base+jsonExtension)
+		err = writeJson(outFile, proof)
+	}
+	if err != nil {
+		return outFile, fmt.Errorf("writing %s: %w", outFile, err)
+	}
+	return outFile, nil
+}
+
+// finish writes the verifying keys file if any binary proofs were written and returns its path.
+func (writer *proofFileWriter) finish() (string, error) {
+	if len(writer.keys) == 0 {
+		return "", nil
+	}
+	keysFile := filepath.Join(writer.outDir, verifyingKeysName)
+	return keysFile, writeVerifyingKeys(keysFile, writer.keys)
+}
+
+// ConvertProofFiles converts every bottom, mid and top level proof file in inDir to format and writes
+// them to outDir under the same names with the format's extension. Binary proofs reference their VK
+// by hash, and each distinct VK is written once to the verifying keys file in outDir. The other files
+// published with the proofs, such as the statement and leaf index, are copied unchanged.
+func ConvertProofFiles(inDir string, outDir string, format ProofFormat) (written []string, err error) {
+	if format != ProofFormatJson && format != ProofFormatBinary {
+		return nil, fmt.Errorf("unknown proof format %q", format)
+	}
+	writer := newProofFileWriter(outDir)
+	for _, name := range []string{bottomLevelProofName, midLevelProofName, topLevelProofName} {
+		files, err := globProofFiles(inDir, name)
+		if err != nil {
+			return written, err
+		}
+		for _, file := range files {
+			proof, err := readCompletedProof(file)
+			if err != nil {
+				return written, fmt.Errorf("reading %s: %w", file, err)
+			}
+			outFile, err := writer.write(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), format, proof)
+			if err != nil {
+				return written, err
+			}
+			written = append(written, outFile)
+		}
+	}
+	entries, err := os.ReadDir(inDir)
+	if err != nil {
+		return written, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == verifyingKeysName || isProofFileName(entry.Name()) {
+			continue
+		}
+		outFile := filepath.Join(outDir, entry.Name())
+		if err := copyFile(filepath.Join(inDir, entry.Name()), outFile); err != nil {
+			return written, err
+		}
+		written = append(written, outFile)
+	}
+	keysFile, err := writer.finish()
+	if err != nil {
+		return written, err
+	}
+	if keysFile != "" {
+		written = append(written, keysFile)
+	}
+	return written, nil
+}
+
+func copyFile(inFile string, outFile string) error {
+	data, err := os.ReadFile(inFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outFile, data, 0o644)
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/consensys/gnark/test"
+)
+
+func TestConvertProofFilesRoundTrip(t *testing.T) {
+	assert := test.NewAssert(t)
+	jsonDir, binaryDir, roundTripDir := t.TempDir(), t.TempDir(), t.TempDir()
+	copyTestProofs(t, jsonDir)
+
+	written, err := ConvertProofFiles(jsonDir, binaryDir, ProofFormatBinary)
+	assert.NoError(err)
+	assert.Equal(5, len(written), "four proofs and the verifying keys file")
+	keys, err := readVerifyingKeys(filepath.Join(binaryDir, verifyingKeysName))
+	assert.NoError(err)
+	assert.Equal(3, len(keys), "the two bottom level proofs share a VK")
+
+	for _, name := range []string{"test_proof_0", "test_mid_level_proof_0", "test_top_level_proof_0"} {
+		original := ReadDataFromFile[CompletedProof](filepath.Join(jsonDir, name+jsonExtension))
+		converted := ReadDataFromFile[CompletedProof](filepath.Join(binaryDir, name+jsonExtension))
+		assert.Equal(original, converted, "binary proofs should be found and decoded in place of JSON proofs")
+
+		jsonInfo, err := os.Stat(filepath.Join(jsonDir, name+jsonExtension))
+		assert.NoError(err)
+		binaryInfo, err := os.Stat(filepath.Join(binaryDir, name+binaryExtension))
+		assert.NoError(err)
+		assert.Less(binaryInfo.Size(), jsonInfo.Size())
+	}
+
+	_, err = ConvertProofFiles(binaryDir, roundTripDir, ProofFormatJson)
+	assert.NoError(err)
+	for _, name := range []string{"test_proof_1.json", "test_top_level_proof_0.json"} {
+		original := ReadDataFromFile[CompletedProof](filepath.Join(jsonDir, name))
+		roundTrip := ReadDataFromFile[CompletedProof](filepath.Join(roundTripDir, name))
+		assert.Equal(original, roundTrip)
+	}
+}
+
+func TestVerifyProofPathInBinaryDir(t *testing.T) {
+	assert := test.NewAssert(t)
+	jsonDir, binaryDir := t.TempDir(), t.TempDir()
+	copyTestProofs(t, jsonDir)
+	_, err := ConvertProofFiles(jsonDir, binaryDir, ProofFormatBinary)
+	assert.NoError(err)
+
+	report := VerifyProofPathInDir(proofLower1.AccountLeaves[3], binaryDir)
+	assert.True(report.Passed())
+	assert.Equal(filepath.Join(binaryDir, "test_proof_1.bin"), report.Checks[1].File)
+}
+
+func TestReadBinaryProofWithoutKeys(t *testing.T) {
+	assert := test.NewAssert(t)
+	jsonDir, binaryDir := t.TempDir(), t.TempDir()
+	copyTestProofs(t, jsonDir)
+	_, err := ConvertProofFiles(jsonDir, binaryDir, ProofFormatBinary)
+	assert.NoError(err)
+
+	data, err := os.ReadFile(filepath.Join(binaryDir, "test_proof_0.bin"))
+	assert.NoError(err)
+	_, err = decodeBinaryProof(data, verifyingKeys{})
+	assert.Error(err, "should fail when the referenced VK is missing")
+}
+
+func TestBinaryProofKeepsEpochContext(t *testing.T) {
+	assert := test.NewAssert(t)
+	proof := proofTop
+	proof.CircuitId = circuitIdV3
+	proof.Version = SchemaVersion
+	proof.EpochContext = NewEpochContext(9, time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC), "BitGo")
+
+	keys := make(verifyingKeys)
+	encoded, err := encodeBinaryProof(proof, keys)
+	assert.NoError(err)
+	decoded, err := decodeBinaryProof(encoded, keys)
+	assert.NoError(err)
+	assert.Equal(proof, decoded)
+}
+
+func TestConvertProofFilesCopiesPublishedFiles(t *testing.T) {
+	assert := test.NewAssert(t)
+	jsonDir, binaryDir := t.TempDir(), t.TempDir()
+	copyTestProofs(t, jsonDir)
+	writeLeafIndex(jsonDir, []CompletedProof{proofLower0, proofLower1})
+	assert.NoError(WriteStatementToFile(filepath.Join(jsonDir, statementName), NewPublishedStatement(0, proofTop)))
+
+	_, err := ConvertProofFiles(jsonDir, binaryDir, ProofFormatBinary)
+	assert.NoError(err)
+	for _, name := range []string{leafIndexName, statementName} {
+		original, err := os.ReadFile(filepath.Join(jsonDir, name))
+		assert.NoError(err)
+		copied, err := os.ReadFile(filepath.Join(binaryDir, name))
+		assert.NoError(err)
+		assert.Equal(original, copied)
+	}
+}
+
+func TestGlobProofFilesListsEachProofOnce(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir, binaryDir := t.TempDir(), t.TempDir()
+	copyTestProofs(t, dir)
+	_, err := ConvertProofFiles(dir, binaryDir, ProofFormatBinary)
+	assert.NoError(err)
+	// the second proof is also written in binary, and a third one only in binary
+	copies := map[string]string{"test_proof_1.bin": "test_proof_1.bin", "test_proof_2.bin": "test_proof_1.bin", verifyingKeysName: verifyingKeysName}
+	for name, original := range copies {
+		b, err := os.ReadFile(filepath.Join(binaryDir, original))
+		assert.NoError(err)
+		assert.NoError(os.WriteFile(filepath.Join(dir, name), b, 0o644))
+	}
+
+	files, err := globProofFiles(dir, bottomLevelProofName)
+	assert.NoError(err)
+	assert.Equal([]string{filepath.Join(dir, "test_proof_0.json"), filepath.Join(dir, "test_proof_1.json"), filepath.Join(dir, "test_proof_2.bin")}, files,
+		"a proof in both formats should be listed once, as resolveProofFile picks it")
+	assert.Equal(files[1], resolveProofFile(filepath.Join(dir, "test_proof_1.json")))
+}
+
+func TestRewrittenVerifyingKeysAreReadAgain(t *testing.T) {
+	assert := test.NewAssert(t)
+	jsonDir, binaryDir := t.TempDir(), t.TempDir()
+	copyTestProofs(t, jsonDir)
+	_, err := ConvertProofFiles(jsonDir, binaryDir, ProofFormatBinary)
+	assert.NoError(err)
+	proofFile := filepath.Join(binaryDir, "test_proof_0.bin")
+	_, err = readCompletedProof(proofFile)
+	assert.NoError(err)
+
+	// swap every key for another one under the same hash
+	keysFile := filepath.Join(binaryDir, verifyingKeysName)
+	keys, err := readVerifyingKeys(keysFile)
+	assert.NoError(err)
+	tampered := make(verifyingKeys)
+	for hash, rawVK := range keys {
+		tampered[hash] = append([]byte{rawVK[0] ^ 1}, rawVK[1:]...)
+	}
+	assert.NoError(writeVerifyingKeys(keysFile, tampered))
+	_, err = readCompletedProof(proofFile)
+	assert.Error(err, "a verifying key that does not match its hash should not be used")
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	endorsementsName = "endorsements.json"
+
+	// endorsementDomain separates endorsement signatures from any other use of an auditor key
+	endorsementDomain = "bgproof endorsement v1\n"
+)
+
+// Endorsement is an auditor's Ed25519 signature over a published statement, made after the auditor
+// fully verified the epoch's proofs themselves.
+type Endorsement struct {
+	Version          int
+	AuditorPublicKey []byte
+	Statement        PublishedStatement
+	Signature        []byte
+}
+
+// Endorsements are the endorsements of one statement by distinct auditors, published alongside the proofs.
+type Endorsements struct {
+	Version      int
+	Endorsements []Endorsement
+}
+
+func endorsedMessage(statement PublishedStatement) []byte {
+	encoded, err := cborEncoding.Marshal(statement)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte(endorsementDomain), encoded...)
+}
+
+func signEndorsement(statement PublishedStatement, privateKey ed25519.PrivateKey) Endorsement {
+	return Endorsement{
+		Version:          SchemaVersion,
+		AuditorPublicKey: privateKey.Public().(ed25519.PublicKey),
+		Statement:        statement,
+		Signature:        ed25519.Sign(privateKey, endorsedMessage(statement)),
+	}
+}
+
+func (endorsement Endorsement) signatureIsValid() bool {
+	return len(endorsement.AuditorPublicKey) == ed25519.PublicKeySize &&
+		ed25519.Verify(endorsement.AuditorPublicKey, endorsedMessage(endorsement.Statement), endorsement.Signature)
+}
+
+// Endorse fully verifies the proofs in 'out/public/' as Verify does, pinned to statement, and if every check
+// passes signs the statement with the auditor's key. It only needs the public proofs, so no user's account is
+// checked for inclusion. The report is returned either way.
+func Endorse(batchCount int, statement PublishedStatement, privateKey ed25519.PrivateKey, options ...VerifyOption) (Endorsement, VerificationReport) {
+	report := verifyProofSet(publicDir, batchCount, nil, append(options, WithPublishedStatement(statement)))
+	if !report.Passed() {
+		return Endorsement{}, report
+	}
+	return signEndorsement(statement, privateKey), report
+}
+
+// CombineEndorsements collects the endorsements of one statement, dropping repeated endorsements by the same auditor.
+func CombineEndorsements(endorsements []Endorsement) (combined Endorsements, err error) {
+	combined = Endorsements{Version: SchemaVersion, Endorsements: make([]Endorsement, 0, len(endorsements))}
+	seen := make(map[string]bool)
+	for i, endorsement := range endorsements {
+		if !endorsement.signatureIsValid() {
+			return combined, fmt.Errorf("endorsement %d by %x has an invalid signature", i, endorsement.AuditorPublicKey)
+		}
+		if !bytes.Equal(endorsedMessage(endorsement.Statement), endorsedMessage(endorsements[0].Statement)) {
+			return combined, fmt.Errorf("endorsement %d by %x endorses a different statement", i, endorsement.AuditorPublicKey)
+		}
+		if seen[string(endorsement.AuditorPublicKey)] {
+			continue
+		}
+		seen[string(endorsement.AuditorPublicKey)] = true
+		combined.Endorsements = append(combined.Endorsements, endorsement)
+	}
+	return combined, nil
+}
+
+func statementMatchesProof(statement PublishedStatement, topLevelProof CompletedProof) bool {
+	return epochContextMismatch(topLevelProof, statement.EpochContext) == nil &&
+		bytes.Equal(topLevelProof.MerkleRoot, statement.MerkleRoot) &&
+		bytes.Equal(topLevelProof.MerkleRootWithAssetSumHash, statement.MerkleRootWithAssetSumHash) &&
+		totalsMismatch(topLevelProof, statement) == nil
+}
+
+// distinctKeys returns keys without the repeats of a key, so that each auditor counts once.
+func distinctKeys(keys []ed25519.PublicKey) []ed25519.PublicKey {
+	distinct := make([]ed25519.PublicKey, 0, len(keys))
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if !seen[string(key)] {
+			seen[string(key)] = true
+			distinct = append(distinct, key)
+		}
+	}
+	return distinct
+}
+
+// verifyEndorsements checks at least threshold of the distinct auditorKeys validly endorsed the top level proof's
+// roots and totals.
+func verifyEndorsements(endorsements Endorsements, auditorKeys []ed25519.PublicKey, threshold int, topLevelProof CompletedProof) {
+	if threshold < 1 {
+		panic(fmt.Sprintf("threshold of %d endorsements would accept proofs no auditor endorsed", threshold))
+	}
+	auditorKeys = distinctKeys(auditorKeys)
+	if threshold > len(auditorKeys) {
+		panic(fmt.Sprintf("threshold of %d endorsements cannot be met by %d auditors", threshold, len(auditorKeys)))
+	}
+	endorsed := make(map[string]bool)
+	for _, endorsement := range endorsements.Endorsements {
+		isAuditor := false
+		for _, key := range auditorKeys {
+			isAuditor = isAuditor || bytes.Equal(key, endorsement.AuditorPublicKey)
+		}
+		if isAuditor && endorsement.signatureIsValid() && statementMatchesProof(endorsement.Statement, topLevelProof) {
+			endorsed[string(endorsement.AuditorPublicKey)] = true
+		}
+	}
+	if len(endorsed) < threshold {
+		panic(fmt.Sprintf("only %d of the required %d auditors endorsed the top level proof", len(endorsed), threshold))
+	}
+}
+
+// WithAuditorEndorsements makes verification fail unless at least threshold of auditorKeys endorsed the top
+// level proof's roots and totals. A nil endorsements, as when none were published, fails verification. The threshold
+// must be at least 1.
+func WithAuditorEndorsements(auditorKeys []ed25519.PublicKey, threshold int, endorsements *Endorsements) VerifyOption {
+	if threshold < 1 {
+		panic(fmt.Sprintf("threshold of %d endorsements would accept proofs no auditor endorsed", threshold))
+	}
+	return func(config *verifyConfig) {
+		config.auditorKeys = auditorKeys
+		config.auditorThreshold = threshold
+		config.endorsements = endorsements
+	}
+}
+
+func ReadEndorsementFromFile(filePath string) (endorsement Endorsement, err error) {
+	err = readVersionedJson(filePath, &endorsement)
+	return endorsement, err
+}
+
+func WriteEndorsementToFile(filePath string, endorsement Endorsement) error {
+	return writeJson(filePath, &endorsement)
+}
+
+func ReadEndorsementsFromFile(filePath string) (endorsements Endorsements, err error) {
+	err = readVersionedJson(filePath, &endorsements)
+	return endorsements, err
+}
+
+func WriteEndorsementsToFile(filePath string, endorsements Endorsements) error {
+	return writeJson(filePath, &endorsements)
+}
+
+// EndorsementsFile is where the combined endorsements of the proofs in proofsDir are kept.
+func EndorsementsFile(proofsDir string) string {
+	return filepath.Join(proofsDir, endorsementsName)
+}
+
+// ReadAuditorKeys reads a set of hex encoded auditor public keys, one per line. Blank lines, lines
+// starting with # and repeats of a key are ignored.
+func ReadAuditorKeys(filePath string) ([]ed25519.PublicKey, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]ed25519.PublicKey, 0)
+	for n, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := hex.DecodeString(line)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s:%d: expected a hex encoded %d byte key", filePath, n+1, ed25519.PublicKeySize)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s lists no auditor keys", filePath)
+	}
+	return distinctKeys(keys), nil
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark/test"
+)
+
+func TestEndorsementThreshold(t *testing.T) {
+	assert := test.NewAssert(t)
+	copyTestProofsToPublicDir(t)
+	statement := NewPublishedStatement(1, proofTop)
+
+	auditorKeys := make([]ed25519.PublicKey, 3)
+	endorsements := make([]Endorsement, 0)
+	for i := range auditorKeys {
+		publicKey, privateKey, err := GenerateSigningKey()
+		assert.NoError(err)
+		auditorKeys[i] = publicKey
+		if i < 2 {
+			endorsement, report := Endorse(2, statement, privateKey)
+			assert.True(report.Passed())
+			for _, check := range report.Checks {
+				assert.NotEqual(CheckUserInclusion, check.Kind, "endorsing should not need a user's account")
+			}
+			endorsements = append(endorsements, endorsement)
+		}
+	}
+	// an auditor endorsing twice still counts once
+	combined, err := CombineEndorsements(append(endorsements, endorsements[0]))
+	assert.NoError(err)
+	assert.Equal(2, len(combined.Endorsements))
+
+	report := VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithAuditorEndorsements(auditorKeys, 2, &combined))
+	assert.True(report.Passed())
+	assert.Equal(CheckEndorsements, report.Checks[len(report.Checks)-1].Kind)
+
+	report = VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithAuditorEndorsements(auditorKeys, 3, &combined))
+	assert.False(report.Passed(), "should fail when fewer auditors than the threshold endorsed")
+	assert.Equal(CheckEndorsements, report.Failures()[0].Kind)
+
+	report = VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithAuditorEndorsements(auditorKeys[1:], 2, &combined))
+	assert.False(report.Passed(), "endorsements by keys outside the auditor set should not count")
+
+	repeated := []ed25519.PublicKey{auditorKeys[1], auditorKeys[1], auditorKeys[2]}
+	report = VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithAuditorEndorsements(repeated, 2, &combined))
+	assert.False(report.Passed(), "a repeated auditor key should count once toward the threshold")
+	repeated = []ed25519.PublicKey{auditorKeys[0], auditorKeys[0], auditorKeys[1]}
+	report = VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithAuditorEndorsements(repeated, 2, &combined))
+	assert.True(report.Passed(), report.Failures())
+
+	report = VerifyProofPath(altProofLower0.AccountLeaves[0], altProofLower0, altProofMid, altProofTop, WithAuditorEndorsements(auditorKeys, 1, &combined))
+	assert.False(report.Passed(), "should fail when the endorsements are of a different proof set")
+
+	report = VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithAuditorEndorsements(auditorKeys, 1, nil))
+	assert.False(report.Passed(), "should fail when nothing was endorsed")
+
+	// a threshold below 1 would pass without any endorsement
+	assert.Panics(func() { WithAuditorEndorsements(auditorKeys, 0, &Endorsements{}) })
+	assert.Panics(func() { WithAuditorEndorsements(auditorKeys, -1, &combined) })
+	assert.Panics(func() { verifyEndorsements(Endorsements{}, auditorKeys, 0, proofTop) })
+}
+
+func TestEndorseRequiresVerification(t *testing.T) {
+	assert := test.NewAssert(t)
+	copyTestProofsToPublicDir(t)
+	_, privateKey, err := GenerateSigningKey()
+	assert.NoError(err)
+
+	endorsement, report := Endorse(2, NewPublishedStatement(1, altProofTop), privateKey)
+	assert.False(report.Passed())
+	assert.Nil(endorsement.Signature, "should not endorse a statement the proofs do not match")
+}
+
+func TestCombineEndorsementsRejectsInvalid(t *testing.T) {
+	assert := test.NewAssert(t)
+	_, privateKey, err := GenerateSigningKey()
+	assert.NoError(err)
+	endorsement := signEndorsement(NewPublishedStatement(1, proofTop), privateKey)
+	other := signEndorsement(NewPublishedStatement(1, altProofTop), privateKey)
+
+	_, err = CombineEndorsements([]Endorsement{endorsement, other})
+	assert.Error(err, "should reject endorsements of different statements")
+
+	forged := endorsement
+	forged.Statement.Epoch = 2
+	_, err = CombineEndorsements([]Endorsement{forged})
+	assert.Error(err, "should reject endorsements with invalid signatures")
+
+	path := filepath.Join(t.TempDir(), endorsementsName)
+	combined, err := CombineEndorsements([]Endorsement{endorsement})
+	assert.NoError(err)
+	assert.NoError(WriteEndorsementsToFile(path, combined))
+	read, err := ReadEndorsementsFromFile(path)
+	assert.NoError(err)
+	assert.True(read.Endorsements[0].signatureIsValid())
+}
+
+func TestReadAuditorKeys(t *testing.T) {
+	assert := test.NewAssert(t)
+	path := filepath.Join(t.TempDir(), "auditors")
+	err := os.WriteFile(path, []byte("# auditors\n"+
+		"d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a\n\n"+
+		"3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c\n"+
+		"d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a\n"), 0o644)
+	assert.NoError(err)
+	keys, err := ReadAuditorKeys(path)
+	assert.NoError(err)
+	assert.Equal(2, len(keys), "a repeated key should be read once")
+
+	assert.NoError(os.WriteFile(path, []byte("abcd\n"), 0o644))
+	_, err = ReadAuditorKeys(path)
+	assert.Error(err)
+}
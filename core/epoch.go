@@ -0,0 +1,76 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+// EpochContext identifies the epoch a proof was made for. Circuits since circuitIdV3 take all of it as public
+// inputs, circuitIdV2 only the epoch number, so a proof cannot be republished as that of another epoch. Lower level
+// proofs made with the unbound circuits take none and leave it unset; the top level proof binds them to its own.
+type EpochContext struct {
+	Epoch uint64
+	// SnapshotTimestamp is when the balances were snapshotted, in seconds since the Unix epoch
+	SnapshotTimestamp uint64 `cbor:",omitempty"`
+	// IssuerIdHash is circuit.GoComputeIssuerIdHash of the issuer's identifier
+	IssuerIdHash []byte `cbor:",omitempty"`
+}
+
+// NewEpochContext returns the context of epoch for the issuer's balances snapshotted at snapshotTime.
+func NewEpochContext(epoch uint64, snapshotTime time.Time, issuerId string) EpochContext {
+	if snapshotTime.Before(time.Unix(0, 0)) {
+		panic("snapshot time is before the Unix epoch")
+	}
+	return EpochContext{
+		Epoch:             epoch,
+		SnapshotTimestamp: uint64(snapshotTime.Unix()),
+		IssuerIdHash:      circuit.GoComputeIssuerIdHash(issuerId),
+	}
+}
+
+// witness is the epoch context a circuit binds its proof to: context, or none if it is nil.
+func (context *EpochContext) witness() []circuit.EpochContext {
+	if context == nil {
+		return nil
+	}
+	return []circuit.EpochContext{{Epoch: context.Epoch, SnapshotTimestamp: context.SnapshotTimestamp, IssuerIdHash: context.IssuerIdHash}}
+}
+
+func (context EpochContext) equals(other EpochContext) bool {
+	return context.Epoch == other.Epoch && context.SnapshotTimestamp == other.SnapshotTimestamp &&
+		bytes.Equal(context.IssuerIdHash, other.IssuerIdHash)
+}
+
+// epochContextMismatch reports how the epoch context proof was made for differs from expected, comparing
+// only what the proof's circuit binds.
+func epochContextMismatch(proof CompletedProof, expected EpochContext) error {
+	spec := circuits[proof.CircuitId]
+	if spec.bindsEpoch && proof.Epoch != expected.Epoch {
+		return fmt.Errorf("proof is from epoch %d but epoch %d was expected", proof.Epoch, expected.Epoch)
+	}
+	if spec.bindsContext && proof.SnapshotTimestamp != expected.SnapshotTimestamp {
+		return fmt.Errorf("proof is of the snapshot at %s but the snapshot at %s was expected",
+			time.Unix(int64(proof.SnapshotTimestamp), 0).UTC().Format(time.RFC3339), time.Unix(int64(expected.SnapshotTimestamp), 0).UTC().Format(time.RFC3339))
+	}
+	if spec.bindsContext && !bytes.Equal(proof.IssuerIdHash, expected.IssuerIdHash) {
+		return fmt.Errorf("proof is from issuer %x but issuer %x was expected", proof.IssuerIdHash, expected.IssuerIdHash)
+	}
+	return nil
+}
+
+func verifyProofEpochContext(proof CompletedProof, expected EpochContext) {
+	if err := epochContextMismatch(proof, expected); err != nil {
+		panic(err)
+	}
+}
+
+// WithExpectedEpoch makes verification fail unless the top level proof was made for the expected epoch context.
+// Every lower level proof must share the top level proof's context.
+func WithExpectedEpoch(expected EpochContext) VerifyOption {
+	return func(config *verifyConfig) {
+		config.expectedEpoch = &expected
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/consensys/gnark/test"
+)
+
+func TestEpochContextMismatch(t *testing.T) {
+	assert := test.NewAssert(t)
+	snapshot := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	expected := NewEpochContext(3, snapshot, "BitGo")
+
+	proof := proofTop
+	proof.CircuitId = circuitIdV3
+	proof.EpochContext = expected
+	assert.NoError(epochContextMismatch(proof, expected))
+
+	proof.EpochContext = NewEpochContext(3, snapshot.Add(-24*time.Hour), "BitGo")
+	assert.Error(epochContextMismatch(proof, expected), "should reject a proof of an older snapshot")
+	proof.EpochContext = NewEpochContext(3, snapshot, "Other")
+	assert.Error(epochContextMismatch(proof, expected), "should reject a proof by another issuer")
+
+	// circuits that only bind the epoch number are only checked against it
+	proof.CircuitId = circuitIdV2
+	assert.NoError(epochContextMismatch(proof, expected))
+	proof.Epoch = 2
+	assert.Error(epochContextMismatch(proof, expected))
+
+	// v1 proofs bind no epoch context at all
+	assert.NoError(epochContextMismatch(proofTop, expected))
+}
+
+func TestVerifyProofPathWithExpectedEpoch(t *testing.T) {
+	assert := test.NewAssert(t)
+	expected := NewEpochContext(3, time.Now(), "BitGo")
+
+	report := VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithExpectedEpoch(expected))
+	assert.True(report.Passed(), "v1 proofs do not bind an epoch context")
+	assert.Equal(CheckEpochContext, report.Checks[len(report.Checks)-1].Kind)
+
+	// without the SNARK, a v3 proof path whose lower levels are from another snapshot
+	bottom, mid, top := proofLower0, proofMid, proofTop
+	for _, proof := range []*CompletedProof{&bottom, &mid, &top} {
+		proof.CircuitId = circuitIdV3
+		proof.EpochContext = expected
+	}
+	bottom.SnapshotTimestamp--
+	assert.Panics(func() { verifyLowerLayerProofEpoch(bottom, mid) })
+	assert.NotPanics(func() { verifyLowerLayerProofEpoch(mid, top) })
+	assert.NotPanics(func() { verifyProofEpochContext(top, expected) })
+
+	// lower level proofs made without the epoch context stand in any epoch, but a top level proof must bind it
+	bottom.CircuitId, mid.CircuitId = circuitIdV3Unbound, circuitIdV3Unbound
+	bottom.EpochContext, mid.EpochContext = EpochContext{}, EpochContext{}
+	assert.NotPanics(func() { verifyLowerLayerProofEpoch(bottom, mid) })
+	assert.NotPanics(func() { verifyLowerLayerProofEpoch(mid, top) })
+	assert.NoError(epochContextMismatch(mid, expected))
+	assert.Panics(func() { verifyTopLayerProofMatchesAssetSum(mid) })
+}
+
+func TestNewPublishedStatementKeepsEpochContext(t *testing.T) {
+	assert := test.NewAssert(t)
+	top := proofTop
+	top.CircuitId = circuitIdV3
+	top.EpochContext = NewEpochContext(4, time.Now(), "BitGo")
+
+	statement := NewPublishedStatement(4, top)
+	assert.True(statement.EpochContext.equals(top.EpochContext))
+	assert.NotPanics(func() { verifyTopLayerProofMatchesStatement(top, statement) })
+
+	// statements of earlier versions did not record a snapshot or issuer, and their hash must not change
+	legacy := NewPublishedStatement(4, proofTop)
+	encoded, err := cborEncoding.Marshal(legacy)
+	assert.NoError(err)
+	assert.NotContains(string(encoded), "SnapshotTimestamp")
+	assert.NotContains(string(encoded), "IssuerIdHash")
+}
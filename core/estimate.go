@@ -0,0 +1,190 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+)
+
+// Estimate is what proving one batch costs for the chosen parameters, measured on this machine, and what
+// proving an epoch of EpochAccounts accounts is projected to take.
+type Estimate struct {
+	AccountsPerBatch  int
+	TreeDepth         int
+	Assets            int
+	Constraints       int
+	PublicWires       int
+	SecretWires       int
+	InternalWires     int
+	ProvingKeyBytes   int64
+	VerifyingKeyBytes int64
+	// PeakHeapBytes is the largest heap seen while compiling, setting up and proving the benchmark proofs
+	PeakHeapBytes   uint64
+	CompileDuration time.Duration
+	SetupDuration   time.Duration
+	ProofDuration   time.Duration
+	// UpperLevelSetupDuration and UpperLevelProofDuration are those of a circuit of as many accounts as the tree has
+	// leaves, as mid and top level proofs are: the batch's own when batches are full
+	UpperLevelSetupDuration time.Duration
+	UpperLevelProofDuration time.Duration
+	// KeysKept is set when the keys are kept from an earlier epoch, as prove --keys-dir does, so that no setup is run
+	KeysKept          bool
+	EpochAccounts     int
+	BottomLevelProofs int
+	MidLevelProofs    int
+	// ProjectedDuration prices bottom level proofs as the benchmark batch and mid and top level proofs as the
+	// upper level benchmark, and adds a setup of each circuit unless the keys are kept
+	ProjectedDuration time.Duration
+}
+
+// MaxEpochAccounts is the most accounts an epoch of bottom, mid and top level proofs can hold with batches of
+// accountsPerBatch accounts, each mid and top level proof holding as many lower level proofs as a tree of the
+// given depth has leaves.
+func MaxEpochAccounts(accountsPerBatch int, depth int) int {
+	fanOut := circuit.PowOfTwo(depth)
+	return accountsPerBatch * fanOut * fanOut
+}
+
+// heapSampler records the peak heap allocation until stopped.
+type heapSampler struct {
+	peak    uint64
+	stop    chan struct{}
+	done    sync.WaitGroup
+	stopped sync.Once
+}
+
+func sampleHeap(interval time.Duration) *heapSampler {
+	sampler := &heapSampler{stop: make(chan struct{})}
+	sampler.done.Add(1)
+	go func() {
+		defer sampler.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			sampler.sample()
+			select {
+			case <-sampler.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return sampler
+}
+
+func (sampler *heapSampler) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if stats.HeapAlloc > sampler.peak {
+		sampler.peak = stats.HeapAlloc
+	}
+}
+
+func (sampler *heapSampler) finish() uint64 {
+	sampler.stopped.Do(func() { close(sampler.stop) })
+	sampler.done.Wait()
+	sampler.sample()
+	return sampler.peak
+}
+
+// projectEpoch fills in the proof counts of an epoch of epochAccounts accounts and how long proving it would take.
+// Each mid level proof holds as many bottom level proofs as a tree of the estimate's depth has leaves.
+func (estimate *Estimate) projectEpoch(epochAccounts int) {
+	if epochAccounts > MaxEpochAccounts(estimate.AccountsPerBatch, estimate.TreeDepth) {
+		panic(fmt.Sprintf("an epoch of %d accounts does not fit in three levels of trees of depth %d", epochAccounts, estimate.TreeDepth))
+	}
+	fanOut := circuit.PowOfTwo(estimate.TreeDepth)
+	estimate.EpochAccounts = epochAccounts
+	estimate.BottomLevelProofs = (epochAccounts + estimate.AccountsPerBatch - 1) / estimate.AccountsPerBatch
+	estimate.MidLevelProofs = (estimate.BottomLevelProofs + fanOut - 1) / fanOut
+	estimate.ProjectedDuration = time.Duration(estimate.BottomLevelProofs)*estimate.ProofDuration +
+		time.Duration(estimate.MidLevelProofs+1)*estimate.UpperLevelProofDuration
+	if !estimate.KeysKept {
+		// the top level circuit binds the epoch context, and the mid level one shares the keys of full batches
+		estimate.ProjectedDuration += estimate.SetupDuration + estimate.UpperLevelSetupDuration
+		if estimate.AccountsPerBatch != fanOut {
+			estimate.ProjectedDuration += estimate.UpperLevelSetupDuration
+		}
+	}
+}
+
+// benchmark is what compiling, setting up and proving the estimation circuit of one size cost.
+type benchmark struct {
+	cs                    constraint.ConstraintSystem
+	compile, setup, proof time.Duration
+	provingKeyBytes       int64
+	verifyingKeyBytes     int64
+}
+
+func benchmarkEstimationCircuit(accountCount int, depth int, assets int) (result benchmark) {
+	shape, assignment := circuit.NewEstimationCircuit(accountCount, depth, assets)
+	start := time.Now()
+	cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, shape)
+	if err != nil {
+		panic(err)
+	}
+	result.cs, result.compile = cs, time.Since(start)
+
+	start = time.Now()
+	pk, vk, err := groth16.Setup(cs)
+	if err != nil {
+		panic(err)
+	}
+	result.setup = time.Since(start)
+	if result.provingKeyBytes, err = pk.WriteTo(io.Discard); err != nil {
+		panic(err)
+	}
+	if result.verifyingKeyBytes, err = vk.WriteTo(io.Discard); err != nil {
+		panic(err)
+	}
+
+	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		panic(err)
+	}
+	start = time.Now()
+	if _, err = groth16.Prove(cs, pk, witness); err != nil {
+		panic(fmt.Errorf("benchmark proof failed: %w", err))
+	}
+	result.proof = time.Since(start)
+	return result
+}
+
+// EstimateProving compiles the estimation circuit for batches of accountsPerBatch accounts of the given number of
+// assets in a tree of the given depth, runs the setup and one benchmark proof, and projects how long proving an
+// epoch of epochAccounts accounts would take. Unless batches are full, a circuit of as many accounts as the tree has
+// leaves is benchmarked as well, for the mid and top level proofs. With keysKept, no setup is projected.
+func EstimateProving(accountsPerBatch int, depth int, assets int, epochAccounts int, keysKept bool) Estimate {
+	if epochAccounts < 1 {
+		panic("the epoch must have at least one account")
+	}
+	estimate := Estimate{AccountsPerBatch: accountsPerBatch, TreeDepth: depth, Assets: assets, KeysKept: keysKept}
+	sampler := sampleHeap(10 * time.Millisecond)
+	defer sampler.finish()
+
+	batch := benchmarkEstimationCircuit(accountsPerBatch, depth, assets)
+	estimate.CompileDuration, estimate.SetupDuration, estimate.ProofDuration = batch.compile, batch.setup, batch.proof
+	estimate.Constraints = batch.cs.GetNbConstraints()
+	estimate.PublicWires = batch.cs.GetNbPublicVariables()
+	estimate.SecretWires = batch.cs.GetNbSecretVariables()
+	estimate.InternalWires = batch.cs.GetNbInternalVariables()
+	estimate.ProvingKeyBytes, estimate.VerifyingKeyBytes = batch.provingKeyBytes, batch.verifyingKeyBytes
+	upper := batch
+	if accountsPerBatch != circuit.PowOfTwo(depth) {
+		upper = benchmarkEstimationCircuit(circuit.PowOfTwo(depth), depth, assets)
+	}
+	estimate.UpperLevelSetupDuration, estimate.UpperLevelProofDuration = upper.setup, upper.proof
+	estimate.PeakHeapBytes = sampler.finish()
+
+	estimate.projectEpoch(epochAccounts)
+	return estimate
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+)
+
+func TestEstimateProving(t *testing.T) {
+	assert := test.NewAssert(t)
+	estimate := EstimateProving(4, 3, 3, 200, false)
+	assert.Equal(4, estimate.AccountsPerBatch)
+	assert.Equal(3, estimate.Assets)
+	assert.True(estimate.Constraints > 0)
+	assert.Equal(6, estimate.PublicWires, "the constant wire and the five public inputs")
+	assert.True(estimate.ProvingKeyBytes > estimate.VerifyingKeyBytes)
+	assert.True(estimate.PeakHeapBytes > 0)
+	assert.True(estimate.ProofDuration > 0)
+	assert.Equal(50, estimate.BottomLevelProofs)
+	assert.Equal(7, estimate.MidLevelProofs, "each mid level proof holds 8 bottom level proofs at depth 3")
+	assert.True(estimate.UpperLevelProofDuration > 0, "the mid and top level proofs of 8 accounts should be benchmarked")
+	assert.True(estimate.ProjectedDuration > estimate.SetupDuration)
+
+	fewerAssets := EstimateProving(4, 3, 2, 200, true)
+	assert.Less(fewerAssets.Constraints, estimate.Constraints, "each asset adds constraints")
+	assert.True(fewerAssets.KeysKept)
+	assert.Panics(func() { EstimateProving(4, 3, 2, MaxEpochAccounts(4, 3)+1, false) }, "the epoch should fit in three levels")
+}
+
+func TestProjectEpoch(t *testing.T) {
+	assert := test.NewAssert(t)
+	estimate := Estimate{AccountsPerBatch: 1024, TreeDepth: 10, SetupDuration: time.Minute, ProofDuration: time.Second,
+		UpperLevelSetupDuration: time.Minute, UpperLevelProofDuration: time.Second}
+	estimate.projectEpoch(1024*2048 + 1)
+	assert.Equal(2049, estimate.BottomLevelProofs)
+	assert.Equal(3, estimate.MidLevelProofs)
+	assert.Equal(2*time.Minute+(2049+3+1)*time.Second, estimate.ProjectedDuration, "full batches share the keys of the mid level")
+
+	// smaller batches are cheaper to prove than the mid and top levels, which have a circuit of their own
+	estimate = Estimate{AccountsPerBatch: 256, TreeDepth: 10, SetupDuration: time.Minute, ProofDuration: time.Second,
+		UpperLevelSetupDuration: 3 * time.Minute, UpperLevelProofDuration: 4 * time.Second}
+	estimate.projectEpoch(256 * 2048)
+	assert.Equal(2048, estimate.BottomLevelProofs)
+	assert.Equal(2, estimate.MidLevelProofs)
+	assert.Equal(7*time.Minute+2048*time.Second+3*4*time.Second, estimate.ProjectedDuration)
+	estimate.KeysKept = true
+	estimate.projectEpoch(256 * 2048)
+	assert.Equal(2048*time.Second+3*4*time.Second, estimate.ProjectedDuration, "kept keys need no setup")
+}
+
+func TestEstimationCircuitMatchesCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	for _, accountCount := range []int{4, circuit.PowOfTwo(circuit.TreeDepth)} {
+		shape, _ := circuit.NewEstimationCircuit(accountCount, circuit.TreeDepth, circuit.AssetCount)
+		cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, shape)
+		assert.NoError(err)
+		proving := compileCircuit(circuitShape{accountCount: accountCount, binding: circuit.BindsContext})
+		assert.Equal(proving.GetNbConstraints(), cs.GetNbConstraints(), "at the tree depth and assets of Circuit, the estimation circuit should make its constraints")
+		assert.Equal(proving.GetNbPublicVariables(), cs.GetNbPublicVariables())
+	}
+}
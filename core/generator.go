@@ -2,14 +2,15 @@ package core
 
 import (
 	"bitgo.com/proof_of_reserves/circuit"
-	"strconv"
 )
 
 func writeTestDataToFile(batchCount int, countPerBatch int) {
 	var lastAccount *circuit.GoAccount
 	for i := 0; i < batchCount; i++ {
-		filePath := "out/secret/test_data_" + strconv.Itoa(i) + ".json"
+		filePath := proofFilePath(secretDataPrefix, i)
 		var secretData ProofElements
+		secretData.Version = SchemaVersion
+		secretData.CircuitId = circuit.CircuitId
 		var assetSum circuit.GoBalance
 		secretData.Accounts, assetSum, secretData.MerkleRoot, secretData.MerkleRootWithAssetSumHash = circuit.GenerateTestData(countPerBatch, i+11)
 		secretData.AssetSum = &assetSum
@@ -24,7 +25,9 @@ func writeTestDataToFile(batchCount int, countPerBatch int) {
 	if lastAccount == nil {
 		panic("lastAccount is nil")
 	}
-	err := writeJson("out/user/test_account.json", lastAccount)
+	lastAccount.Version = SchemaVersion
+	lastAccount.CircuitId = circuit.CircuitId
+	err := writeJson(userAccountFile, lastAccount)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+)
+
+// assetSumOpeningFile is where Prove writes the opening of a hidden total's commitment.
+const assetSumOpeningFile = secretDir + assetSumOpeningName
+
+// AssetSumOpening opens the commitment of a top level proof that hides its totals. Like the secret data, it is
+// only disclosed to auditors. BatchBlindings holds the blinding factor of each bottom level proof's asset sum, in
+// the order of the batches, for an audit to recompute their MerkleRootWithAssetSumHash from the ledger.
+type AssetSumOpening struct {
+	Version        int
+	AssetSum       circuit.GoBalance
+	Blinding       circuit.GoBalance
+	BatchBlindings []*big.Int `json:",omitempty"`
+}
+
+func WriteAssetSumOpeningToFile(filePath string, opening AssetSumOpening) error {
+	if err := writeJson(filePath, &opening); err != nil {
+		return err
+	}
+	return os.Chmod(filePath, 0o600)
+}
+
+func ReadAssetSumOpeningFromFile(filePath string) (opening AssetSumOpening, err error) {
+	err = readVersionedJson(filePath, &opening)
+	return opening, err
+}
+
+// WithHiddenTotal makes the top level proof hide the total liabilities: it publishes a Pedersen commitment to each
+// asset's total instead, and proves each total is at most the given reserves. The commitment's opening is written
+// to the secret directory. The bottom and mid level proofs are made with circuit.BlindedCircuitId, each blinding its
+// asset sum with a fresh random factor, so that no MerkleRootWithAssetSumHash can be checked against a guess of a
+// total; they are therefore never reused. The account count cannot be proven along with hidden totals.
+func WithHiddenTotal(reserves circuit.GoBalance) ProveOption {
+	return func(config *proveConfig) {
+		config.reserves = &reserves
+	}
+}
+
+func newHiddenTotalWitness(elements ProofElements, context EpochContext, reserves circuit.GoBalance, opening AssetSumOpening) witness.Witness {
+	commitment, err := circuit.ConvertGoCommitmentToCommitment(circuit.GoCommitToBalance(opening.AssetSum, opening.Blinding))
+	if err != nil {
+		panic(err)
+	}
+	witnessInput := circuit.HiddenTotalCircuit{
+		Accounts:           circuit.ConvertGoAccountsToAccounts(elements.Accounts),
+		AccountBlindings:   circuit.ConvertGoAccountBlindings(elements.Accounts),
+		AssetSum:           circuit.ConvertGoBalanceToBalance(opening.AssetSum),
+		Blinding:           circuit.ConvertGoBalanceToBalance(opening.Blinding),
+		MerkleRoot:         elements.MerkleRoot,
+		AssetSumCommitment: commitment,
+		Reserves:           circuit.ConvertGoBalanceToBalance(reserves),
+		Epoch:              context.Epoch,
+		SnapshotTimestamp:  context.SnapshotTimestamp,
+		IssuerIdHash:       context.IssuerIdHash,
+	}
+	witness, err := frontend.NewWitness(&witnessInput, ecc.BN254.ScalarField())
+	if err != nil {
+		panic(err)
+	}
+	return witness
+}
+
+// verifyTotalWithinReserves checks each asset's total is at most its reserves, before proving it.
+func verifyTotalWithinReserves(assetSum circuit.GoBalance, reserves circuit.GoBalance) {
+	if assetSum.AccountCount != nil {
+		panic("hidden totals cannot be proven along with the account count")
+	}
+	if assetSum.Bitcoin.Cmp(&reserves.Bitcoin) > 0 || assetSum.Ethereum.Cmp(&reserves.Ethereum) > 0 {
+		panic(fmt.Sprintf("total liabilities (%s) exceed the reserves (%s)", describeBalance(assetSum), describeBalance(reserves)))
+	}
+}
+
+// generateHiddenTotalProof proves elements, the mid level proofs, as the top level proof hiding its totals below
+// reserves, and returns the opening of its commitment.
+func generateHiddenTotalProof(elements ProofElements, context EpochContext, reserves circuit.GoBalance, keysDir string) (CompletedProof, AssetSumOpening) {
+	elements = completeProofElements(elements)
+	verifyTotalWithinReserves(*elements.AssetSum, reserves)
+	opening := AssetSumOpening{Version: SchemaVersion, AssetSum: *elements.AssetSum, Blinding: circuit.GoRandomBlinding()}
+
+	keys := provingKeys(circuitShape{accountCount: len(elements.Accounts), hidesTotal: true}, keysDir)
+	proof, err := groth16.Prove(keys.cs, keys.pk, newHiddenTotalWitness(elements, context, reserves, opening), backend.WithIcicleAcceleration())
+	if err != nil {
+		panic(err)
+	}
+	b := bytes.Buffer{}
+	if _, err = proof.WriteTo(&b); err != nil {
+		panic(err)
+	}
+	commitment := circuit.GoCommitToBalance(opening.AssetSum, opening.Blinding)
+	return CompletedProof{
+		Version:            SchemaVersion,
+		CircuitId:          circuit.HiddenTotalCircuitId,
+		Proof:              base64.StdEncoding.EncodeToString(b.Bytes()),
+		VK:                 encodeVerifyingKey(keys.vk),
+		AccountLeaves:      computeAccountLeavesFromAccounts(elements.Accounts),
+		MerkleRoot:         elements.MerkleRoot,
+		AssetSumCommitment: &commitment,
+		Reserves:           &reserves,
+		EpochContext:       context,
+	}, opening
+}
+
+// blind gives the asset sum of elements a fresh blinding factor if the epoch hides its totals, so that the proof's
+// MerkleRootWithAssetSumHash reveals nothing of the sum.
+func (config *proveConfig) blind(elements ProofElements) ProofElements {
+	if config.reserves == nil {
+		return elements
+	}
+	if elements.AssetSum == nil {
+		panic("AssetSum is nil")
+	}
+	assetSum := *elements.AssetSum
+	assetSum.Blinding = circuit.GoRandomSumBlinding()
+	elements.AssetSum = &assetSum
+	// the recorded root with the asset sum did not commit to the blinding factor
+	elements.MerkleRootWithAssetSumHash = nil
+	return elements
+}
+
+// proveTopLevel proves elements, the mid level proofs, as the top level proof, hiding its totals if configured to.
+// The opening of hidden totals records the blinding factors of bottomLevelProofs.
+func (config *proveConfig) proveTopLevel(elements ProofElements, context EpochContext, counting circuit.Counting, bottomLevelProofs []CompletedProof) CompletedProof {
+	if config.reserves == nil {
+		return config.proveOrReuse(elements, &context, counting, topLevelProofName, 0)
+	}
+	topLevelProof, opening := generateHiddenTotalProof(elements, context, *config.reserves, config.keysDir)
+	for _, proof := range bottomLevelProofs {
+		opening.BatchBlindings = append(opening.BatchBlindings, proof.AssetSum.Blinding)
+	}
+	if config.reuse != nil {
+		config.reuse.Reproven = append(config.reuse.Reproven, ReprovenProof{
+			File:   proofFilePath(topLevelProofPrefix, 0),
+			Reason: "its hidden totals are committed to with new blinding factors",
+		})
+	}
+	if err := WriteAssetSumOpeningToFile(assetSumOpeningFile, opening); err != nil {
+		panic(err)
+	}
+	return topLevelProof
+}
+
+// solveHiddenTotalWitness is solveWitness for the top level proof hiding its totals.
+func solveHiddenTotalWitness(elements ProofElements, context EpochContext, reserves circuit.GoBalance) {
+	elements = completeProofElements(elements)
+	verifyTotalWithinReserves(*elements.AssetSum, reserves)
+	opening := AssetSumOpening{AssetSum: *elements.AssetSum, Blinding: circuit.GoRandomBlinding()}
+	isSolved(circuitShape{accountCount: len(elements.Accounts), hidesTotal: true}, newHiddenTotalWitness(elements, context, reserves, opening))
+}
+
+// verifyHiddenTotal checks a top level proof that hides its totals publishes the commitment and reserves its SNARK
+// proves, and not the totals.
+func verifyHiddenTotal(topLayerProof CompletedProof) {
+	if topLayerProof.AssetSum != nil {
+		panic("top layer proof publishes the asset sum it hides")
+	}
+	if topLayerProof.AssetSumCommitment == nil || topLayerProof.Reserves == nil {
+		panic("top layer proof does not publish its asset sum commitment and reserves")
+	}
+}
+
+// verifyAssetSumOpening checks opening opens the top level proof's commitment to total.
+func verifyAssetSumOpening(topLayerProof CompletedProof, opening AssetSumOpening, total circuit.GoBalance) {
+	if !opening.AssetSum.Equals(total) {
+		panic(fmt.Sprintf("opened total (%s) differs from the ledger total (%s)", describeBalance(opening.AssetSum), describeBalance(total)))
+	}
+	commitment := circuit.GoCommitToBalance(opening.AssetSum, opening.Blinding)
+	if topLayerProof.AssetSumCommitment == nil || !topLayerProof.AssetSumCommitment.Equals(commitment) {
+		panic("the asset sum opening does not open the published commitment")
+	}
+}
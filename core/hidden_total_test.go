@@ -0,0 +1,81 @@
+package core
+
+import (
+	"math/big"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+func TestProveWithHiddenTotal(t *testing.T) {
+	assert := test.NewAssert(t)
+	ledger := []ProofElements{generatedBatch(4, 21), generatedBatch(3, 22)}
+	writeSecretData(t, ledger...)
+	total := circuit.SumGoAccountBalances(slices.Concat(ledger[0].Accounts, ledger[1].Accounts))
+	reserves := circuit.GoBalance{Bitcoin: *new(big.Int).Add(&total.Bitcoin, big.NewInt(1)), Ethereum: total.Ethereum}
+	context := NewEpochContext(1, time.Now(), "BitGo")
+
+	bottomLevelProofs, top := Prove(2, context, nil, WithHiddenTotal(reserves))
+	assert.Equal(circuit.HiddenTotalCircuitId, top.CircuitId)
+	assert.Nil(top.AssetSum, "the total should not be published")
+	assert.True(top.Reserves.Equals(reserves))
+
+	// no published hash commits to a sum alone, which a guess of the total could be checked against
+	midLevelProof, err := readCompletedProof(proofFilePath(midLevelProofPrefix, 0))
+	assert.NoError(err)
+	assert.Equal(circuit.BlindedCircuitId, midLevelProof.CircuitId)
+	assert.NotEqual(circuit.GoComputeMiMCHashForAccount(circuit.GoAccount{UserId: midLevelProof.MerkleRoot, Balance: total}), midLevelProof.MerkleRootWithAssetSumHash)
+	for i, proof := range bottomLevelProofs {
+		batchSum := circuit.SumGoAccountBalances(ledger[i].Accounts)
+		assert.Equal(circuit.BlindedCircuitId, proof.CircuitId)
+		assert.NotEqual(circuit.GoComputeMiMCHashForAccount(circuit.GoAccount{UserId: proof.MerkleRoot, Balance: batchSum}), proof.MerkleRootWithAssetSumHash)
+	}
+
+	statement, err := ReadStatementFromDir(publicDir)
+	assert.NoError(err)
+	assert.Nil(statement.AssetSum)
+	assert.True(statement.AssetSumCommitment.Equals(*top.AssetSumCommitment))
+	report := VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(ledger[1].Accounts[2]), publicDir, WithPublishedStatement(statement))
+	assert.True(report.Passed(), report.Failures())
+	report = Verify(2, ledger[1].Accounts[2], WithPublishedStatement(statement))
+	assert.True(report.Passed(), report.Failures())
+
+	opening, err := ReadAssetSumOpeningFromFile(assetSumOpeningFile)
+	assert.NoError(err)
+	assert.True(opening.AssetSum.Equals(total))
+	assert.Len(opening.BatchBlindings, 2)
+	report = Audit(filepath.Clean(secretDir), filepath.Clean(publicDir), Placement{})
+	assert.True(report.Passed(), report.Failures())
+	assert.True(DryRun(2, context, WithHiddenTotal(reserves)).Passed())
+
+	// the solvency certificate is of the epoch the reserves were stated for
+	_, privateKey, err := GenerateSigningKey()
+	assert.NoError(err)
+	reservesStatement := SignReservesStatement(custodiedReserves(reserves, statement), statement, privateKey)
+	assert.True(reservesStatement.EpochContext.equals(context))
+	certificate, report := CertifySolvency(publicDir, reservesStatement, nil, testOwnership)
+	assert.True(report.Passed(), report.Failures())
+	assert.True(certificate.Assets[0].ProvenInCircuit)
+	reservesStatement.SnapshotTimestamp++
+	_, report = CertifySolvency(publicDir, reservesStatement, nil, testOwnership)
+	assert.False(report.Passed(), "reserves of another snapshot should fail")
+
+	// the batches are only audited with the blinding factors they were proven with
+	opening.BatchBlindings[0] = circuit.GoRandomSumBlinding()
+	assert.NoError(WriteAssetSumOpeningToFile(assetSumOpeningFile, opening))
+	assert.False(Audit(filepath.Clean(secretDir), filepath.Clean(publicDir), Placement{}).Passed())
+
+	// the opening must be to the ledger total and to the published commitment
+	assert.Panics(func() { verifyAssetSumOpening(top, opening, reserves) })
+	opening.Blinding.Bitcoin.Add(&opening.Blinding.Bitcoin, big.NewInt(1))
+	assert.Panics(func() { verifyAssetSumOpening(top, opening, total) })
+
+	// totals above the reserves cannot be proven
+	reserves.Ethereum.Sub(&reserves.Ethereum, big.NewInt(1))
+	assert.Panics(func() { Prove(2, context, nil, WithHiddenTotal(reserves)) })
+	assert.False(DryRun(2, context, WithHiddenTotal(reserves)).Passed())
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+)
+
+const (
+	provingKeyExtension   = ".pk"
+	verifyingKeyExtension = ".vk"
+)
+
+// keysId identifies a set of keys: those of the circuit of a shape, and where they are kept.
+type keysId struct {
+	keysDir string
+	shape   circuitShape
+}
+
+// keyFilePrefix names the key files of the circuit of the given shape in keysDir. The circuit id is part of the
+// name so that keys of an older circuit are never used for the current one.
+func keyFilePrefix(keysDir string, shape circuitShape) string {
+	name := fmt.Sprintf("%s_%d", shape.circuitId(), shape.accountCount)
+	if shape.counting == circuit.CountSubtrees || shape.margin == circuit.MarginSubtrees || shape.segmenting == circuit.SegmentSubtrees || shape.blinding == circuit.BlindSubtrees {
+		// upper level circuits count or sum accounts differently from bottom level circuits of the same batch size
+		name += "_subtrees"
+	}
+	return filepath.Join(keysDir, name)
+}
+
+func readKeys(prefix string) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	pk, vk := groth16.NewProvingKey(ecc.BN254), groth16.NewVerifyingKey(ecc.BN254)
+	for _, k := range []struct {
+		file string
+		read func(*os.File) error
+	}{
+		{prefix + provingKeyExtension, func(f *os.File) error { _, err := pk.ReadFrom(f); return err }},
+		{prefix + verifyingKeyExtension, func(f *os.File) error { _, err := vk.ReadFrom(f); return err }},
+	} {
+		f, err := os.Open(k.file)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = k.read(f)
+		closeErr := f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", k.file, err)
+		}
+		if closeErr != nil {
+			return nil, nil, closeErr
+		}
+	}
+	return pk, vk, nil
+}
+
+func writeKeys(prefix string, pk groth16.ProvingKey, vk groth16.VerifyingKey) error {
+	var pkBuf, vkBuf bytes.Buffer
+	if _, err := pk.WriteTo(&pkBuf); err != nil {
+		return err
+	}
+	if _, err := vk.WriteTo(&vkBuf); err != nil {
+		return err
+	}
+	// the proving key stays private to the prover, like the secret data
+	if err := os.WriteFile(prefix+provingKeyExtension, pkBuf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(prefix+verifyingKeyExtension, vkBuf.Bytes(), 0o644)
+}
+
+// provingKeys returns the compiled circuit and keys of the given shape, once per shape. With a keysDir, the keys
+// are read from it if an earlier run saved them there, and saved to it otherwise, so that the proofs of different
+// runs share their verifying key.
+func provingKeys(shape circuitShape, keysDir string) PartialProof {
+	id := keysId{keysDir: keysDir, shape: shape}
+	if keys, ok := cachedProofs[id]; ok {
+		return keys
+	}
+	keys := PartialProof{cs: compileCircuit(shape)}
+	var err error
+	prefix := keyFilePrefix(keysDir, shape)
+	if keysDir != "" {
+		keys.pk, keys.vk, err = readKeys(prefix)
+		if err != nil && !os.IsNotExist(err) {
+			panic(err)
+		}
+	}
+	if keys.pk == nil {
+		keys.pk, keys.vk, err = groth16.Setup(keys.cs)
+		if err != nil {
+			panic(err)
+		}
+		if keysDir != "" {
+			if err = writeKeys(prefix, keys.pk, keys.vk); err != nil {
+				panic(err)
+			}
+		}
+	}
+	cachedProofs[id] = keys
+	return keys
+}
+
+// encodeVerifyingKey encodes vk the way proofs carry it.
+func encodeVerifyingKey(vk groth16.VerifyingKey) string {
+	b := bytes.Buffer{}
+	if _, err := vk.WriteTo(&b); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b.Bytes())
+}
+
+// WithPersistentKeys keeps the proving and verifying keys of each circuit shape in keysDir, and proves with the
+// keys found there, so that proofs made by separate runs share their verifying key.
+func WithPersistentKeys(keysDir string) ProveOption {
+	return func(config *proveConfig) {
+		config.keysDir = keysDir
+	}
+}
@@ -1,12 +1,19 @@
 package core
 
-import "bitgo.com/proof_of_reserves/circuit"
+import (
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
 
 func main() {
 	batchCount := 10
 	GenerateData(batchCount, 16)
-	Prove(batchCount)
-	account := ReadDataFromFile[circuit.GoAccount]("out/user/test_account.json")
-	Verify(batchCount, account)
+	Prove(batchCount, NewEpochContext(0, time.Now(), "BitGo"), nil)
+	account := ReadDataFromFile[circuit.GoAccount](userAccountFile)
+	report := Verify(batchCount, account)
+	if !report.Passed() {
+		panic("verification failed")
+	}
 	print("Proof succeeded!")
 }
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+// MigrateFiles rewrites every versioned file in inDir at the current schema version into outDir: proofs
+// (in the format they were in), secret batches, the published statement and user accounts. Proofs keep
+// the circuit id they were made with, so they are still verified against that circuit. Any other files
+// are copied unchanged.
+func MigrateFiles(inDir string, outDir string) (written []string, err error) {
+	entries, err := os.ReadDir(inDir)
+	if err != nil {
+		return nil, err
+	}
+	writer := newProofFileWriter(outDir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == verifyingKeysName {
+			// the verifying keys file is rewritten by the proof writer
+			continue
+		}
+		inFile := filepath.Join(inDir, name)
+		outFile := filepath.Join(outDir, name)
+		extension := filepath.Ext(name)
+		switch {
+		case isProofFileName(name):
+			var proof CompletedProof
+			proof, err = readCompletedProof(inFile)
+			if err != nil {
+				return written, fmt.Errorf("reading %s: %w", inFile, err)
+			}
+			format := ProofFormatJson
+			if extension == binaryExtension {
+				format = ProofFormatBinary
+			}
+			outFile, err = writer.write(strings.TrimSuffix(name, extension), format, proof)
+		case strings.HasPrefix(name, secretDataName) && extension == jsonExtension:
+			err = migrateJsonFile[ProofElements](inFile, outFile)
+		case name == statementName:
+			err = migrateJsonFile[PublishedStatement](inFile, outFile)
+		case name == userAccountName:
+			err = migrateJsonFile[circuit.GoAccount](inFile, outFile)
+		default:
+			err = copyFile(inFile, outFile)
+		}
+		if err != nil {
+			return written, err
+		}
+		written = append(written, outFile)
+	}
+	keysFile, err := writer.finish()
+	if err != nil {
+		return written, err
+	}
+	if keysFile != "" {
+		written = append(written, keysFile)
+	}
+	return written, nil
+}
+
+func isProofFileName(name string) bool {
+	extension := filepath.Ext(name)
+	if extension != jsonExtension && extension != binaryExtension {
+		return false
+	}
+	for _, prefix := range []string{bottomLevelProofName, midLevelProofName, topLevelProofName} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func migrateJsonFile[D ProofElements | PublishedStatement | circuit.GoAccount](inFile string, outFile string) error {
+	var data D
+	err := readVersionedJson(inFile, &data)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", inFile, err)
+	}
+	return writeJson(outFile, &data)
+}
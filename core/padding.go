@@ -0,0 +1,62 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+// paddingDomain separates the dummy accounts' user ids from any other use of the seed
+const paddingDomain = "bgproof padding v1"
+
+// dummyUserId is the user id of the dummy account at index of batch: SHA-256(paddingDomain || seed || batch || index),
+// with batch and index as big endian uint64s, reduced modulo the field. Without the seed it cannot be told apart from
+// the id of a real account, and its leaf hash from that of a real leaf.
+func dummyUserId(seed []byte, batch int, index int) []byte {
+	hasher := sha256.New()
+	hasher.Write([]byte(paddingDomain))
+	hasher.Write(seed)
+	hasher.Write(binary.BigEndian.AppendUint64(nil, uint64(batch)))
+	hasher.Write(binary.BigEndian.AppendUint64(nil, uint64(index)))
+	id := new(big.Int).Mod(new(big.Int).SetBytes(hasher.Sum(nil)), ecc.BN254.ScalarField())
+	return id.FillBytes(make([]byte, circuit.ModBytes))
+}
+
+// PadAccounts appends dummy accounts with zero balances to every batch until it holds size accounts, so that the
+// published proofs do not reveal how many accounts each batch, or the ledger, has. The circuit proves a batch's
+// asset sum includes the balance of every leaf, so each dummy account provably adds exactly its zero balance.
+// The recorded asset sum of every input batch must be right.
+func PadAccounts(batches []ProofElements, seed []byte, size int) []ProofElements {
+	if size > circuit.PowOfTwo(circuit.TreeDepth) {
+		panic(fmt.Sprintf("batches cannot be padded beyond %d accounts", circuit.PowOfTwo(circuit.TreeDepth)))
+	}
+	padded := make([]ProofElements, len(batches))
+	for i, batch := range batches {
+		sum := sumBalances(batch.Accounts, batch.AssetSum)
+		if batch.AssetSum == nil || !batch.AssetSum.Equals(sum) {
+			panic(fmt.Sprintf("Asset sum does not match in batch %d", i))
+		}
+		if len(batch.Accounts) > size {
+			panic(fmt.Sprintf("batch %d has %d accounts, more than the %d it is padded to", i, len(batch.Accounts), size))
+		}
+		padded[i] = ProofElements{Version: batch.Version, CircuitId: batch.CircuitId, AssetSum: &sum}
+		padded[i].Accounts = append(make([]circuit.GoAccount, 0, size), batch.Accounts...)
+		for j := len(batch.Accounts); j < size; j++ {
+			dummy := circuit.GoAccount{UserId: dummyUserId(seed, i, j)}
+			if sum.AccountCount != nil {
+				// a dummy account is no ledger account
+				dummy.Balance.AccountCount = big.NewInt(0)
+			}
+			if sum.Segments != nil {
+				// a dummy account of zero balance adds nothing to the segment it is tagged with
+				dummy.Balance.Segment = big.NewInt(0)
+			}
+			padded[i].Accounts = append(padded[i].Accounts, dummy)
+		}
+	}
+	return padded
+}
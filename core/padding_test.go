@@ -0,0 +1,33 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+)
+
+func TestPadAccounts(t *testing.T) {
+	assert := test.NewAssert(t)
+	batches := []ProofElements{generatedBatch(5, 11), generatedBatch(2, 12)}
+	padded := PadAccounts(batches, []byte("seed"), 8)
+	for i, batch := range padded {
+		assert.Equal(8, len(batch.Accounts))
+		assert.True(batch.AssetSum.Equals(*batches[i].AssetSum))
+		assert.Equal(batches[i].Accounts, batch.Accounts[:len(batches[i].Accounts)])
+		for _, dummy := range batch.Accounts[len(batches[i].Accounts):] {
+			assert.Equal(0, dummy.Balance.Bitcoin.Sign())
+			assert.Equal(0, dummy.Balance.Ethereum.Sign())
+			assert.True(new(big.Int).SetBytes(dummy.UserId).Cmp(ecc.BN254.ScalarField()) < 0)
+		}
+	}
+	assert.Equal(padded, PadAccounts(batches, []byte("seed"), 8), "dummy accounts should be reproducible from the seed")
+	assert.False(bytes.Equal(dummyUserId([]byte("seed"), 0, 5), dummyUserId([]byte("seed"), 1, 5)))
+	assert.False(bytes.Equal(dummyUserId([]byte("seed"), 0, 5), dummyUserId([]byte("other"), 0, 5)))
+
+	assert.Panics(func() { PadAccounts(batches, []byte("seed"), 4) }, "a batch larger than the padded size")
+	assert.Panics(func() { PadAccounts(batches, []byte("seed"), 2048) }, "padding beyond the tree")
+	assert.Panics(func() { Placement{PadTo: 8}.apply(batches) }, "padding needs the seed")
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EpochSeedFile is where the seed of the given epoch is kept in secretDir. Every epoch has a seed of its own, so
+// that the placements of two epochs cannot be linked, and the seed disclosed to the auditors of one epoch reveals
+// nothing about the placement of any other.
+func EpochSeedFile(secretDir string, epoch uint64) string {
+	return filepath.Join(secretDir, fmt.Sprintf("epoch_%d_seed.hex", epoch))
+}
+
+// GenerateEpochSeed returns a new random seed for an epoch's account placement.
+func GenerateEpochSeed() []byte {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		panic(err)
+	}
+	return seed
+}
+
+// WriteEpochSeedToFile writes seed hex encoded. Like the secret data, it is only disclosed to auditors.
+func WriteEpochSeedToFile(filePath string, seed []byte) error {
+	return os.WriteFile(filePath, []byte(hex.EncodeToString(seed)+"\n"), 0o600)
+}
+
+func ReadEpochSeedFromFile(filePath string) ([]byte, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return seed, nil
+}
+
+// Placement is how the prover lays out the ledger's accounts in the bottom level proofs, derived from the epoch's
+// secret seed: each batch is first padded to PadTo accounts with dummy accounts unless PadTo is 0, then the accounts
+// are shuffled across all batches if Shuffle is set. Auditors given the seed reproduce it.
+type Placement struct {
+	Seed    []byte
+	PadTo   int
+	Shuffle bool
+}
+
+func (placement Placement) isIdentity() bool {
+	return placement.PadTo == 0 && !placement.Shuffle
+}
+
+func (placement Placement) apply(batches []ProofElements) []ProofElements {
+	if placement.isIdentity() {
+		return batches
+	}
+	if placement.Seed == nil {
+		panic("placing accounts requires the epoch's seed")
+	}
+	if placement.PadTo != 0 {
+		batches = PadAccounts(batches, placement.Seed, placement.PadTo)
+	}
+	if placement.Shuffle {
+		batches = ShuffleAccounts(batches, placement.Seed)
+	}
+	return batches
+}
+
+// WithPlacement places the accounts as given before proving.
+func WithPlacement(placement Placement) ProveOption {
+	return func(config *proveConfig) {
+		config.placement = placement
+	}
+}
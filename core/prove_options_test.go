@@ -0,0 +1,76 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+// proveOptionCase is a ledger proven with an option that changes the circuit, and what its proofs must then show.
+type proveOptionCase struct {
+	name      string
+	option    ProveOption
+	circuitId string
+	seed      int
+	// batch generates a batch of the ledger the option proves
+	batch func(count int, seed int) ProofElements
+	// needsOption is set when the ledger does not validate without the option
+	needsOption bool
+	// leaf is the account a user's leaf hashes, if it is not the ledger account
+	leaf func(account circuit.GoAccount) circuit.GoAccount
+	// check makes the checks specific to the option, of the proofs of ledger and the statement published with them
+	check func(assert *test.Assert, ledger []ProofElements, bottomLevelProofs []CompletedProof, top CompletedProof, statement PublishedStatement)
+}
+
+func TestProveVerifyAndAuditWithOption(t *testing.T) {
+	cases := []proveOptionCase{
+		{name: "account count", option: WithAccountCount(), circuitId: circuit.CountingCircuitId, seed: 11, batch: generatedBatch,
+			leaf: countedLeaf, check: checkAccountCount},
+		{name: "cross margin", option: WithCrossMargin(testPrices), circuitId: circuit.CrossMarginCircuitId, seed: 31, batch: marginBatch,
+			needsOption: true, check: checkCrossMargin},
+		{name: "segments", option: WithSegments(), circuitId: circuit.SegmentedCircuitId, seed: 41, batch: segmentedBatch,
+			needsOption: true, check: checkSegments},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert := test.NewAssert(t)
+			ledger := []ProofElements{c.batch(4, c.seed), c.batch(3, c.seed+1)}
+			writeSecretData(t, ledger...)
+			account := ledger[1].Accounts[0]
+			assert.NoError(os.MkdirAll(userDir, 0o755))
+			assert.NoError(writeJson(userAccountFile, &account))
+			placement := Placement{Seed: []byte("epoch seed"), PadTo: 8, Shuffle: true}
+
+			if c.needsOption {
+				assert.False(ValidateLedger(secretDir).Passed(), "the ledger should only validate with the option")
+			}
+			validation := ValidateLedger(secretDir, c.option)
+			assert.True(validation.Passed(), validation.Issues)
+
+			bottomLevelProofs, top := Prove(2, NewEpochContext(1, time.Now(), "BitGo"), nil, c.option, WithPlacement(placement))
+			assert.Equal(c.circuitId, top.CircuitId)
+			for _, proof := range bottomLevelProofs {
+				assert.Equal(circuit.Unbound.CircuitId(c.circuitId), proof.CircuitId, "only the top level proof binds the epoch context")
+			}
+			statement, err := ReadStatementFromDir(publicDir)
+			assert.NoError(err)
+			assert.True(statement.AssetSum.Equals(*top.AssetSum))
+
+			if c.leaf != nil {
+				account = c.leaf(account)
+			}
+			report := VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(account), publicDir, WithPublishedStatement(statement))
+			assert.True(report.Passed(), report.Failures())
+			report = Verify(2, account, WithPublishedStatement(statement))
+			assert.True(report.Passed(), report.Failures())
+			report = Audit(filepath.Clean(secretDir), filepath.Clean(publicDir), placement)
+			assert.True(report.Passed(), report.Failures())
+
+			c.check(assert, ledger, bottomLevelProofs, top, statement)
+		})
+	}
+}
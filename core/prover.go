@@ -7,10 +7,11 @@ import (
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/constraint"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"strconv"
+	"time"
 )
 
 type PartialProof struct {
@@ -19,9 +20,99 @@ type PartialProof struct {
 	cs constraint.ConstraintSystem
 }
 
-var cachedProofs = make(map[int]PartialProof)
+var cachedProofs = make(map[keysId]PartialProof)
 
-func generateProof(elements ProofElements) CompletedProof {
+// circuitShape is what a compiled circuit depends on: its batch size, how it counts accounts, whether it takes
+// cross-margin or segmented accounts, whether it binds the epoch context, whether it blinds its asset sum and, for
+// top level proofs, whether it hides the totals.
+type circuitShape struct {
+	accountCount int
+	counting     circuit.Counting
+	margin       circuit.Margin
+	segmenting   circuit.Segmenting
+	binding      circuit.Binding
+	blinding     circuit.Blinding
+	hidesTotal   bool
+}
+
+func (shape circuitShape) circuitId() string {
+	if shape.hidesTotal {
+		return circuit.HiddenTotalCircuitId
+	}
+	if shape.blinding != circuit.NoBlinding {
+		return circuit.BlindedCircuitId
+	}
+	if shape.margin != circuit.NoMargin {
+		return shape.binding.CircuitId(circuit.CrossMarginCircuitId)
+	}
+	if shape.segmenting != circuit.NoSegments {
+		return shape.binding.CircuitId(circuit.SegmentedCircuitId)
+	}
+	return shape.binding.CircuitId(shape.counting.CircuitId())
+}
+
+func (shape circuitShape) definition() frontend.Circuit {
+	if shape.hidesTotal {
+		return circuit.NewHiddenTotalCircuit(shape.accountCount)
+	}
+	if shape.blinding != circuit.NoBlinding {
+		return circuit.NewBlindedCircuit(shape.accountCount, shape.blinding)
+	}
+	if shape.margin != circuit.NoMargin {
+		return circuit.NewCrossMarginCircuit(shape.accountCount, shape.margin, shape.binding)
+	}
+	if shape.segmenting != circuit.NoSegments {
+		return circuit.NewSegmentedCircuit(shape.accountCount, shape.segmenting, shape.binding)
+	}
+	return circuit.NewCircuit(shape.accountCount, shape.counting, shape.binding)
+}
+
+// shapeOf is the shape of the circuit proving elements with the given counting, whose accounts must be counted
+// if and only if the circuit counts them, binding the epoch context unless it is nil. Elements whose asset sum has
+// prices are proven by a cross-margin circuit, elements tagged with segments by a segmented one and elements whose
+// asset sum has a blinding factor by a blinded one; none of them counts accounts, and no elements are two of them.
+// Blinded circuits do not bind the epoch context.
+func shapeOf(elements ProofElements, counting circuit.Counting, context *EpochContext) circuitShape {
+	counted := circuit.ConvertGoAccountCounts(elements.Accounts) != nil
+	if counted != (counting != circuit.NoCounting) {
+		panic("the accounts must have account counts exactly when the circuit counts accounts")
+	}
+	margin := circuit.MarginOf(elements.Accounts, *elements.AssetSum)
+	if margin != circuit.NoMargin && counted {
+		panic("cross-margin accounts cannot be counted")
+	}
+	segmenting := circuit.SegmentingOf(elements.Accounts)
+	if segmenting != circuit.NoSegments && (counted || margin != circuit.NoMargin) {
+		panic("segmented accounts cannot be counted or cross-margin accounts")
+	}
+	binding := circuit.BindsContext
+	if context == nil {
+		binding = circuit.Unbound
+	}
+	blinding := circuit.BlindingOf(elements.Accounts, *elements.AssetSum)
+	if blinding != circuit.NoBlinding && (counted || margin != circuit.NoMargin || segmenting != circuit.NoSegments || binding != circuit.Unbound) {
+		panic("blinded asset sums cannot be counted, cross-margin or segmented, nor bound to the epoch context")
+	}
+	return circuitShape{accountCount: len(elements.Accounts), counting: counting, margin: margin, segmenting: segmenting, binding: binding, blinding: blinding}
+}
+
+var compiledCircuits = make(map[circuitShape]constraint.ConstraintSystem)
+
+// compileCircuit compiles the circuit of the given shape, once per shape.
+func compileCircuit(shape circuitShape) constraint.ConstraintSystem {
+	if cs, ok := compiledCircuits[shape]; ok {
+		return cs
+	}
+	cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, shape.definition())
+	if err != nil {
+		panic(err)
+	}
+	compiledCircuits[shape] = cs
+	return cs
+}
+
+// completeProofElements fills in the roots of elements that were not recorded with them.
+func completeProofElements(elements ProofElements) ProofElements {
 	if elements.AssetSum == nil {
 		panic("AssetSum is nil")
 	}
@@ -31,83 +122,84 @@ func generateProof(elements ProofElements) CompletedProof {
 	if elements.MerkleRootWithAssetSumHash == nil {
 		elements.MerkleRootWithAssetSumHash = circuit.GoComputeMiMCHashForAccount(circuit.GoAccount{UserId: elements.MerkleRoot, Balance: *elements.AssetSum})
 	}
-	actualBalances := circuit.SumGoAccountBalances(elements.Accounts)
-	if !actualBalances.Equals(*elements.AssetSum) {
-		panic("Asset sum does not match")
-	}
+	return elements
+}
 
-	proofLen := len(elements.Accounts)
-	if _, ok := cachedProofs[proofLen]; !ok {
-		var err error
-		c := &circuit.Circuit{
-			Accounts: make([]circuit.Account, len(elements.Accounts)),
-		}
-		cachedProof := PartialProof{}
-		cachedProof.cs, err = frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, c)
-		if err != nil {
-			panic(err)
-		}
-		cachedProof.pk, cachedProof.vk, err = groth16.Setup(cachedProof.cs)
-		if err != nil {
-			panic(err)
-		}
-		cachedProofs[proofLen] = cachedProof
+func newWitness(elements ProofElements, context *EpochContext) witness.Witness {
+	if elements.AssetSum.Prices != nil {
+		return newCrossMarginWitness(elements, context)
+	}
+	if elements.AssetSum.Segments != nil {
+		return newSegmentedWitness(elements, context)
 	}
-	cachedProof := cachedProofs[proofLen]
 	var witnessInput circuit.Circuit
 	witnessInput.Accounts = circuit.ConvertGoAccountsToAccounts(elements.Accounts)
 	witnessInput.MerkleRoot = elements.MerkleRoot
-	if elements.AssetSum == nil {
-		panic("AssetSum is nil")
-	}
 	witnessInput.AssetSum = circuit.ConvertGoBalanceToBalance(*elements.AssetSum)
+	witnessInput.AccountCounts = circuit.ConvertGoAccountCounts(elements.Accounts)
+	if elements.AssetSum.AccountCount != nil {
+		witnessInput.AccountCount = []frontend.Variable{elements.AssetSum.AccountCount}
+	}
 	witnessInput.MerkleRootWithAssetSumHash = elements.MerkleRootWithAssetSumHash
+	witnessInput.Context = context.witness()
+	witnessInput.AccountBlindings = circuit.ConvertGoAccountBlindings(elements.Accounts)
+	if elements.AssetSum.Blinding != nil {
+		witnessInput.SumBlinding = []frontend.Variable{elements.AssetSum.Blinding}
+	}
 	witness, err := frontend.NewWitness(&witnessInput, ecc.BN254.ScalarField())
 	if err != nil {
 		panic(err)
 	}
+	return witness
+}
+
+// generateProof proves elements with the circuit counting accounts as given, bound to the epoch context unless it is
+// nil, with the keys for its shape, kept in keysDir unless it is empty.
+func generateProof(elements ProofElements, context *EpochContext, counting circuit.Counting, keysDir string) CompletedProof {
+	elements = completeProofElements(elements)
+	actualBalances := sumBalances(elements.Accounts, elements.AssetSum)
+	if !actualBalances.Equals(*elements.AssetSum) {
+		panic("Asset sum does not match")
+	}
+
+	shape := shapeOf(elements, counting, context)
+	cachedProof := provingKeys(shape, keysDir)
+	witness := newWitness(elements, context)
 	proof, err := groth16.Prove(cachedProof.cs, cachedProof.pk, witness, backend.WithIcicleAcceleration())
 	if err != nil {
 		panic(err)
 	}
 
 	var completedProof CompletedProof
+	completedProof.Version = SchemaVersion
+	completedProof.CircuitId = shape.circuitId()
+	if context != nil {
+		completedProof.EpochContext = *context
+	}
 	b1 := bytes.Buffer{}
 	_, err = proof.WriteTo(&b1)
 	if err != nil {
 		panic(err)
 	}
 	completedProof.Proof = base64.StdEncoding.EncodeToString(b1.Bytes())
-	b2 := bytes.Buffer{}
-	_, err = cachedProof.vk.WriteTo(&b2)
-	if err != nil {
-		panic(err)
-	}
-	completedProof.VK = base64.StdEncoding.EncodeToString(b2.Bytes())
+	completedProof.VK = encodeVerifyingKey(cachedProof.vk)
 	completedProof.AccountLeaves = computeAccountLeavesFromAccounts(elements.Accounts)
 	completedProof.MerkleRoot = circuit.GoComputeMerkleRootFromAccounts(elements.Accounts)
 	if elements.AssetSum == nil {
 		panic("AssetSum is nil")
 	}
 	completedProof.AssetSum = elements.AssetSum
+	completedProof.Prices = elements.AssetSum.Prices
 	completedProof.MerkleRootWithAssetSumHash = circuit.GoComputeMiMCHashForAccount(circuit.GoAccount{UserId: completedProof.MerkleRoot, Balance: *elements.AssetSum})
 	return completedProof
 }
 
-func generateProofs(proofElements []ProofElements) []CompletedProof {
-	completedProofs := make([]CompletedProof, len(proofElements))
-	for i := 0; i < len(proofElements); i++ {
-		completedProofs[i] = generateProof(proofElements[i])
-	}
-	return completedProofs
-}
-
 func writeProofsToFiles(proofs []CompletedProof, prefix string, saveAssetSum bool) {
 	for i, proof := range proofs {
 		if !saveAssetSum {
 			proof.AssetSum = nil
 		}
-		filePath := prefix + strconv.Itoa(i) + ".json"
+		filePath := proofFilePath(prefix, i)
 		err := writeJson(filePath, proof)
 		if err != nil {
 			panic(err)
@@ -115,7 +207,8 @@ func writeProofsToFiles(proofs []CompletedProof, prefix string, saveAssetSum boo
 	}
 }
 
-func generateNextLevelProofs(currentLevelProof []CompletedProof) CompletedProof {
+// nextLevelProofElements makes the proofs of a level the accounts of the next level's proof.
+func nextLevelProofElements(currentLevelProof []CompletedProof) ProofElements {
 	var nextLevelProofElements ProofElements
 	nextLevelProofElements.Accounts = make([]circuit.GoAccount, len(currentLevelProof))
 
@@ -129,27 +222,84 @@ func generateNextLevelProofs(currentLevelProof []CompletedProof) CompletedProof
 		}
 	}
 	nextLevelProofElements.MerkleRoot = circuit.GoComputeMerkleRootFromAccounts(nextLevelProofElements.Accounts)
-	assetSum := circuit.SumGoAccountBalances(nextLevelProofElements.Accounts)
+	// cross-margin proofs are summed at the prices they were proven at
+	assetSum := sumBalances(nextLevelProofElements.Accounts, &circuit.GoBalance{Prices: currentLevelProof[0].AssetSum.Prices})
 	nextLevelProofElements.AssetSum = &assetSum
 	nextLevelProofElements.MerkleRootWithAssetSumHash = circuit.GoComputeMiMCHashForAccount(circuit.GoAccount{UserId: nextLevelProofElements.MerkleRoot, Balance: *nextLevelProofElements.AssetSum})
-	return generateProof(nextLevelProofElements)
+	return nextLevelProofElements
 }
 
-func Prove(batchCount int) (bottomLevelProofs []CompletedProof, topLevelProof CompletedProof) {
+type proveConfig struct {
+	keysDir   string
+	reuseDir  string
+	reuse     *ReuseReport
+	placement Placement
+	// countAccounts is set to prove the number of ledger accounts; see WithAccountCount
+	countAccounts bool
+	// reserves is set to hide the totals of the top level proof below them; see WithHiddenTotal
+	reserves *circuit.GoBalance
+	// prices is set to prove a ledger with margin accounts at them; see WithCrossMargin
+	prices *circuit.GoBalance
+	// segments is set to prove the subtotal of each segment; see WithSegments
+	segments bool
+}
+
+type ProveOption func(config *proveConfig)
+
+// Prove proves every batch of secret data for the epoch context and writes the proofs and the statement to publish.
+// Unless this is the first epoch, previous is the statement published for the epoch before, which the new statement links to.
+func Prove(batchCount int, context EpochContext, previous *PublishedStatement, options ...ProveOption) (bottomLevelProofs []CompletedProof, topLevelProof CompletedProof) {
+	config := proveConfig{}
+	for _, option := range options {
+		option(&config)
+	}
+	if config.reuseDir != "" && config.keysDir == "" {
+		panic("reusing proofs requires persistent keys")
+	}
+	if config.reserves != nil && config.countAccounts {
+		panic("hidden totals cannot be proven along with the account count")
+	}
+	if config.prices != nil && (config.countAccounts || config.reserves != nil) {
+		panic("cross-margin ledgers cannot be proven with the account count or hidden totals")
+	}
+	if config.segments && (config.countAccounts || config.reserves != nil || config.prices != nil) {
+		panic("segments cannot be proven with the account count, cross-margin accounts or hidden totals")
+	}
+	if previous != nil {
+		verifyEpochFollows(*previous, context.Epoch)
+	}
+
 	// bottom level proofs
-	proofElements := ReadDataFromFiles[ProofElements](batchCount, "out/secret/test_data_")
-	bottomLevelProofs = generateProofs(proofElements)
-	writeProofsToFiles(bottomLevelProofs, "out/public/test_proof_", false)
+	proofElements := ReadDataFromFiles[ProofElements](batchCount, secretDataPrefix)
+	proofElements = config.prepare(proofElements)
+	bottomLevelCounting, upperLevelCounting := config.countings()
+	bottomLevelProofs = make([]CompletedProof, len(proofElements))
+	for i, elements := range proofElements {
+		bottomLevelProofs[i] = config.proveOrReuse(config.blind(elements), nil, bottomLevelCounting, bottomLevelProofName, i)
+	}
+	writeProofsToFiles(bottomLevelProofs, bottomLevelProofPrefix, false)
+	writeLeafIndex(publicDir, bottomLevelProofs)
 
-	// mid level proofs
+	// mid level proofs, which like the bottom level ones are not bound to the epoch context, so that those of batches
+	// that did not change can be reused in the next epoch; the top level proof binds them through its merkle root
 	midLevelProofs := make([]CompletedProof, 0)
-	for _, batch := range batchProofs(bottomLevelProofs, 1024) {
-		midLevelProofs = append(midLevelProofs, generateNextLevelProofs(batch))
+	for i, batch := range batchProofs(bottomLevelProofs, 1024) {
+		midLevelProofs = append(midLevelProofs, config.proveOrReuse(config.blind(nextLevelProofElements(batch)), nil, upperLevelCounting, midLevelProofName, i))
 	}
-	writeProofsToFiles(midLevelProofs, "out/public/test_mid_level_proof_", false)
+	writeProofsToFiles(midLevelProofs, midLevelProofPrefix, false)
 
 	// top level proof
-	topLevelProof = generateNextLevelProofs(midLevelProofs)
-	writeProofsToFiles([]CompletedProof{topLevelProof}, "out/public/test_top_level_proof_", true)
+	topLevelProof = config.proveTopLevel(nextLevelProofElements(midLevelProofs), context, upperLevelCounting, bottomLevelProofs)
+	writeProofsToFiles([]CompletedProof{topLevelProof}, topLevelProofPrefix, true)
+
+	// the user's account file for them to find their leaf with
+	recordUserAccountCount(userAccountFile, config.countAccounts)
+
+	// statement for verifiers to pin the top level proof to
+	statement := linkStatement(NewPublishedStatement(context.Epoch, topLevelProof), previous, time.Now())
+	err := WriteStatementToFile(statementFile, statement)
+	if err != nil {
+		panic(err)
+	}
 	return bottomLevelProofs, topLevelProof
 }
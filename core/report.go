@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+type CheckKind string
+
+const (
+	CheckSnark          CheckKind = "snark"
+	CheckLeavesToRoot   CheckKind = "leaves-to-root"
+	CheckChildToParent  CheckKind = "child-to-parent"
+	CheckTopLevelSum    CheckKind = "top-level-asset-sum"
+	CheckUserInclusion  CheckKind = "user-inclusion"
+	CheckProofSetLayout CheckKind = "proof-set-layout"
+	CheckStatement      CheckKind = "published-statement"
+	CheckProofDiscovery CheckKind = "proof-discovery"
+	CheckProofFile      CheckKind = "proof-file"
+	CheckAttestation    CheckKind = "issuer-attestation"
+	CheckEndorsements   CheckKind = "auditor-endorsements"
+	CheckEpochChain     CheckKind = "epoch-chain"
+	CheckEpochContext   CheckKind = "epoch-context"
+	CheckWitness        CheckKind = "witness"
+	CheckSolvency       CheckKind = "solvency"
+	CheckReserves       CheckKind = "reserves-statement"
+	CheckOwnership      CheckKind = "reserves-ownership"
+
+	CheckAuditLeaves          CheckKind = "audit-leaves"
+	CheckAuditBatchRoot       CheckKind = "audit-batch-root"
+	CheckAuditAssetSum        CheckKind = "audit-asset-sum"
+	CheckAuditLedgerInclusion CheckKind = "audit-ledger-inclusion"
+)
+
+type CheckResult struct {
+	Kind     CheckKind
+	File     string
+	Passed   bool
+	Error    string `json:",omitempty"`
+	Started  time.Time
+	Duration time.Duration
+}
+
+type VerificationReport struct {
+	Checks   []CheckResult
+	Started  time.Time
+	Duration time.Duration
+}
+
+func (report VerificationReport) Passed() bool {
+	for _, check := range report.Checks {
+		if !check.Passed {
+			return false
+		}
+	}
+	return len(report.Checks) > 0
+}
+
+func (report VerificationReport) Failures() []CheckResult {
+	failures := make([]CheckResult, 0)
+	for _, check := range report.Checks {
+		if !check.Passed {
+			failures = append(failures, check)
+		}
+	}
+	return failures
+}
+
+// runCheck runs a single verification step and records it in the report. The verification
+// helpers signal failure by panicking, so a panic here is recorded as a failed check rather
+// than aborting the remaining checks.
+func (report *VerificationReport) runCheck(kind CheckKind, file string, check func()) (passed bool) {
+	result := CheckResult{Kind: kind, File: file, Started: time.Now()}
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Sprint(r)
+		} else {
+			result.Passed = true
+		}
+		result.Duration = time.Since(result.Started)
+		report.Checks = append(report.Checks, result)
+		passed = result.Passed
+	}()
+	check()
+	return true
+}
+
+// runCheckIfFails is runCheck for steps that are only worth reporting when they fail, such as reading a file.
+func (report *VerificationReport) runCheckIfFails(kind CheckKind, file string, check func()) bool {
+	var stepReport VerificationReport
+	if stepReport.runCheck(kind, file, check) {
+		return true
+	}
+	report.Checks = append(report.Checks, stepReport.Checks...)
+	return false
+}
+
+func newVerificationReport() VerificationReport {
+	return VerificationReport{Checks: make([]CheckResult, 0), Started: time.Now()}
+}
+
+func (report *VerificationReport) finish() VerificationReport {
+	report.Duration = time.Since(report.Started)
+	return *report
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+// WithReusedProofs reuses the proofs in previousDir, the public directory of an earlier run, whose inputs have not
+// changed, rather than proving them again, and lists what was reused in report unless it is nil. A proof is only
+// reused if it was made with the current circuit and the persistent keys and, if it binds one, for the same epoch
+// context. Only the top level proof binds the epoch context, so the bottom and mid level proofs of batches that did
+// not change are reused from an earlier epoch, while the top level proof is proven again for each epoch.
+func WithReusedProofs(previousDir string, report *ReuseReport) ProveOption {
+	return func(config *proveConfig) {
+		config.reuseDir = previousDir
+		config.reuse = report
+	}
+}
+
+// ReprovenProof is a proof that could not be reused, and why.
+type ReprovenProof struct {
+	File   string
+	Reason string
+}
+
+// ReuseReport lists the proofs an incremental Prove reused from the previous run and those it proved again.
+type ReuseReport struct {
+	Reused   []string
+	Reproven []ReprovenProof
+}
+
+// reuseMismatch reports why the previous proof cannot stand for the proof of elements. The batch's content hash is
+// its MerkleRootWithAssetSumHash, which commits to every account and the asset sum.
+func reuseMismatch(previous CompletedProof, elements ProofElements, context *EpochContext, shape circuitShape, vk string) error {
+	if previous.CircuitId != shape.circuitId() {
+		return fmt.Errorf("it was made with circuit %s", previous.CircuitId)
+	}
+	if context != nil {
+		if err := epochContextMismatch(previous, *context); err != nil {
+			return fmt.Errorf("the epoch binding does not allow it: %w", err)
+		}
+	}
+	if previous.VK != vk {
+		return errors.New("it was made with other keys")
+	}
+	contentHash := circuit.GoComputeMiMCHashForAccount(circuit.GoAccount{UserId: elements.MerkleRoot, Balance: *elements.AssetSum})
+	if !bytes.Equal(previous.MerkleRootWithAssetSumHash, contentHash) {
+		return errors.New("its inputs changed")
+	}
+	return nil
+}
+
+// proveOrReuse proves elements as the index-th proof named name, counting accounts and binding the epoch context
+// as generateProof does, unless the previous run's proof of the same name can be reused.
+func (config *proveConfig) proveOrReuse(elements ProofElements, context *EpochContext, counting circuit.Counting, name string, index int) CompletedProof {
+	if config.reuseDir == "" {
+		return generateProof(elements, context, counting, config.keysDir)
+	}
+	file := proofFilePath(filepath.Join(publicDir, name), index)
+	if elements.AssetSum != nil && elements.AssetSum.Blinding != nil {
+		if config.reuse != nil {
+			config.reuse.Reproven = append(config.reuse.Reproven, ReprovenProof{File: file, Reason: "its asset sum is blinded anew"})
+		}
+		return generateProof(elements, context, counting, config.keysDir)
+	}
+
+	// the batch's roots are recomputed rather than trusted from the secret data, and a batch whose recorded
+	// AssetSum is wrong fails to prove as it would without reuse
+	assetSum := sumBalances(elements.Accounts, elements.AssetSum)
+	if elements.AssetSum == nil || !elements.AssetSum.Equals(assetSum) {
+		return generateProof(elements, context, counting, config.keysDir)
+	}
+	actual := elements
+	actual.MerkleRoot = circuit.GoComputeMerkleRootFromAccounts(elements.Accounts)
+
+	previousFile := resolveProofFile(proofFilePath(filepath.Join(config.reuseDir, name), index))
+	previous, err := readCompletedProof(previousFile)
+	if err == nil {
+		shape := shapeOf(elements, counting, context)
+		err = reuseMismatch(previous, actual, context, shape, encodeVerifyingKey(provingKeys(shape, config.keysDir).vk))
+	}
+	if err != nil {
+		if config.reuse != nil {
+			config.reuse.Reproven = append(config.reuse.Reproven, ReprovenProof{File: file, Reason: err.Error()})
+		}
+		return generateProof(elements, context, counting, config.keysDir)
+	}
+	if config.reuse != nil {
+		config.reuse.Reused = append(config.reuse.Reused, file)
+	}
+	previous.Version = SchemaVersion
+	previous.AssetSum = &assetSum
+	return previous
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+func TestProveReusesUnchangedProofs(t *testing.T) {
+	assert := test.NewAssert(t)
+	first, second := generatedBatch(4, 11), generatedBatch(4, 12)
+	writeSecretData(t, first, second)
+	keysDir := t.TempDir()
+	context := NewEpochContext(1, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), "BitGo")
+
+	_, previousTop := Prove(2, context, nil, WithPersistentKeys(keysDir))
+	_, err := os.Stat(keyFilePrefix(keysDir, circuitShape{accountCount: 4, binding: circuit.Unbound}) + provingKeyExtension)
+	assert.NoError(err, "the keys should be kept")
+
+	// the second batch changes before the epoch is published
+	second.Accounts[1].Balance.Bitcoin.Add(&second.Accounts[1].Balance.Bitcoin, big.NewInt(7))
+	second.AssetSum.Bitcoin.Add(&second.AssetSum.Bitcoin, big.NewInt(7))
+	second.MerkleRoot, second.MerkleRootWithAssetSumHash = nil, nil
+	assert.NoError(writeJson(proofFilePath(secretDataPrefix, 1), second))
+
+	var report ReuseReport
+	bottomLevelProofs, top := Prove(2, context, nil, WithPersistentKeys(keysDir), WithReusedProofs(publicDir, &report))
+	assert.Equal([]string{proofFilePath(bottomLevelProofPrefix, 0)}, report.Reused)
+	assert.Equal(3, len(report.Reproven), "the changed batch and the chain above it")
+	assert.Equal("its inputs changed", report.Reproven[0].Reason)
+	assert.Equal(previousTop.AssetSum.Bitcoin.Int64()+7, top.AssetSum.Bitcoin.Int64())
+
+	verification := Verify(2, second.Accounts[1])
+	assert.True(verification.Passed(), verification.Failures())
+	assert.Equal(bottomLevelProofs[0].VK, bottomLevelProofs[1].VK, "every proof of a batch size shares the persistent key")
+
+	// the next snapshot is another epoch, which only the top level proof is bound to
+	report = ReuseReport{}
+	next := NewEpochContext(2, time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), "BitGo")
+	nextBottomLevelProofs, nextTop := Prove(2, next, nil, WithPersistentKeys(keysDir), WithReusedProofs(publicDir, &report))
+	assert.Equal([]string{proofFilePath(bottomLevelProofPrefix, 0), proofFilePath(bottomLevelProofPrefix, 1), proofFilePath(midLevelProofPrefix, 0)}, report.Reused)
+	assert.Equal(1, len(report.Reproven))
+	assert.Equal(proofFilePath(topLevelProofPrefix, 0), report.Reproven[0].File)
+	assert.Contains(report.Reproven[0].Reason, "epoch binding")
+	assert.Equal(bottomLevelProofs[1].Proof, nextBottomLevelProofs[1].Proof, "the unchanged batch keeps its proof")
+	assert.Equal(next, nextTop.EpochContext)
+
+	verification = Verify(2, second.Accounts[1], WithExpectedEpoch(next))
+	assert.True(verification.Passed(), verification.Failures())
+	assert.False(Verify(2, second.Accounts[1], WithExpectedEpoch(context)).Passed(), "the top level proof is bound to the new epoch")
+
+	assert.Panics(func() { Prove(2, next, nil, WithReusedProofs(publicDir, nil)) }, "proofs made with fresh keys cannot be reused")
+}
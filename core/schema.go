@@ -0,0 +1,120 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+// SchemaVersion is the version of the serialized types written by this package: CompletedProof,
+// ProofElements, PublishedStatement, Attestation, Endorsement(s), AssetSumOpening, ReservesStatement,
+// SolvencyCertificate and a user's circuit.GoAccount. Files written before the types were versioned have
+// no Version and are read as version 0. It is bumped whenever a field is added to any of them, so that a
+// reader can tell a file lacking the field from one written before it existed.
+const SchemaVersion = 3
+
+const (
+	// circuitIdV1 is the circuit every proof was made with before proofs recorded their circuit.
+	circuitIdV1 = "v1-mimc-bn254-depth10-btc-eth"
+	// circuitIdV2 added the epoch as a public input.
+	circuitIdV2 = "v2-mimc-bn254-depth10-btc-eth-epoch"
+	// circuitIdV3 added the snapshot timestamp and issuer identifier hash as public inputs.
+	circuitIdV3 = circuit.CircuitId
+	// circuitIdV4 is circuitIdV3 counting the ledger accounts in the asset sum, for epochs proven with their account count.
+	circuitIdV4 = circuit.CountingCircuitId
+	// circuitIdV5 is the top level circuit publishing a commitment to the totals, proven at most the reserves, instead of them.
+	circuitIdV5 = circuit.HiddenTotalCircuitId
+	// circuitIdV5Blinded is circuitIdV3Unbound hashing a blinding factor with the asset sum, for the lower level
+	// proofs of epochs proven with circuitIdV5.
+	circuitIdV5Blinded = circuit.BlindedCircuitId
+	// circuitIdV6 is circuitIdV3 for ledgers of margin accounts, proven of non-negative net value at public prices.
+	circuitIdV6 = circuit.CrossMarginCircuitId
+	// circuitIdV7 is circuitIdV3 with the subtotal of each segment in the asset sum, for epochs proven with segments.
+	circuitIdV7 = circuit.SegmentedCircuitId
+	// circuitIdV3Unbound, circuitIdV4Unbound, circuitIdV6Unbound and circuitIdV7Unbound are those circuits without
+	// the epoch context, for lower level proofs, which only the top level proof binds to it.
+	circuitIdV3Unbound = circuitIdV3 + circuit.UnboundSuffix
+	circuitIdV4Unbound = circuitIdV4 + circuit.UnboundSuffix
+	circuitIdV6Unbound = circuitIdV6 + circuit.UnboundSuffix
+	circuitIdV7Unbound = circuitIdV7 + circuit.UnboundSuffix
+)
+
+// schemaUpgrades bring a value read from a file of a past schema version up to the current one.
+// Every version that has ever been written must keep an entry here so that old epochs stay readable.
+var schemaUpgrades = map[int]func(target any){
+	0: upgradeFromUnversioned,
+	1: func(target any) {},
+	// version 2 added the epoch to proofs and the timestamp and previous statement hash to statements,
+	// which are left unset for earlier files
+	2: func(target any) {},
+	// version 3 added the snapshot timestamp and issuer to proofs and statements, the account count, gross
+	// total, prices, segment subtotals and blinding factor to asset sums, the asset sum commitment and reserves to top level
+	// proofs, the segment names to statements and the reserves and solvency files, none of which earlier files have
+	3: func(target any) {},
+}
+
+// upgradeFromUnversioned records the circuit that unversioned files were made with, which was left implicit.
+func upgradeFromUnversioned(target any) {
+	switch value := target.(type) {
+	case *CompletedProof:
+		value.CircuitId = circuitIdV1
+	case *ProofElements:
+		value.CircuitId = circuitIdV1
+	case *circuit.GoAccount:
+		value.CircuitId = circuitIdV1
+	}
+}
+
+func setSchemaVersion(target any, version int) {
+	switch value := target.(type) {
+	case *CompletedProof:
+		value.Version = version
+	case *ProofElements:
+		value.Version = version
+	case *circuit.GoAccount:
+		value.Version = version
+	case *PublishedStatement:
+		value.Version = version
+	case *Attestation:
+		value.Version = version
+	case *Endorsement:
+		value.Version = version
+	case *Endorsements:
+		value.Version = version
+	case *AssetSumOpening:
+		value.Version = version
+	case *ReservesStatement:
+		value.Version = version
+	case *SolvencyCertificate:
+		value.Version = version
+	}
+}
+
+// upgradeSchema upgrades target, read from a file of the given schema version, to the current schema version.
+func upgradeSchema(version int, target any) error {
+	upgrade, ok := schemaUpgrades[version]
+	if !ok {
+		return fmt.Errorf("unsupported schema version %d, this build reads versions up to %d", version, SchemaVersion)
+	}
+	upgrade(target)
+	setSchemaVersion(target, SchemaVersion)
+	return nil
+}
+
+// decodeVersionedJson decodes a JSON document of any supported schema version into target, a pointer to one of the current types.
+func decodeVersionedJson(data []byte, target any) error {
+	var header struct{ Version int }
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return err
+	}
+	if _, ok := schemaUpgrades[header.Version]; !ok {
+		return fmt.Errorf("unsupported schema version %d, this build reads versions up to %d", header.Version, SchemaVersion)
+	}
+	err = json.Unmarshal(data, target)
+	if err != nil {
+		return err
+	}
+	return upgradeSchema(header.Version, target)
+}
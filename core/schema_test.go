@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+func TestReadUnversionedFiles(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// the testdata files were written before proofs were versioned
+	assert.Equal(SchemaVersion, proofLower0.Version)
+	assert.Equal(circuitIdV1, proofLower0.CircuitId)
+	elements := ReadDataFromFile[ProofElements]("testdata/test_data_0.json")
+	assert.Equal(SchemaVersion, elements.Version)
+	assert.Equal(circuitIdV1, elements.CircuitId)
+	assert.True(VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop).Passed())
+}
+
+func TestReadUnsupportedSchemaVersion(t *testing.T) {
+	assert := test.NewAssert(t)
+	var proof CompletedProof
+
+	err := decodeVersionedJson([]byte(`{"Version": 99, "CircuitId": "v99"}`), &proof)
+	assert.Error(err)
+	assert.True(strings.Contains(err.Error(), "unsupported schema version 99"))
+}
+
+func TestVerifyProofWithUnknownCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	proof := proofLower0
+	proof.CircuitId = "v0-unknown"
+
+	assert.Panics(func() { verifyProof(proof) }, "should panic when the circuit is unknown")
+}
+
+func TestMigrateFiles(t *testing.T) {
+	assert := test.NewAssert(t)
+	inDir, outDir := t.TempDir(), t.TempDir()
+	copyTestProofs(t, inDir)
+	b, err := os.ReadFile("testdata/test_data_0.json")
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(filepath.Join(inDir, "test_data_0.json"), b, 0o644))
+	assert.NoError(os.WriteFile(filepath.Join(inDir, "notes.txt"), []byte("kept"), 0o644))
+
+	written, err := MigrateFiles(inDir, outDir)
+	assert.NoError(err)
+	assert.Equal(6, len(written))
+
+	for _, name := range []string{"test_proof_0.json", "test_top_level_proof_0.json", "test_data_0.json"} {
+		migrated, err := os.ReadFile(filepath.Join(outDir, name))
+		assert.NoError(err)
+		assert.True(strings.Contains(string(migrated), fmt.Sprintf(`"Version": %d`, SchemaVersion)), name+" should be written at the current version")
+		assert.True(strings.Contains(string(migrated), circuitIdV1), name+" should keep the circuit it was made with")
+	}
+	kept, err := os.ReadFile(filepath.Join(outDir, "notes.txt"))
+	assert.NoError(err)
+	assert.Equal("kept", string(kept))
+
+	report := VerifyProofPathInDir(proofLower0.AccountLeaves[0], outDir)
+	assert.True(report.Passed())
+	account := ReadDataFromFile[ProofElements](filepath.Join(outDir, "test_data_0.json")).Accounts[0]
+	assert.Equal(proofLower0.AccountLeaves[0], circuit.GoComputeMiMCHashForAccount(account))
+}
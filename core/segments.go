@@ -0,0 +1,90 @@
+package core
+
+import (
+	"fmt"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+)
+
+// SegmentNames names the segments of a segmented ledger, in the order of their tags. The published statement records
+// them, so that its subtotals are read by the names they were proven under.
+var SegmentNames = [circuit.SegmentCount]string{"retail", "institutional", "house"}
+
+// WithSegments proves the liabilities of each segment along with the grand total: every ledger account must be
+// tagged with the Segment it belongs to, every proof is made with circuit.SegmentedCircuitId or its unbound variant,
+// and the top level proof's AssetSum, like the published statement's, has the Segments subtotals of the epoch. The
+// account count, cross-margin ledgers and hidden totals cannot be proven along with segments.
+func WithSegments() ProveOption {
+	return func(config *proveConfig) {
+		config.segments = true
+	}
+}
+
+// SegmentAccounts checks every account of the batches is tagged with a segment, and adds the subtotal of each
+// segment to the batch's recorded asset sum, so that the batches are proven with the segmented circuit. The
+// recorded asset sums are otherwise left as they are.
+func SegmentAccounts(batches []ProofElements) []ProofElements {
+	segmented := make([]ProofElements, len(batches))
+	for i, batch := range batches {
+		for j, account := range batch.Accounts {
+			if account.Balance.Segment == nil {
+				panic(fmt.Sprintf("account %d of batch %d (user id %x) is not tagged with a segment", j, i, account.UserId))
+			}
+		}
+		segmented[i] = batch
+		if batch.AssetSum != nil {
+			assetSum := *batch.AssetSum
+			assetSum.Segments = circuit.SumGoAccountBalances(batch.Accounts).Segments
+			segmented[i].AssetSum = &assetSum
+		}
+		// the recorded root with the asset sum did not commit to the subtotals
+		segmented[i].MerkleRootWithAssetSumHash = nil
+	}
+	return segmented
+}
+
+// verifyUnsegmented checks no account of the batches is tagged with a segment, as the ledger is not proven with them.
+func verifyUnsegmented(batches []ProofElements) {
+	for i, batch := range batches {
+		for j, account := range batch.Accounts {
+			if account.Balance.Segment != nil {
+				panic(fmt.Sprintf("account %d of batch %d is tagged with a segment, but the ledger is not proven with segments", j, i))
+			}
+		}
+	}
+}
+
+func newSegmentedWitness(elements ProofElements, context *EpochContext) witness.Witness {
+	witnessInput := circuit.SegmentedCircuit{
+		Accounts:                   circuit.ConvertGoAccountsToAccounts(elements.Accounts),
+		AccountSegments:            circuit.ConvertGoAccountSegments(elements.Accounts),
+		AccountSubtotals:           circuit.ConvertGoAccountSubtotals(elements.Accounts),
+		AssetSum:                   circuit.ConvertGoBalanceToBalance(*elements.AssetSum),
+		Subtotals:                  circuit.ConvertGoSubtotals(elements.AssetSum.Segments),
+		MerkleRoot:                 elements.MerkleRoot,
+		MerkleRootWithAssetSumHash: elements.MerkleRootWithAssetSumHash,
+		Context:                    context.witness(),
+	}
+	witness, err := frontend.NewWitness(&witnessInput, ecc.BN254.ScalarField())
+	if err != nil {
+		panic(err)
+	}
+	return witness
+}
+
+// addSegments adds the subtotals of assetSum to those of total, if it has them.
+func addSegments(total *circuit.GoBalance, assetSum circuit.GoBalance) {
+	if assetSum.Segments == nil {
+		return
+	}
+	if total.Segments == nil {
+		total.Segments = make([]circuit.GoBalance, len(assetSum.Segments))
+	}
+	for k := range assetSum.Segments {
+		total.Segments[k].Bitcoin.Add(&total.Segments[k].Bitcoin, &assetSum.Segments[k].Bitcoin)
+		total.Segments[k].Ethereum.Add(&total.Segments[k].Ethereum, &assetSum.Segments[k].Ethereum)
+	}
+}
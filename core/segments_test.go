@@ -0,0 +1,66 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+// segmentedBatch is a generated batch whose accounts are spread over the segments in turn.
+func segmentedBatch(count int, seed int) ProofElements {
+	batch := generatedBatch(count, seed)
+	for i := range batch.Accounts {
+		batch.Accounts[i].Balance.Segment = big.NewInt(int64(i % circuit.SegmentCount))
+	}
+	batch.MerkleRoot, batch.MerkleRootWithAssetSumHash = nil, nil
+	return batch
+}
+
+func checkSegments(assert *test.Assert, ledger []ProofElements, _ []CompletedProof, top CompletedProof, statement PublishedStatement) {
+	retail := new(big.Int)
+	for _, batch := range ledger {
+		for i := 0; i < len(batch.Accounts); i += circuit.SegmentCount {
+			retail.Add(retail, &batch.Accounts[i].Balance.Bitcoin)
+		}
+	}
+	assert.Equal(circuit.SegmentCount, len(top.AssetSum.Segments))
+	assert.Equal(0, top.AssetSum.Segments[0].Bitcoin.Cmp(retail), "the retail subtotal should hold the first of every three accounts")
+	assert.Equal([]string{"retail", "institutional", "house"}, statement.SegmentNames)
+
+	// moving liabilities between segments leaves the total but not the proven subtotals
+	account := ledger[1].Accounts[0]
+	moved := big.NewInt(1)
+	statement.AssetSum.Segments[0].Bitcoin.Sub(&statement.AssetSum.Segments[0].Bitcoin, moved)
+	statement.AssetSum.Segments[1].Bitcoin.Add(&statement.AssetSum.Segments[1].Bitcoin, moved)
+	assert.False(VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(account), publicDir, WithPublishedStatement(statement)).Passed())
+	statement.AssetSum.Segments[0].Bitcoin.Add(&statement.AssetSum.Segments[0].Bitcoin, moved)
+	statement.AssetSum.Segments[1].Bitcoin.Sub(&statement.AssetSum.Segments[1].Bitcoin, moved)
+	assert.True(VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(account), publicDir, WithPublishedStatement(statement)).Passed())
+	unnamed := statement
+	unnamed.SegmentNames = statement.SegmentNames[:2]
+	assert.False(VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(account), publicDir, WithPublishedStatement(unnamed)).Passed(), "every subtotal must be named")
+	top.AssetSum.Segments = nil
+	assert.Panics(func() { verifyTopLayerProofMatchesAssetSum(top) }, "a segmented top level proof must publish its subtotals")
+}
+
+func TestSegmentsTagEveryAccount(t *testing.T) {
+	assert := test.NewAssert(t)
+	ledger := []ProofElements{segmentedBatch(4, 41), segmentedBatch(3, 42)}
+	context := NewEpochContext(1, time.Now(), "BitGo")
+	writeSecretData(t, ledger...)
+	unsegmented := ValidateLedger(secretDir)
+	for _, kind := range issueKinds(unsegmented) {
+		assert.Equal(IssueSegment, kind)
+	}
+	assert.Panics(func() { Prove(2, context, nil) }, "tagged accounts cannot be proven without segments")
+
+	// every account must be in a segment
+	ledger[0].Accounts[1].Balance.Segment = big.NewInt(circuit.SegmentCount)
+	writeSecretData(t, ledger...)
+	assert.Equal([]IssueKind{IssueSegment}, issueKinds(ValidateLedger(secretDir, WithSegments())))
+	assert.Panics(func() { Prove(2, context, nil, WithSegments()) })
+	assert.Panics(func() { Prove(2, context, nil, WithSegments(), WithAccountCount()) })
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"crypto/sha256"
+	"fmt"
+	mathrand "math/rand/v2"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+// shuffleDomain separates the shuffle's randomness from any other use of the seed
+const shuffleDomain = "bgproof leaf placement v1"
+
+// shufflePermutation returns the permutation of count elements for seed: a Fisher-Yates shuffle, from the last element
+// down, drawing each index uniformly by rejection sampling from the ChaCha8 generator keyed with
+// SHA-256(shuffleDomain || seed). Every step is specified, so auditors can reproduce it from the seed.
+func shufflePermutation(count int, seed []byte) []int {
+	key := sha256.Sum256(append([]byte(shuffleDomain), seed...))
+	source := mathrand.NewChaCha8(key)
+	uniform := func(n uint64) uint64 {
+		limit := ^uint64(0) - ^uint64(0)%n
+		for {
+			if v := source.Uint64(); v < limit {
+				return v % n
+			}
+		}
+	}
+	permutation := make([]int, count)
+	for i := range permutation {
+		permutation[i] = i
+	}
+	for i := count - 1; i > 0; i-- {
+		j := uniform(uint64(i + 1))
+		permutation[i], permutation[j] = permutation[j], permutation[i]
+	}
+	return permutation
+}
+
+// ShuffleAccounts places the accounts of every batch at the positions given by seed, across all batches, so that
+// batch and leaf indices do not reveal the order of the ledger. Each batch keeps its number of accounts, and its
+// asset sum is recomputed; the recorded asset sum of every input batch must be right.
+func ShuffleAccounts(batches []ProofElements, seed []byte) []ProofElements {
+	accounts := make([]circuit.GoAccount, 0)
+	for i, batch := range batches {
+		sum := sumBalances(batch.Accounts, batch.AssetSum)
+		if batch.AssetSum == nil || !batch.AssetSum.Equals(sum) {
+			panic(fmt.Sprintf("Asset sum does not match in batch %d", i))
+		}
+		accounts = append(accounts, batch.Accounts...)
+	}
+	permutation := shufflePermutation(len(accounts), seed)
+	shuffled := make([]ProofElements, len(batches))
+	next := 0
+	for i, batch := range batches {
+		shuffled[i] = ProofElements{Version: batch.Version, CircuitId: batch.CircuitId, Accounts: make([]circuit.GoAccount, len(batch.Accounts))}
+		for j := range shuffled[i].Accounts {
+			shuffled[i].Accounts[j] = accounts[permutation[next]]
+			next++
+		}
+		assetSum := sumBalances(shuffled[i].Accounts, batch.AssetSum)
+		shuffled[i].AssetSum = &assetSum
+	}
+	return shuffled
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+func TestShufflePermutation(t *testing.T) {
+	assert := test.NewAssert(t)
+	// pinned so that a change to the algorithm, which auditors reproduce from the seed, is noticed
+	assert.Equal([]int{4, 8, 6, 3, 9, 7, 1, 5, 0, 2}, shufflePermutation(10, []byte("seed")))
+	assert.NotEqual(shufflePermutation(10, []byte("seed")), shufflePermutation(10, []byte("other seed")))
+
+	permutation := shufflePermutation(1000, GenerateEpochSeed())
+	sorted := append([]int(nil), permutation...)
+	sort.Ints(sorted)
+	for i, v := range sorted {
+		assert.Equal(i, v)
+	}
+}
+
+func TestShuffleAccounts(t *testing.T) {
+	assert := test.NewAssert(t)
+	batches := []ProofElements{generatedBatch(5, 11), generatedBatch(3, 12)}
+	shuffled := ShuffleAccounts(batches, []byte("seed"))
+	assert.Equal(5, len(shuffled[0].Accounts))
+	assert.Equal(3, len(shuffled[1].Accounts))
+	assert.Equal(shuffled, ShuffleAccounts(batches, []byte("seed")), "the placement should be reproducible from the seed")
+
+	leaves := func(batches []ProofElements) []string {
+		all := make([]string, 0)
+		for _, batch := range batches {
+			for _, account := range batch.Accounts {
+				all = append(all, string(circuit.GoComputeMiMCHashForAccount(account)))
+			}
+			sum := circuit.SumGoAccountBalances(batch.Accounts)
+			assert.True(batch.AssetSum.Equals(sum))
+		}
+		sort.Strings(all)
+		return all
+	}
+	assert.Equal(leaves(batches), leaves(shuffled), "every account should be placed exactly once")
+
+	batches[1].AssetSum.Bitcoin.SetInt64(0)
+	assert.Panics(func() { ShuffleAccounts(batches, []byte("seed")) })
+}
+
+func TestProveWithPlacedAccounts(t *testing.T) {
+	assert := test.NewAssert(t)
+	ledger := []ProofElements{generatedBatch(4, 11), generatedBatch(3, 12)}
+	writeSecretData(t, ledger...)
+	placement := Placement{Seed: []byte("epoch seed"), PadTo: 8, Shuffle: true}
+
+	bottomLevelProofs, top := Prove(2, NewEpochContext(1, time.Now(), "BitGo"), nil, WithPlacement(placement))
+	for _, proof := range bottomLevelProofs {
+		assert.Equal(8, len(proof.AccountLeaves), "every published batch should be full")
+	}
+	assert.NotEqual(computeAccountLeavesFromAccounts(ledger[0].Accounts), bottomLevelProofs[0].AccountLeaves[:4])
+	total := circuit.SumGoAccountBalances(append(append([]circuit.GoAccount{}, ledger[0].Accounts...), ledger[1].Accounts...))
+	assert.True(top.AssetSum.Equals(total), "dummy accounts should add nothing")
+
+	account := ledger[1].Accounts[2]
+	report := VerifyProofPathInDir(circuit.GoComputeMiMCHashForAccount(account), publicDir)
+	assert.True(report.Passed(), report.Failures())
+
+	secret, public := filepath.Clean(secretDir), filepath.Clean(publicDir)
+	report = Audit(secret, public, placement)
+	assert.True(report.Passed(), report.Failures())
+	assert.False(Audit(secret, public, Placement{}).Passed(), "without the seed the batches do not match")
+}
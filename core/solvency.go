@@ -0,0 +1,271 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"math/big"
+	"path/filepath"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+const (
+	solvencyCertificateName = "solvency_certificate.json"
+
+	// reservesDomain separates reserves statement signatures from any other use of the issuer key
+	reservesDomain = "bgproof reserves statement v1\n"
+)
+
+// ReservesStatement is an exchange's statement of the reserves of each asset it holds at the snapshot of an epoch,
+// whose context the liabilities must have been proven for. A signed statement is linked to the published statement
+// of the epoch's liabilities by its hash; see SignReservesStatement.
+type ReservesStatement struct {
+	Version int
+	EpochContext
+	Reserves circuit.GoBalance
+	// Blocks are the blocks of each chain the reserves were read at
+	Blocks []SnapshotBlock `json:",omitempty"`
+	// Challenge is the message of the epoch that every custody address signed
+	Challenge string `json:",omitempty"`
+	// CustodyAddresses are the addresses the reserves are held at, with their signatures of Challenge
+	CustodyAddresses []CustodyAddress `json:",omitempty"`
+	// LiabilitiesStatementHash is the Hash of the published statement of the epoch
+	LiabilitiesStatementHash []byte `json:",omitempty"`
+	IssuerPublicKey          []byte `json:",omitempty"`
+	Signature                []byte `json:",omitempty"`
+}
+
+// CustodyAddress is an address the exchange holds Asset at. Signature, of the challenge of the epoch, shows the
+// exchange controls it.
+type CustodyAddress struct {
+	Asset     string
+	Address   string
+	Signature string `json:",omitempty"`
+}
+
+// OwnershipVerifier checks every custody address of statement signed its Challenge, which must be the challenge of
+// the epoch of liabilities. reserves.VerifyStatementOwnership is the one for Bitcoin and Ethereum addresses.
+type OwnershipVerifier func(statement ReservesStatement, liabilities PublishedStatement) error
+
+// SnapshotBlock is the block of the chain of Asset that its reserves were read at.
+type SnapshotBlock struct {
+	Asset  string
+	Height uint64
+	Hash   string
+}
+
+// AssetCoverage is how well the reserves of one asset cover its liabilities.
+type AssetCoverage struct {
+	Asset string
+	// Liabilities is nil when the top level proof hides its totals
+	Liabilities *big.Int `json:",omitempty"`
+	Reserves    big.Int
+	// CoverageRatio is the reserves over the liabilities with 4 decimals, or a lower bound of it when the liabilities
+	// are hidden. It is "unbounded" when the liabilities are not positive, as the net liabilities of a cross-margin
+	// ledger can be.
+	CoverageRatio string
+	// GrossLiabilities, of a cross-margin ledger, is the total of the positive balances alone, which the reserves are
+	// also compared with in GrossCoverageRatio, without being required to cover it
+	GrossLiabilities   *big.Int `json:",omitempty"`
+	GrossCoverageRatio string   `json:",omitempty"`
+	// ProvenInCircuit is set when the top level proof's SNARK proves the liabilities are at most the reserves, rather
+	// than the published liabilities being compared to them
+	ProvenInCircuit bool
+}
+
+// SolvencyCertificate shows, per asset, that the reserves of an epoch cover the liabilities proven by its top level
+// proof.
+type SolvencyCertificate struct {
+	Version   int
+	CircuitId string
+	EpochContext
+	MerkleRoot []byte
+	Assets     []AssetCoverage
+}
+
+func ReadReservesStatementFromFile(filePath string) (statement ReservesStatement, err error) {
+	err = readVersionedJson(filePath, &statement)
+	return statement, err
+}
+
+func WriteReservesStatementToFile(filePath string, statement ReservesStatement) error {
+	return writeJson(filePath, &statement)
+}
+
+func WriteSolvencyCertificateToFile(filePath string, certificate SolvencyCertificate) error {
+	return writeJson(filePath, &certificate)
+}
+
+func (statement ReservesStatement) signedMessage() []byte {
+	// the signature must not change when a statement is migrated to a newer schema version
+	statement.Version = 0
+	statement.Signature = nil
+	encoded, err := cborEncoding.Marshal(statement)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte(reservesDomain), encoded...)
+}
+
+// SignReservesStatement links statement to liabilities, the published statement of the same epoch, and signs it
+// with the issuer's key.
+func SignReservesStatement(statement ReservesStatement, liabilities PublishedStatement, privateKey ed25519.PrivateKey) ReservesStatement {
+	statement.Version = SchemaVersion
+	statement.EpochContext = liabilities.EpochContext
+	statement.LiabilitiesStatementHash = liabilities.Hash()
+	statement.IssuerPublicKey = privateKey.Public().(ed25519.PublicKey)
+	statement.Signature = ed25519.Sign(privateKey, statement.signedMessage())
+	return statement
+}
+
+// verifyReservesStatement checks statement is linked to liabilities, the published statement matching the top level
+// proof, and signed by the issuer: by issuerKey if it is given, and by the key the statement names otherwise.
+func verifyReservesStatement(statement ReservesStatement, liabilities PublishedStatement, topLevelProof CompletedProof, issuerKey ed25519.PublicKey) {
+	if !bytes.Equal(statement.LiabilitiesStatementHash, liabilities.Hash()) {
+		panic("reserves statement is not linked to the published statement")
+	}
+	verifyTopLayerProofMatchesStatement(topLevelProof, liabilities)
+	if issuerKey != nil && !bytes.Equal(statement.IssuerPublicKey, issuerKey) {
+		panic(fmt.Sprintf("reserves statement is signed by %x, not the expected issuer %x", statement.IssuerPublicKey, []byte(issuerKey)))
+	}
+	if statement.Signature == nil {
+		panic("reserves statement is not signed")
+	}
+	if len(statement.IssuerPublicKey) != ed25519.PublicKeySize {
+		panic("reserves statement does not name the key it is signed with")
+	}
+	if !ed25519.Verify(statement.IssuerPublicKey, statement.signedMessage(), statement.Signature) {
+		panic("reserves statement signature is invalid")
+	}
+}
+
+// verifyCustody checks statement lists the custody addresses of its reserves, and that verifyOwnership accepts their
+// signatures of the challenge of the epoch of liabilities.
+func verifyCustody(statement ReservesStatement, liabilities PublishedStatement, verifyOwnership OwnershipVerifier) {
+	if len(statement.CustodyAddresses) == 0 {
+		panic("reserves statement lists no custody addresses")
+	}
+	if verifyOwnership == nil {
+		panic("no ownership verifier is given for the custody addresses")
+	}
+	if err := verifyOwnership(statement, liabilities); err != nil {
+		panic(err)
+	}
+}
+
+// SolvencyCertificateFile is where the solvency certificate of the proofs in proofsDir is written by default.
+func SolvencyCertificateFile(proofsDir string) string {
+	return filepath.Join(proofsDir, solvencyCertificateName)
+}
+
+// assetNames names the assets of a balance, in the order of assetAmounts.
+var assetNames = []string{"Bitcoin", "Ethereum"}
+
+func assetAmounts(balance *circuit.GoBalance) []*big.Int {
+	return []*big.Int{&balance.Bitcoin, &balance.Ethereum}
+}
+
+// coverageRatio formats reserves over liabilities with 4 decimals. Net liabilities of a cross-margin ledger can be
+// negative, when its users owe more of an asset than they hold, and are then covered without reserves.
+func coverageRatio(reserves *big.Int, liabilities *big.Int) string {
+	if liabilities.Sign() <= 0 {
+		return "unbounded"
+	}
+	return new(big.Rat).SetFrac(reserves, liabilities).FloatString(4)
+}
+
+// newSolvencyCertificate compares the liabilities of topLevelProof with the reserves of statement, per asset, and
+// panics unless every asset is covered. When the proof hides its totals, its SNARK proves they are at most the
+// reserves it publishes, which must then be at most those of the statement. The liabilities of a cross-margin ledger
+// are its net liabilities, which its gross liabilities are reported along with.
+func newSolvencyCertificate(topLevelProof CompletedProof, statement ReservesStatement) SolvencyCertificate {
+	if statement.Epoch != topLevelProof.Epoch {
+		panic(fmt.Sprintf("reserves statement is for epoch %d, the proof for epoch %d", statement.Epoch, topLevelProof.Epoch))
+	}
+	hidden := circuits[topLevelProof.CircuitId].hidesTotal
+	var bounds *circuit.GoBalance
+	if hidden {
+		verifyHiddenTotal(topLevelProof)
+		bounds = topLevelProof.Reserves
+	} else {
+		if topLevelProof.AssetSum == nil {
+			panic("top layer proof asset sum is nil")
+		}
+		bounds = topLevelProof.AssetSum
+	}
+	certificate := SolvencyCertificate{
+		Version:      SchemaVersion,
+		CircuitId:    topLevelProof.CircuitId,
+		EpochContext: topLevelProof.EpochContext,
+		MerkleRoot:   topLevelProof.MerkleRoot,
+		Assets:       make([]AssetCoverage, len(assetNames)),
+	}
+	reserves := assetAmounts(&statement.Reserves)
+	for i, bound := range assetAmounts(bounds) {
+		if bound.Cmp(reserves[i]) > 0 {
+			if hidden {
+				panic(fmt.Sprintf("%s reserves of %s are below the %s the proof shows the liabilities are at most", assetNames[i], reserves[i], bound))
+			}
+			panic(fmt.Sprintf("%s liabilities of %s exceed the reserves of %s", assetNames[i], bound, reserves[i]))
+		}
+		coverage := AssetCoverage{Asset: assetNames[i], ProvenInCircuit: hidden}
+		coverage.Reserves.Set(reserves[i])
+		coverage.CoverageRatio = coverageRatio(reserves[i], bound)
+		if !hidden {
+			coverage.Liabilities = new(big.Int).Set(bound)
+		}
+		if bounds.Gross != nil {
+			gross := assetAmounts(bounds.Gross)[i]
+			coverage.GrossLiabilities = new(big.Int).Set(gross)
+			coverage.GrossCoverageRatio = coverageRatio(reserves[i], gross)
+		}
+		certificate.Assets[i] = coverage
+	}
+	return certificate
+}
+
+// CertifySolvency checks that the reserves of statement cover the liabilities proven by the top level proof in
+// proofsDir, for every asset. The whole proof set in proofsDir is first verified as Verify does, pinned to the
+// statement published with it and to the epoch context of the reserves. The reserves statement must be linked to
+// the statement published in proofsDir and signed: by issuerKey if it is given, which pins the issuer, and otherwise
+// by the key it names. Each of its custody addresses must have signed the challenge of the epoch, as verifyOwnership
+// checks. The certificate is only made once every check passes, and is only complete if the returned report passed.
+func CertifySolvency(proofsDir string, statement ReservesStatement, issuerKey ed25519.PublicKey, verifyOwnership OwnershipVerifier) (certificate SolvencyCertificate, report VerificationReport) {
+	report = newVerificationReport()
+	var liabilities PublishedStatement
+	if !report.runCheckIfFails(CheckStatement, filepath.Join(proofsDir, statementName), func() {
+		var err error
+		if liabilities, err = ReadStatementFromDir(proofsDir); err != nil {
+			panic(err)
+		}
+	}) {
+		return certificate, report.finish()
+	}
+	file := resolveProofFile(proofFilePath(filepath.Join(proofsDir, topLevelProofName), 0))
+	var topLevelProof CompletedProof
+	read := report.runCheckIfFails(CheckProofFile, file, func() {
+		var err error
+		if topLevelProof, err = readCompletedProof(file); err != nil {
+			panic(err)
+		}
+	})
+	batchCount := countIndexedFiles(proofsDir, bottomLevelProofName)
+	read = report.runCheckIfFails(CheckProofSetLayout, proofsDir, func() {
+		if batchCount == 0 {
+			panic(fmt.Sprintf("no bottom level proofs found in %s", proofsDir))
+		}
+	}) && read
+	if !read {
+		return certificate, report.finish()
+	}
+	proofSet := verifyProofSet(proofsDir, batchCount, nil, []VerifyOption{WithPublishedStatement(liabilities), WithExpectedEpoch(statement.EpochContext)})
+	report.Checks = append(report.Checks, proofSet.Checks...)
+	report.runCheck(CheckReserves, file, func() { verifyReservesStatement(statement, liabilities, topLevelProof, issuerKey) })
+	report.runCheck(CheckOwnership, file, func() { verifyCustody(statement, liabilities, verifyOwnership) })
+	if !report.Passed() {
+		return certificate, report.finish()
+	}
+	report.runCheck(CheckSolvency, file, func() { certificate = newSolvencyCertificate(topLevelProof, statement) })
+	return certificate, report.finish()
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+// solvencyProofsDir lays out the testdata proofs and the statement published with them in a temporary directory.
+func solvencyProofsDir(t *testing.T) (dir string, liabilities PublishedStatement) {
+	dir = t.TempDir()
+	copyTestProofs(t, dir)
+	liabilities = NewPublishedStatement(0, proofTop)
+	if err := WriteStatementToFile(filepath.Join(dir, statementName), liabilities); err != nil {
+		t.Fatal(err)
+	}
+	return dir, liabilities
+}
+
+// testChallenge stands in for the challenge of the reserves package, which core cannot import.
+func testChallenge(liabilities PublishedStatement) string {
+	return fmt.Sprintf("epoch %d, merkle root %x", liabilities.Epoch, liabilities.MerkleRoot)
+}
+
+// custodiedReserves is a reserves statement of one custody address, whose signature testOwnership accepts.
+func custodiedReserves(reserves circuit.GoBalance, liabilities PublishedStatement) ReservesStatement {
+	challenge := testChallenge(liabilities)
+	return ReservesStatement{
+		Reserves:         reserves,
+		Challenge:        challenge,
+		CustodyAddresses: []CustodyAddress{{Asset: "Bitcoin", Address: "custody", Signature: challenge}},
+	}
+}
+
+// testOwnership accepts custody addresses whose signature is the challenge itself.
+func testOwnership(statement ReservesStatement, liabilities PublishedStatement) error {
+	challenge := testChallenge(liabilities)
+	if statement.Challenge != challenge {
+		return errors.New("not the challenge of the epoch")
+	}
+	for _, address := range statement.CustodyAddresses {
+		if address.Signature != challenge {
+			return fmt.Errorf("%s did not sign the challenge", address.Address)
+		}
+	}
+	return nil
+}
+
+func TestCertifySolvency(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir, liabilities := solvencyProofsDir(t)
+	_, privateKey, err := GenerateSigningKey()
+	assert.NoError(err)
+	sign := func(bitcoin, ethereum int64) ReservesStatement {
+		reserves := circuit.GoBalance{Bitcoin: *big.NewInt(bitcoin), Ethereum: *big.NewInt(ethereum)}
+		return SignReservesStatement(custodiedReserves(reserves, liabilities), liabilities, privateKey)
+	}
+	statement := sign(702432, 54856)
+
+	certificate, report := CertifySolvency(dir, statement, nil, testOwnership)
+	assert.True(report.Passed(), report.Failures())
+	assert.Equal(proofTop.MerkleRoot, certificate.MerkleRoot)
+	assert.Equal("Bitcoin", certificate.Assets[0].Asset)
+	assert.Equal(int64(351216), certificate.Assets[0].Liabilities.Int64())
+	assert.Equal("2.0000", certificate.Assets[0].CoverageRatio)
+	assert.Equal("1.0000", certificate.Assets[1].CoverageRatio)
+	assert.False(certificate.Assets[0].ProvenInCircuit)
+
+	_, report = CertifySolvency(dir, sign(702432, 54855), nil, testOwnership)
+	assert.False(report.Passed(), "reserves short of the liabilities should fail")
+	otherEpoch := statement
+	otherEpoch.Epoch = 1
+	_, report = CertifySolvency(dir, otherEpoch, nil, testOwnership)
+	assert.False(report.Passed(), "reserves of another epoch should fail")
+
+	// the reserves must be signed, by the key the statement names unless the issuer's key is pinned
+	unsigned := statement
+	unsigned.Signature = nil
+	_, report = CertifySolvency(dir, unsigned, nil, testOwnership)
+	assert.False(report.Passed(), "an unsigned statement should fail")
+	unlinked := statement
+	unlinked.LiabilitiesStatementHash = nil
+	_, report = CertifySolvency(dir, unlinked, nil, testOwnership)
+	assert.False(report.Passed(), "a statement not linked to the liabilities should fail")
+
+	// the custody addresses must have signed the challenge of the epoch, which the statement's signature covers
+	_, report = CertifySolvency(dir, statement, nil, nil)
+	assert.False(report.Passed(), "the ownership of the custody addresses must be verified")
+	unowned := SignReservesStatement(ReservesStatement{Reserves: statement.Reserves}, liabilities, privateKey)
+	_, report = CertifySolvency(dir, unowned, nil, testOwnership)
+	assert.False(report.Passed(), "a statement without custody addresses should fail")
+	unowned = custodiedReserves(statement.Reserves, liabilities)
+	unowned.CustodyAddresses[0].Signature = testChallenge(NewPublishedStatement(1, proofTop))
+	_, report = CertifySolvency(dir, SignReservesStatement(unowned, liabilities, privateKey), nil, testOwnership)
+	assert.False(report.Passed(), "an address that signed the challenge of another epoch should fail")
+	moved := statement
+	moved.CustodyAddresses = []CustodyAddress{{Asset: "Bitcoin", Address: "other", Signature: statement.Challenge}}
+	_, report = CertifySolvency(dir, moved, nil, testOwnership)
+	assert.False(report.Passed(), "custody addresses changed after signing should invalidate the signature")
+
+	// every proof of the set must verify, not only the top level one
+	b, err := os.ReadFile("testdata/test_alt_proof_0.json")
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(filepath.Join(dir, "test_proof_1.json"), b, 0o644))
+	certificate, report = CertifySolvency(dir, statement, nil, testOwnership)
+	assert.False(report.Passed(), "a bottom level proof that does not lead to the top level proof should fail")
+	assert.Nil(certificate.Assets, "no certificate should be made for proofs that do not verify")
+	assert.NoError(os.Remove(filepath.Join(dir, statementName)))
+	_, report = CertifySolvency(dir, statement, nil, testOwnership)
+	assert.False(report.Passed(), "proofs without their published statement should fail")
+}
+
+func TestSolvencyCertificateOfHiddenTotal(t *testing.T) {
+	assert := test.NewAssert(t)
+	commitment := circuit.GoCommitToBalance(circuit.GoBalance{}, circuit.GoRandomBlinding())
+	proof := CompletedProof{
+		CircuitId:          circuit.HiddenTotalCircuitId,
+		AssetSumCommitment: &commitment,
+		Reserves:           &circuit.GoBalance{Bitcoin: *big.NewInt(400), Ethereum: *big.NewInt(0)},
+	}
+	statement := ReservesStatement{Reserves: circuit.GoBalance{Bitcoin: *big.NewInt(500), Ethereum: *big.NewInt(7)}}
+
+	certificate := newSolvencyCertificate(proof, statement)
+	assert.Nil(certificate.Assets[0].Liabilities, "hidden liabilities should not be disclosed")
+	assert.Equal("1.2500", certificate.Assets[0].CoverageRatio)
+	assert.Equal("unbounded", certificate.Assets[1].CoverageRatio)
+	assert.True(certificate.Assets[0].ProvenInCircuit)
+
+	statement.Reserves.Bitcoin.SetInt64(399)
+	assert.Panics(func() { newSolvencyCertificate(proof, statement) }, "reserves below the proven bound should fail")
+}
+
+func TestSolvencyCertificateOfCrossMargin(t *testing.T) {
+	assert := test.NewAssert(t)
+	gross := circuit.GoBalance{Bitcoin: *big.NewInt(300), Ethereum: *big.NewInt(40)}
+	proof := CompletedProof{
+		CircuitId: circuit.CrossMarginCircuitId,
+		AssetSum:  &circuit.GoBalance{Bitcoin: *big.NewInt(200), Ethereum: *big.NewInt(-10), Gross: &gross, Prices: &testPrices},
+	}
+	statement := ReservesStatement{Reserves: circuit.GoBalance{Bitcoin: *big.NewInt(250), Ethereum: *big.NewInt(0)}}
+
+	certificate := newSolvencyCertificate(proof, statement)
+	assert.Equal("1.2500", certificate.Assets[0].CoverageRatio)
+	assert.Equal(int64(300), certificate.Assets[0].GrossLiabilities.Int64())
+	assert.Equal("0.8333", certificate.Assets[0].GrossCoverageRatio, "the reserves need not cover the gross liabilities")
+	assert.Equal("unbounded", certificate.Assets[1].CoverageRatio, "net liabilities below zero are covered without reserves")
+	assert.Equal("0.0000", certificate.Assets[1].GrossCoverageRatio)
+}
+
+func TestCertifySolvencyOfSignedReserves(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir, liabilities := solvencyProofsDir(t)
+	publicKey, privateKey, err := GenerateSigningKey()
+	assert.NoError(err)
+	otherKey, otherPrivateKey, err := GenerateSigningKey()
+	assert.NoError(err)
+
+	reserves := circuit.GoBalance{Bitcoin: *big.NewInt(400000), Ethereum: *big.NewInt(60000)}
+	statement := SignReservesStatement(custodiedReserves(reserves, liabilities), liabilities, privateKey)
+	_, report := CertifySolvency(dir, statement, publicKey, testOwnership)
+	assert.True(report.Passed(), report.Failures())
+	_, report = CertifySolvency(dir, statement, otherKey, testOwnership)
+	assert.False(report.Passed(), "a statement signed by another key should fail")
+
+	tampered := statement
+	tampered.Reserves = circuit.GoBalance{Bitcoin: *big.NewInt(500000), Ethereum: *big.NewInt(60000)}
+	_, report = CertifySolvency(dir, tampered, publicKey, testOwnership)
+	assert.False(report.Passed(), "changed reserves should invalidate the signature")
+
+	unlinked := SignReservesStatement(custodiedReserves(reserves, liabilities), NewPublishedStatement(1, proofTop), privateKey)
+	_, report = CertifySolvency(dir, unlinked, publicKey, testOwnership)
+	assert.False(report.Passed(), "a statement linked to other liabilities should fail")
+	_, report = CertifySolvency(dir, ReservesStatement{Reserves: reserves}, publicKey, testOwnership)
+	assert.False(report.Passed(), "an unsigned statement should fail given the issuer key")
+	otherSigned := SignReservesStatement(custodiedReserves(reserves, liabilities), liabilities, otherPrivateKey)
+	_, report = CertifySolvency(dir, otherSigned, nil, testOwnership)
+	assert.True(report.Passed(), "without a pinned key the statement's own key is checked")
+	_, report = CertifySolvency(dir, otherSigned, publicKey, testOwnership)
+	assert.False(report.Passed(), "a pinned key must be the one the statement is signed with")
+}
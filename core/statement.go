@@ -0,0 +1,175 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+const statementFile = publicDir + statementName
+
+// PublishedStatement is what the exchange publicly announces for an epoch. Verifiers pin the
+// top level proof to it so that a self-consistent but different proof set is rejected.
+//
+// Each statement records the hash of the previous epoch's statement, so the statements form a
+// chain and a past epoch cannot be rewritten or dropped without breaking every later link.
+type PublishedStatement struct {
+	Version int
+	EpochContext
+	Timestamp                  time.Time
+	PreviousStatementHash      []byte
+	MerkleRoot                 []byte
+	MerkleRootWithAssetSumHash []byte `json:",omitempty"`
+	// AssetSum is the total liabilities, unless the epoch hides them: then AssetSumCommitment commits to them and
+	// Reserves is what the top level proof proves they are at most
+	AssetSum           *circuit.GoBalance    `json:",omitempty"`
+	AssetSumCommitment *circuit.GoCommitment `json:",omitempty"`
+	Reserves           *circuit.GoBalance    `json:",omitempty"`
+	// SegmentNames names the Segments subtotals of AssetSum, in their order, when the liabilities are proven per segment
+	SegmentNames []string `json:",omitempty"`
+}
+
+func NewPublishedStatement(epoch uint64, topLevelProof CompletedProof) PublishedStatement {
+	if topLevelProof.AssetSum == nil && topLevelProof.AssetSumCommitment == nil {
+		panic("AssetSum is nil, cannot publish statement")
+	}
+	context := topLevelProof.EpochContext
+	context.Epoch = epoch
+	statement := PublishedStatement{
+		Version:                    SchemaVersion,
+		EpochContext:               context,
+		MerkleRoot:                 topLevelProof.MerkleRoot,
+		MerkleRootWithAssetSumHash: topLevelProof.MerkleRootWithAssetSumHash,
+		AssetSum:                   topLevelProof.AssetSum,
+		AssetSumCommitment:         topLevelProof.AssetSumCommitment,
+		Reserves:                   topLevelProof.Reserves,
+	}
+	if topLevelProof.AssetSum != nil && topLevelProof.AssetSum.Segments != nil {
+		statement.SegmentNames = append([]string{}, SegmentNames[:len(topLevelProof.AssetSum.Segments)]...)
+	}
+	return statement
+}
+
+// Hash is the SHA-256 hash of the statement's canonical serialization, recorded by the next epoch's statement.
+func (statement PublishedStatement) Hash() []byte {
+	// the hash must not change when a statement is migrated to a newer schema version
+	statement.Version = 0
+	encoded, err := cborEncoding.Marshal(statement)
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(encoded)
+	return hash[:]
+}
+
+// linkStatement records when statement was made and, unless it is the first epoch, the previous epoch's statement.
+func linkStatement(statement PublishedStatement, previous *PublishedStatement, timestamp time.Time) PublishedStatement {
+	statement.Timestamp = timestamp.UTC().Truncate(time.Second)
+	if previous != nil {
+		statement.PreviousStatementHash = previous.Hash()
+	}
+	return statement
+}
+
+// verifyEpochFollows checks epoch is the one after previous.
+func verifyEpochFollows(previous PublishedStatement, epoch uint64) {
+	if epoch != previous.Epoch+1 {
+		panic(fmt.Sprintf("epoch %d does not follow epoch %d", epoch, previous.Epoch))
+	}
+}
+
+// verifyStatementFollows checks statement is the next link in the chain after previous.
+func verifyStatementFollows(previous PublishedStatement, statement PublishedStatement) {
+	verifyEpochFollows(previous, statement.Epoch)
+	if !statement.Timestamp.After(previous.Timestamp) {
+		panic(fmt.Sprintf("epoch %d timestamp %s is not after epoch %d timestamp %s",
+			statement.Epoch, statement.Timestamp.Format(time.RFC3339), previous.Epoch, previous.Timestamp.Format(time.RFC3339)))
+	}
+	if !bytes.Equal(statement.PreviousStatementHash, previous.Hash()) {
+		panic(fmt.Sprintf("epoch %d does not record the hash of the epoch %d statement", statement.Epoch, previous.Epoch))
+	}
+}
+
+func ReadStatementFromFile(filePath string) (statement PublishedStatement, err error) {
+	err = readVersionedJson(filePath, &statement)
+	return statement, err
+}
+
+// ReadStatementFromDir reads the statement published alongside the proofs in proofsDir.
+func ReadStatementFromDir(proofsDir string) (PublishedStatement, error) {
+	return ReadStatementFromFile(filepath.Join(proofsDir, statementName))
+}
+
+func WriteStatementToFile(filePath string, statement PublishedStatement) error {
+	return writeJson(filePath, &statement)
+}
+
+func verifyTopLayerProofMatchesStatement(topLayerProof CompletedProof, statement PublishedStatement) {
+	if err := epochContextMismatch(topLayerProof, statement.EpochContext); err != nil {
+		panic(fmt.Sprintf("top layer proof does not match the published statement: %s", err))
+	}
+	if !bytes.Equal(topLayerProof.MerkleRoot, statement.MerkleRoot) {
+		panic("top layer merkle root does not match the published statement")
+	}
+	if !bytes.Equal(topLayerProof.MerkleRootWithAssetSumHash, statement.MerkleRootWithAssetSumHash) {
+		panic("top layer merkle root with asset sum hash does not match the published statement")
+	}
+	if err := totalsMismatch(topLayerProof, statement); err != nil {
+		panic(fmt.Sprintf("top layer %s", err))
+	}
+	verifySegmentNames(statement)
+}
+
+// verifySegmentNames checks the statement names each segment subtotal it publishes, and names nothing else.
+func verifySegmentNames(statement PublishedStatement) {
+	subtotals := 0
+	if statement.AssetSum != nil {
+		subtotals = len(statement.AssetSum.Segments)
+	}
+	if len(statement.SegmentNames) != subtotals {
+		panic(fmt.Sprintf("published statement names %d segments but has %d segment subtotals", len(statement.SegmentNames), subtotals))
+	}
+	for k, name := range statement.SegmentNames {
+		if name == "" {
+			panic(fmt.Sprintf("segment %d of the published statement has no name", k))
+		}
+	}
+}
+
+// totalsMismatch reports how the totals the top level proof publishes differ from those of the statement: the asset
+// sum or, for hidden totals, the commitment and reserves.
+func totalsMismatch(topLayerProof CompletedProof, statement PublishedStatement) error {
+	if topLayerProof.AssetSumCommitment != nil || statement.AssetSumCommitment != nil {
+		if topLayerProof.AssetSumCommitment == nil || statement.AssetSumCommitment == nil ||
+			!topLayerProof.AssetSumCommitment.Equals(*statement.AssetSumCommitment) {
+			return errors.New("asset sum commitment does not match the published statement")
+		}
+		if topLayerProof.Reserves == nil || statement.Reserves == nil || !topLayerProof.Reserves.Equals(*statement.Reserves) {
+			return errors.New("reserves do not match the published statement")
+		}
+		return nil
+	}
+	if topLayerProof.AssetSum == nil {
+		return errors.New("proof asset sum is nil")
+	}
+	if statement.AssetSum == nil {
+		return errors.New("asset sum is not in the published statement")
+	}
+	if !topLayerProof.AssetSum.Equals(*statement.AssetSum) {
+		return fmt.Errorf("asset sum (%s) does not match the published statement (%s)",
+			describeBalance(*topLayerProof.AssetSum), describeBalance(*statement.AssetSum))
+	}
+	return nil
+}
+
+// WithPublishedStatement makes verification fail unless the top level proof matches the statement the exchange published.
+func WithPublishedStatement(statement PublishedStatement) VerifyOption {
+	return func(config *verifyConfig) {
+		config.statement = &statement
+	}
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+func TestVerifyProofPathWithPublishedStatement(t *testing.T) {
+	assert := test.NewAssert(t)
+	statement := NewPublishedStatement(7, proofTop)
+
+	report := VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithPublishedStatement(statement))
+	assert.True(report.Passed())
+	assert.Equal(CheckStatement, report.Checks[len(report.Checks)-1].Kind)
+
+	// a valid, self-consistent proof set that is not the announced one
+	report = VerifyProofPath(altProofLower0.AccountLeaves[0], altProofLower0, altProofMid, altProofTop, WithPublishedStatement(statement))
+	assert.False(report.Passed(), "should fail when the proof set differs from the statement")
+	assert.Equal(1, len(report.Failures()))
+	assert.Equal(CheckStatement, report.Failures()[0].Kind)
+
+	wrongTotals := statement
+	wrongTotals.AssetSum = &circuit.GoBalance{Bitcoin: *big.NewInt(1), Ethereum: statement.AssetSum.Ethereum}
+	report = VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop, WithPublishedStatement(wrongTotals))
+	assert.False(report.Passed(), "should fail when the announced totals differ")
+}
+
+func TestStatementFileRoundTrip(t *testing.T) {
+	assert := test.NewAssert(t)
+	statement := NewPublishedStatement(3, proofTop)
+	path := filepath.Join(t.TempDir(), "statement.json")
+
+	assert.NoError(WriteStatementToFile(path, statement))
+	read, err := ReadStatementFromFile(path)
+	assert.NoError(err)
+	assert.Equal(statement.Epoch, read.Epoch)
+	assert.Equal(statement.MerkleRoot, read.MerkleRoot)
+	assert.Equal(statement.MerkleRootWithAssetSumHash, read.MerkleRootWithAssetSumHash)
+	assert.True(read.AssetSum.Equals(*statement.AssetSum))
+}
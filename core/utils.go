@@ -3,10 +3,49 @@ package core
 import (
 	"bitgo.com/proof_of_reserves/circuit"
 	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
 )
 
+const (
+	secretDir = "out/secret/"
+	publicDir = "out/public/"
+	userDir   = "out/user/"
+
+	secretDataName       = "test_data_"
+	bottomLevelProofName = "test_proof_"
+	midLevelProofName    = "test_mid_level_proof_"
+	topLevelProofName    = "test_top_level_proof_"
+	statementName        = "statement.json"
+	userAccountName      = "test_account.json"
+	assetSumOpeningName  = "asset_sum_opening.json"
+
+	secretDataPrefix       = secretDir + secretDataName
+	bottomLevelProofPrefix = publicDir + bottomLevelProofName
+	midLevelProofPrefix    = publicDir + midLevelProofName
+	topLevelProofPrefix    = publicDir + topLevelProofName
+	userAccountFile        = userDir + userAccountName
+)
+
+func proofFilePath(prefix string, index int) string {
+	return prefix + strconv.Itoa(index) + ".json"
+}
+
+// proofFilePaths lists the proof files for prefix, in whichever format each one was written.
+func proofFilePaths(prefix string, count int) []string {
+	paths := make([]string, count)
+	for i := range paths {
+		paths[i] = resolveProofFile(proofFilePath(prefix, i))
+	}
+	return paths
+}
+
+// midLevelProofCount is ceil(batchCount / 1024), the number of mid level proofs for batchCount bottom level proofs
+func midLevelProofCount(batchCount int) int {
+	return (batchCount + 1023) / 1024
+}
+
 func writeJson(filePath string, data interface{}) error {
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -40,7 +79,18 @@ func readJson(filePath string, data interface{}) error {
 	return decoder.Decode(data)
 }
 
+// readVersionedJson reads a file of one of the versioned types, upgrading it from the schema version it was written with.
+func readVersionedJson(filePath string, data interface{}) error {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	return decodeVersionedJson(b, data)
+}
+
 type ProofElements struct {
+	Version                    int
+	CircuitId                  string
 	Accounts                   []circuit.GoAccount
 	AssetSum                   *circuit.GoBalance
 	MerkleRoot                 []byte
@@ -50,17 +100,33 @@ type ProofElements struct {
 type AccountLeaf = []byte
 
 type CompletedProof struct {
+	Version                    int
+	CircuitId                  string
 	Proof                      string
 	VK                         string
 	AccountLeaves              []AccountLeaf
 	MerkleRoot                 []byte
 	MerkleRootWithAssetSumHash []byte
 	AssetSum                   *circuit.GoBalance
+	// AssetSumCommitment and Reserves are published instead of the AssetSum by top level proofs of
+	// circuit.HiddenTotalCircuitId, which prove each committed total is at most its reserves
+	AssetSumCommitment *circuit.GoCommitment `json:",omitempty"`
+	Reserves           *circuit.GoBalance    `json:",omitempty"`
+	// Prices are the prices a proof of circuit.CrossMarginCircuitId proved its accounts' net values at, a public
+	// input published by proofs of every level
+	Prices *circuit.GoBalance `json:",omitempty"`
+	EpochContext
 }
 
 func ReadDataFromFile[D ProofElements | CompletedProof | circuit.GoAccount](filePath string) D {
 	var data D
-	err := readJson(filePath, &data)
+	var err error
+	if proof, ok := any(&data).(*CompletedProof); ok {
+		// proof files may be JSON or binary
+		*proof, err = readCompletedProof(resolveProofFile(filePath))
+	} else {
+		err = readVersionedJson(filePath, &data)
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -70,7 +136,7 @@ func ReadDataFromFile[D ProofElements | CompletedProof | circuit.GoAccount](file
 func ReadDataFromFiles[D ProofElements | CompletedProof](batchCount int, prefix string) []D {
 	proofElements := make([]D, batchCount)
 	for i := 0; i < batchCount; i++ {
-		file := ReadDataFromFile[D](prefix + strconv.Itoa(i) + ".json")
+		file := ReadDataFromFile[D](proofFilePath(prefix, i))
 		proofElements[i] = file
 	}
 	return proofElements
@@ -109,3 +175,18 @@ func ConvertProofToGoAccount(proof CompletedProof) circuit.GoAccount {
 		Balance: *proof.AssetSum,
 	}
 }
+
+// describeBalance formats balance for error messages, with its account count or gross total if it has one.
+func describeBalance(balance circuit.GoBalance) string {
+	description := fmt.Sprintf("Bitcoin %s, Ethereum %s", balance.Bitcoin.String(), balance.Ethereum.String())
+	if balance.AccountCount != nil {
+		description += fmt.Sprintf(", %s accounts", balance.AccountCount.String())
+	}
+	if balance.Gross != nil {
+		description += fmt.Sprintf(" net of gross Bitcoin %s, Ethereum %s", balance.Gross.Bitcoin.String(), balance.Gross.Ethereum.String())
+	}
+	for k, subtotal := range balance.Segments {
+		description += fmt.Sprintf("; segment %d Bitcoin %s, Ethereum %s", k, subtotal.Bitcoin.String(), subtotal.Ethereum.String())
+	}
+	return description
+}
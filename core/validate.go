@@ -0,0 +1,170 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"path/filepath"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark-crypto/ecc"
+)
+
+type IssueKind string
+
+const (
+	IssueUnreadableFile  IssueKind = "unreadable-file"
+	IssueBatchCapacity   IssueKind = "batch-capacity"
+	IssueDuplicateUserId IssueKind = "duplicate-user-id"
+	IssueUserIdOverflow  IssueKind = "user-id-overflow"
+	IssueNegativeBalance IssueKind = "negative-balance"
+	IssueBalanceOverflow IssueKind = "balance-overflow"
+	IssueAssetSum        IssueKind = "asset-sum"
+	IssueNetValue        IssueKind = "negative-net-value"
+	IssueSegment         IssueKind = "segment"
+)
+
+// balanceBits is the width of the range check the circuit applies to each balance.
+const balanceBits = 64
+
+// LedgerIssue is a problem with the secret inputs that would make proving fail or produce wrong proofs.
+// Index is the account's index in its batch, or -1 for issues with the batch as a whole.
+type LedgerIssue struct {
+	Kind    IssueKind
+	File    string
+	Index   int
+	UserId  []byte `json:",omitempty"`
+	Message string
+}
+
+type LedgerValidation struct {
+	Batches  int
+	Accounts int
+	Issues   []LedgerIssue
+}
+
+func (validation LedgerValidation) Passed() bool {
+	return validation.Batches > 0 && len(validation.Issues) == 0
+}
+
+func (validation *LedgerValidation) addIssue(kind IssueKind, file string, index int, userId []byte, format string, args ...any) {
+	validation.Issues = append(validation.Issues, LedgerIssue{Kind: kind, File: file, Index: index, UserId: userId, Message: fmt.Sprintf(format, args...)})
+}
+
+// accountLocation is where a user id was first seen, to report where its duplicates clash with.
+type accountLocation struct {
+	file  string
+	index int
+}
+
+func assetBalances(balance *circuit.GoBalance) map[string]*big.Int {
+	return map[string]*big.Int{"Bitcoin": &balance.Bitcoin, "Ethereum": &balance.Ethereum}
+}
+
+// fitsSignedBits tells whether value lies in [-2^(bits-1), 2^(bits-1)), as the cross-margin circuit checks balances do.
+func fitsSignedBits(value *big.Int, bits int) bool {
+	shifted := new(big.Int).Add(value, new(big.Int).Lsh(big.NewInt(1), uint(bits-1)))
+	return shifted.Sign() >= 0 && shifted.BitLen() <= bits
+}
+
+// validateAccount reports the problems of a single account that the circuit would reject or silently wrap. With
+// prices, the account is a margin account whose balances may be negative but whose net value may not.
+func (validation *LedgerValidation) validateAccount(file string, index int, account circuit.GoAccount, prices *circuit.GoBalance) {
+	userId := new(big.Int).SetBytes(account.UserId)
+	if userId.Cmp(ecc.BN254.ScalarField()) >= 0 {
+		validation.addIssue(IssueUserIdOverflow, file, index, account.UserId,
+			"user id is %d bits and would wrap modulo the BN254 scalar field", userId.BitLen())
+	}
+	for _, asset := range []string{"Bitcoin", "Ethereum"} {
+		balance := assetBalances(&account.Balance)[asset]
+		switch {
+		case prices != nil:
+			if !fitsSignedBits(balance, balanceBits) {
+				validation.addIssue(IssueBalanceOverflow, file, index, account.UserId, "%s balance %s does not fit in %d signed bits", asset, balance.String(), balanceBits)
+			}
+		case balance.Sign() < 0:
+			validation.addIssue(IssueNegativeBalance, file, index, account.UserId, "%s balance %s is negative", asset, balance.String())
+		case balance.BitLen() > balanceBits:
+			validation.addIssue(IssueBalanceOverflow, file, index, account.UserId, "%s balance %s does not fit in %d bits", asset, balance.String(), balanceBits)
+		}
+	}
+	if prices != nil {
+		if value := circuit.GoNetValue(account.Balance, *prices); value.Sign() < 0 {
+			validation.addIssue(IssueNetValue, file, index, account.UserId, "net value %s at the prices is negative", value.String())
+		}
+	}
+}
+
+// validateSegment reports an account of a segmented ledger without a known segment, or one tagged with a segment
+// although the ledger is not.
+func (validation *LedgerValidation) validateSegment(file string, index int, account circuit.GoAccount, segmented bool) {
+	segment := account.Balance.Segment
+	switch {
+	case !segmented && segment != nil:
+		validation.addIssue(IssueSegment, file, index, account.UserId, "account is tagged with segment %s but the ledger is not proven with segments", segment.String())
+	case segmented && segment == nil:
+		validation.addIssue(IssueSegment, file, index, account.UserId, "account is not tagged with a segment")
+	case segmented && (segment.Sign() < 0 || segment.Cmp(big.NewInt(circuit.SegmentCount)) >= 0):
+		validation.addIssue(IssueSegment, file, index, account.UserId, "segment %s is not one of the %d segments", segment.String(), circuit.SegmentCount)
+	}
+}
+
+// validateAssetSum reports a batch whose recorded AssetSum is missing or is not the sum of its balances.
+func (validation *LedgerValidation) validateAssetSum(file string, elements ProofElements) {
+	if elements.AssetSum == nil {
+		validation.addIssue(IssueAssetSum, file, -1, nil, "batch has no AssetSum")
+		return
+	}
+	sum := circuit.GoBalance{}
+	for _, account := range elements.Accounts {
+		sum.Bitcoin.Add(&sum.Bitcoin, &account.Balance.Bitcoin)
+		sum.Ethereum.Add(&sum.Ethereum, &account.Balance.Ethereum)
+	}
+	for _, asset := range []string{"Bitcoin", "Ethereum"} {
+		recorded, actual := assetBalances(elements.AssetSum)[asset], assetBalances(&sum)[asset]
+		if recorded.Cmp(actual) != 0 {
+			validation.addIssue(IssueAssetSum, file, -1, nil, "recorded %s sum %s but the balances sum to %s", asset, recorded.String(), actual.String())
+		}
+	}
+}
+
+// ValidateLedger scans every batch of secret data in secretDir before proving, and reports each problem
+// with its file, account index and user id rather than stopping at the first one: unreadable files,
+// batches over the circuit's capacity, user ids that appear more than once across all batches, user ids
+// too large for the field, negative balances, balances outside the circuit's range check and AssetSums
+// that are not the sum of the batch's balances. The options are those the ledger is to be proven with: with
+// WithCrossMargin, negative balances are allowed and accounts of negative net value are reported instead, and with
+// WithSegments, accounts not tagged with one of the segments are reported.
+func ValidateLedger(secretDir string, options ...ProveOption) LedgerValidation {
+	config := proveConfig{}
+	for _, option := range options {
+		option(&config)
+	}
+	validation := LedgerValidation{Batches: countIndexedFiles(secretDir, secretDataName)}
+	capacity := circuit.PowOfTwo(circuit.TreeDepth)
+	seen := make(map[string]accountLocation)
+	for i := 0; i < validation.Batches; i++ {
+		file := proofFilePath(filepath.Join(secretDir, secretDataName), i)
+		var elements ProofElements
+		if err := readVersionedJson(file, &elements); err != nil {
+			validation.addIssue(IssueUnreadableFile, file, -1, nil, "%v", err)
+			continue
+		}
+		validation.Accounts += len(elements.Accounts)
+		if len(elements.Accounts) > capacity {
+			validation.addIssue(IssueBatchCapacity, file, -1, nil, "batch has %d accounts but a proof holds at most %d", len(elements.Accounts), capacity)
+		}
+		for index, account := range elements.Accounts {
+			validation.validateAccount(file, index, account, config.prices)
+			validation.validateSegment(file, index, account, config.segments)
+			// ids are compared as the field elements they are hashed as, so leading zero bytes do not hide a duplicate
+			key := new(big.Int).SetBytes(account.UserId).String()
+			if first, ok := seen[key]; ok {
+				validation.addIssue(IssueDuplicateUserId, file, index, account.UserId, "user id was already used at index %d of %s", first.index, first.file)
+			} else {
+				seen[key] = accountLocation{file: file, index: index}
+			}
+		}
+		validation.validateAssetSum(file, elements)
+	}
+	return validation
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"github.com/consensys/gnark/test"
+)
+
+func writeLedger(t *testing.T, batches ...ProofElements) string {
+	dir := t.TempDir()
+	for i, elements := range batches {
+		if err := writeJson(proofFilePath(filepath.Join(dir, secretDataName), i), elements); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func ledgerBatch(accounts []circuit.GoAccount) ProofElements {
+	sum := circuit.SumGoAccountBalancesIncludingNegatives(nil)
+	for _, account := range accounts {
+		sum.Bitcoin.Add(&sum.Bitcoin, &account.Balance.Bitcoin)
+		sum.Ethereum.Add(&sum.Ethereum, &account.Balance.Ethereum)
+	}
+	return ProofElements{Version: SchemaVersion, Accounts: accounts, AssetSum: &sum}
+}
+
+func issueKinds(validation LedgerValidation) []IssueKind {
+	kinds := make([]IssueKind, len(validation.Issues))
+	for i, issue := range validation.Issues {
+		kinds[i] = issue.Kind
+	}
+	return kinds
+}
+
+func TestValidateLedger(t *testing.T) {
+	assert := test.NewAssert(t)
+	first, _, _, _ := circuit.GenerateTestData(16, 11)
+	second, _, _, _ := circuit.GenerateTestData(16, 12)
+
+	validation := ValidateLedger(writeLedger(t, ledgerBatch(first), ledgerBatch(second)))
+	assert.True(validation.Passed(), validation.Issues)
+	assert.Equal(2, validation.Batches)
+	assert.Equal(32, validation.Accounts)
+
+	assert.False(ValidateLedger(t.TempDir()).Passed(), "an empty ledger should not pass")
+}
+
+func TestValidateLedgerReportsEveryIssue(t *testing.T) {
+	assert := test.NewAssert(t)
+	accounts, _, _, _ := circuit.GenerateTestData(8, 11)
+	accounts[1].Balance.Bitcoin.SetInt64(-5)
+	accounts[2].Balance.Ethereum.Lsh(big.NewInt(1), 64)
+	accounts[3].UserId = make([]byte, 32)
+	for i := range accounts[3].UserId {
+		accounts[3].UserId[i] = 0xff
+	}
+	accounts[4].UserId = append([]byte{0}, accounts[0].UserId...)
+	batch := ledgerBatch(accounts)
+	wrongSum := ledgerBatch(accounts[5:])
+	wrongSum.AssetSum.Bitcoin.Add(&wrongSum.AssetSum.Bitcoin, big.NewInt(1))
+	oversized := ledgerBatch(make([]circuit.GoAccount, 0))
+	for i := 0; i < 1025; i++ {
+		oversized.Accounts = append(oversized.Accounts, circuit.GoAccount{UserId: big.NewInt(int64(1000 + i)).Bytes()})
+	}
+
+	dir := writeLedger(t, batch, wrongSum, oversized)
+	validation := ValidateLedger(dir)
+	assert.False(validation.Passed())
+	assert.Equal([]IssueKind{
+		IssueNegativeBalance, IssueBalanceOverflow, IssueUserIdOverflow, IssueDuplicateUserId,
+		IssueDuplicateUserId, IssueDuplicateUserId, IssueDuplicateUserId, IssueAssetSum,
+		IssueBatchCapacity,
+	}, issueKinds(validation))
+
+	negative := validation.Issues[0]
+	assert.Equal(proofFilePath(filepath.Join(dir, secretDataName), 0), negative.File)
+	assert.Equal(1, negative.Index)
+	assert.Equal(accounts[1].UserId, negative.UserId)
+	padded := validation.Issues[3]
+	assert.Equal(4, padded.Index)
+	assert.Contains(padded.Message, "index 0", "the zero padded id should clash with the first account")
+	assert.Equal(proofFilePath(filepath.Join(dir, secretDataName), 1), validation.Issues[4].File, "duplicates are found across batches")
+	assert.Equal(-1, validation.Issues[8].Index)
+}
+
+func TestValidateLedgerReportsUnreadableFiles(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := t.TempDir()
+	assert.NoError(os.WriteFile(proofFilePath(filepath.Join(dir, secretDataName), 0), []byte("{"), 0o644))
+	validation := ValidateLedger(dir)
+	assert.False(validation.Passed())
+	assert.Equal([]IssueKind{IssueUnreadableFile}, issueKinds(validation))
+}
@@ -3,18 +3,215 @@ package core
 import (
 	"bitgo.com/proof_of_reserves/circuit"
 	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend/groth16"
-	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/backend/witness"
+	"path/filepath"
+	"runtime"
+	"sync"
 )
 
+type VerifyOption func(*verifyConfig)
+
+type verifyConfig struct {
+	pathFiles   [3]string
+	statement   *PublishedStatement
+	workers     int
+	issuerKey   ed25519.PublicKey
+	attestation *Attestation
+
+	auditorKeys      []ed25519.PublicKey
+	auditorThreshold int
+	endorsements     *Endorsements
+
+	expectedEpoch *EpochContext
+}
+
+func newVerifyConfig(options []VerifyOption) verifyConfig {
+	config := verifyConfig{
+		pathFiles: [3]string{"bottom level proof", "mid level proof", "top level proof"},
+		workers:   runtime.NumCPU(),
+	}
+	for _, option := range options {
+		option(&config)
+	}
+	return config
+}
+
+// WithProofPathFiles names the files the bottom, mid and top level proofs passed to VerifyProofPath were read from.
+func WithProofPathFiles(bottomFile, midFile, topFile string) VerifyOption {
+	return func(config *verifyConfig) {
+		config.pathFiles = [3]string{bottomFile, midFile, topFile}
+	}
+}
+
+// WithWorkers sets how many proof files Verify checks concurrently. It defaults to the number of CPUs.
+func WithWorkers(workers int) VerifyOption {
+	return func(config *verifyConfig) {
+		if workers > 0 {
+			config.workers = workers
+		}
+	}
+}
+
 func verifyProof(proof CompletedProof) bool {
-	// first, verify snark
-	var publicCircuit circuit.Circuit
-	publicCircuit.MerkleRoot = proof.MerkleRoot
-	publicCircuit.MerkleRootWithAssetSumHash = proof.MerkleRootWithAssetSumHash
-	publicWitness, err := frontend.NewWitness(&publicCircuit, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	verifyProofSnark(proof)
+	verifyProofLeaves(proof)
+	return true
+}
+
+type circuitSpec struct {
+	// publicInputs lists the public inputs of a proof in the order the circuit declares them
+	publicInputs func(proof CompletedProof) []any
+	// bindsEpoch is set when the proof's Epoch is one of its public inputs
+	bindsEpoch bool
+	// bindsContext is set when the rest of the proof's EpochContext is also among its public inputs
+	bindsContext bool
+	// countsAccounts is set when the asset sum committed to by MerkleRootWithAssetSumHash has an AccountCount
+	countsAccounts bool
+	// hidesTotal is set for top level proofs publishing AssetSumCommitment and Reserves instead of the AssetSum
+	hidesTotal bool
+	// crossMargin is set when the asset sum is net of negative balances and has a Gross total and the Prices
+	crossMargin bool
+	// segments is set when the asset sum has the Segments subtotals
+	segments bool
+	// unbound is set for circuits that take no epoch context, which only lower level proofs are made with
+	unbound bool
+	// blindsSum is set when the asset sum committed to by MerkleRootWithAssetSumHash has a Blinding
+	blindsSum bool
+}
+
+// circuits lists every circuit proofs have been made with. Entries are never removed so that old
+// epochs stay verifiable.
+var circuits = map[string]circuitSpec{
+	circuitIdV1: {
+		publicInputs: func(proof CompletedProof) []any {
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash}
+		},
+	},
+	circuitIdV2: {
+		publicInputs: func(proof CompletedProof) []any {
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash, proof.Epoch}
+		},
+		bindsEpoch: true,
+	},
+	circuitIdV3: {
+		publicInputs: func(proof CompletedProof) []any {
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash, proof.Epoch, proof.SnapshotTimestamp, proof.IssuerIdHash}
+		},
+		bindsEpoch:   true,
+		bindsContext: true,
+	},
+	circuitIdV4: {
+		publicInputs: func(proof CompletedProof) []any {
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash, proof.Epoch, proof.SnapshotTimestamp, proof.IssuerIdHash}
+		},
+		bindsEpoch:     true,
+		bindsContext:   true,
+		countsAccounts: true,
+	},
+	circuitIdV5: {
+		publicInputs: func(proof CompletedProof) []any {
+			if proof.AssetSumCommitment == nil || proof.Reserves == nil {
+				panic("proof does not publish its asset sum commitment and reserves")
+			}
+			commitment, err := circuit.ConvertGoCommitmentToCommitment(*proof.AssetSumCommitment)
+			if err != nil {
+				panic(err)
+			}
+			return []any{proof.MerkleRoot, commitment.Bitcoin.X, commitment.Bitcoin.Y, commitment.Ethereum.X, commitment.Ethereum.Y,
+				&proof.Reserves.Bitcoin, &proof.Reserves.Ethereum, proof.Epoch, proof.SnapshotTimestamp, proof.IssuerIdHash}
+		},
+		bindsEpoch:   true,
+		bindsContext: true,
+		hidesTotal:   true,
+	},
+	circuitIdV6: {
+		publicInputs: func(proof CompletedProof) []any {
+			if proof.Prices == nil {
+				panic("proof does not publish its prices")
+			}
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash, proof.Epoch, proof.SnapshotTimestamp, proof.IssuerIdHash,
+				&proof.Prices.Bitcoin, &proof.Prices.Ethereum}
+		},
+		bindsEpoch:   true,
+		bindsContext: true,
+		crossMargin:  true,
+	},
+	circuitIdV7: {
+		publicInputs: func(proof CompletedProof) []any {
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash, proof.Epoch, proof.SnapshotTimestamp, proof.IssuerIdHash}
+		},
+		bindsEpoch:   true,
+		bindsContext: true,
+		segments:     true,
+	},
+	circuitIdV3Unbound: {
+		publicInputs: func(proof CompletedProof) []any {
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash}
+		},
+		unbound: true,
+	},
+	circuitIdV5Blinded: {
+		publicInputs: func(proof CompletedProof) []any {
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash}
+		},
+		unbound:   true,
+		blindsSum: true,
+	},
+	circuitIdV4Unbound: {
+		publicInputs: func(proof CompletedProof) []any {
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash}
+		},
+		countsAccounts: true,
+		unbound:        true,
+	},
+	circuitIdV6Unbound: {
+		publicInputs: func(proof CompletedProof) []any {
+			if proof.Prices == nil {
+				panic("proof does not publish its prices")
+			}
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash, &proof.Prices.Bitcoin, &proof.Prices.Ethereum}
+		},
+		crossMargin: true,
+		unbound:     true,
+	},
+	circuitIdV7Unbound: {
+		publicInputs: func(proof CompletedProof) []any {
+			return []any{proof.MerkleRoot, proof.MerkleRootWithAssetSumHash}
+		},
+		segments: true,
+		unbound:  true,
+	},
+}
+
+func newPublicWitness(proof CompletedProof) (witness.Witness, error) {
+	spec, ok := circuits[proof.CircuitId]
+	if !ok {
+		return nil, fmt.Errorf("unknown circuit %q", proof.CircuitId)
+	}
+	values := spec.publicInputs(proof)
+	publicWitness, err := witness.New(ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, err
+	}
+	valuesChan := make(chan any, len(values))
+	for _, value := range values {
+		valuesChan <- value
+	}
+	close(valuesChan)
+	return publicWitness, publicWitness.Fill(len(values), 0, valuesChan)
+}
+
+func verifyProofSnark(proof CompletedProof) {
+	publicWitness, err := newPublicWitness(proof)
+	if err != nil {
+		panic(err)
+	}
 	grothProof := groth16.NewProof(ecc.BN254)
 	b1, err := base64.StdEncoding.DecodeString(proof.Proof)
 	if err != nil {
@@ -25,31 +222,67 @@ func verifyProof(proof CompletedProof) bool {
 	if err != nil {
 		panic(err)
 	}
-	grothVK := groth16.NewVerifyingKey(ecc.BN254)
-	b2, err := base64.StdEncoding.DecodeString(proof.VK)
+	grothVK := decodeVerifyingKey(proof.VK)
+	err = groth16.Verify(grothProof, grothVK, publicWitness)
 	if err != nil {
 		panic(err)
 	}
-	buf2 := bytes.NewBuffer(b2)
-	_, err = grothVK.ReadFrom(buf2)
+}
+
+// every bottom level proof of a given size carries the same VK, so decoded VKs are cached by the hash of their encoding
+var cachedVerifyingKeys = struct {
+	sync.Mutex
+	keys map[[sha256.Size]byte]groth16.VerifyingKey
+}{keys: make(map[[sha256.Size]byte]groth16.VerifyingKey)}
+
+func decodeVerifyingKey(vk string) groth16.VerifyingKey {
+	hash := sha256.Sum256([]byte(vk))
+	cachedVerifyingKeys.Lock()
+	grothVK, ok := cachedVerifyingKeys.keys[hash]
+	cachedVerifyingKeys.Unlock()
+	if ok {
+		return grothVK
+	}
+
+	grothVK = groth16.NewVerifyingKey(ecc.BN254)
+	b, err := base64.StdEncoding.DecodeString(vk)
 	if err != nil {
 		panic(err)
 	}
-	err = groth16.Verify(grothProof, grothVK, publicWitness)
+	_, err = grothVK.ReadFrom(bytes.NewBuffer(b))
 	if err != nil {
 		panic(err)
 	}
+	cachedVerifyingKeys.Lock()
+	cachedVerifyingKeys.keys[hash] = grothVK
+	cachedVerifyingKeys.Unlock()
+	return grothVK
+}
 
-	// next, verify the account leaves hash to the merkle root
+func verifyProofLeaves(proof CompletedProof) {
 	if !bytes.Equal(circuit.GoComputeMerkleRootFromHashes(proof.AccountLeaves), proof.MerkleRoot) {
 		panic("account leaves do not hash to the merkle root")
 	}
-	return true
+}
+
+// verifyLowerLayerProofEpoch checks a proof belongs to the same epoch context as the proof it was aggregated into,
+// unless it binds none: the upper layer proof binds it to its own through its merkle root.
+func verifyLowerLayerProofEpoch(lowerLayerProof CompletedProof, upperLayerProof CompletedProof) {
+	if circuits[lowerLayerProof.CircuitId].unbound {
+		return
+	}
+	if lowerLayerProof.Epoch != upperLayerProof.Epoch {
+		panic(fmt.Sprintf("lower layer proof is from epoch %d but upper layer proof is from epoch %d", lowerLayerProof.Epoch, upperLayerProof.Epoch))
+	}
+	if !lowerLayerProof.EpochContext.equals(upperLayerProof.EpochContext) {
+		panic("lower layer proof is from a different snapshot or issuer than upper layer proof")
+	}
 }
 
 func verifyLowerLayerProofsLeadToUpperLayerProof(lowerLayerProofs []CompletedProof, upperLayerProof CompletedProof) {
 	bottomLayerHashes := make([]circuit.Hash, len(lowerLayerProofs))
 	for i, proof := range lowerLayerProofs {
+		verifyLowerLayerProofEpoch(proof, upperLayerProof)
 		bottomLayerHashes[i] = proof.MerkleRootWithAssetSumHash
 	}
 	if !bytes.Equal(circuit.GoComputeMerkleRootFromHashes(bottomLayerHashes), upperLayerProof.MerkleRoot) {
@@ -58,9 +291,31 @@ func verifyLowerLayerProofsLeadToUpperLayerProof(lowerLayerProofs []CompletedPro
 }
 
 func verifyTopLayerProofMatchesAssetSum(topLayerProof CompletedProof) {
+	if circuits[topLayerProof.CircuitId].unbound {
+		panic(fmt.Sprintf("top layer proof is made with circuit %s, which does not bind the epoch context", topLayerProof.CircuitId))
+	}
+	if circuits[topLayerProof.CircuitId].hidesTotal {
+		// the SNARK proves the commitment is to the sum of the mid level proofs
+		verifyHiddenTotal(topLayerProof)
+		return
+	}
 	if topLayerProof.AssetSum == nil {
 		panic("top layer proof asset sum is nil")
 	}
+	// the hash would not match either, but a missing or unexpected count is worth naming
+	counted := topLayerProof.AssetSum.AccountCount != nil
+	if spec, ok := circuits[topLayerProof.CircuitId]; ok && spec.countsAccounts != counted {
+		if counted {
+			panic(fmt.Sprintf("top layer proof publishes an account count, which circuit %s does not prove", topLayerProof.CircuitId))
+		}
+		panic(fmt.Sprintf("top layer proof does not publish the account count circuit %s proves", topLayerProof.CircuitId))
+	}
+	if circuits[topLayerProof.CircuitId].crossMargin {
+		verifyCrossMarginSum(topLayerProof)
+	}
+	if circuits[topLayerProof.CircuitId].segments && len(topLayerProof.AssetSum.Segments) != circuit.SegmentCount {
+		panic(fmt.Sprintf("top layer proof does not publish the %d segment subtotals circuit %s proves", circuit.SegmentCount, topLayerProof.CircuitId))
+	}
 	if !bytes.Equal(circuit.GoComputeMiMCHashForAccount(ConvertProofToGoAccount(topLayerProof)), topLayerProof.MerkleRootWithAssetSumHash) {
 		panic("top layer hash with asset sum does not match published asset sum")
 	}
@@ -96,41 +351,154 @@ func verifyProofs(bottomLayerProofs []CompletedProof, midLayerProofs []Completed
 	verifyTopLayerProofMatchesAssetSum(topLayerProof)
 }
 
-func verifyInclusionInProof(accountHash circuit.Hash, bottomLayerProofs []CompletedProof) {
-	for _, proof := range bottomLayerProofs {
+func findInclusionInProofs(accountHash circuit.Hash, proofs []CompletedProof) int {
+	for i, proof := range proofs {
 		for _, leaf := range proof.AccountLeaves {
 			if bytes.Equal(leaf, accountHash) {
-				return
+				return i
 			}
 		}
 	}
-	panic("account not found in any proof")
+	return -1
 }
 
-func Verify(batchCount int, account circuit.GoAccount) {
-	bottomLevelProofs := ReadDataFromFiles[CompletedProof](batchCount, "out/public/test_proof_")
-	// the number of mid level proofs is ceil(batchCount / 1024)
-	midLevelProofs := ReadDataFromFiles[CompletedProof]((batchCount+1023)/1024, "out/public/test_mid_level_proof_")
-	topLevelProof := ReadDataFromFiles[CompletedProof](1, "out/public/test_top_level_proof_")[0]
-	verifyProofs(bottomLevelProofs, midLevelProofs, topLevelProof)
-
-	accountHash := circuit.GoComputeMiMCHashForAccount(account)
-	verifyInclusionInProof(accountHash, bottomLevelProofs)
+func verifyInclusionInProof(accountHash circuit.Hash, bottomLayerProofs []CompletedProof) {
+	if findInclusionInProofs(accountHash, bottomLayerProofs) == -1 {
+		panic("account not found in any proof")
+	}
 }
 
-func VerifyProofPath(accountHash circuit.Hash, bottomLayerProof CompletedProof, midLayerProof CompletedProof, topLayerProof CompletedProof) {
-	if !verifyProof(bottomLayerProof) {
-		panic("bottom layer proof verification failed")
+// verifyPinnedChecks records the checks that compare the proofs against what the verifier was told to
+// expect through options. files and hashes are the verified proof files and their canonical hashes, ending
+// with the top level proof; complete is set when they are the whole proof set rather than one path.
+func (report *VerificationReport) verifyPinnedChecks(config verifyConfig, topLevelProof CompletedProof, files []string, hashes [][]byte, complete bool) {
+	topLevelFile := files[len(files)-1]
+	if config.expectedEpoch != nil {
+		report.runCheck(CheckEpochContext, topLevelFile, func() { verifyProofEpochContext(topLevelProof, *config.expectedEpoch) })
 	}
-	if !verifyProof(midLayerProof) {
-		panic("mid layer proof verification failed")
+	if config.statement != nil {
+		report.runCheck(CheckStatement, topLevelFile, func() { verifyTopLayerProofMatchesStatement(topLevelProof, *config.statement) })
 	}
-	if !verifyProof(topLayerProof) {
-		panic("top layer proof verification failed")
+	if config.issuerKey != nil {
+		report.runCheck(CheckAttestation, topLevelFile, func() {
+			if config.attestation == nil {
+				panic("proofs are not attested by the issuer")
+			}
+			verifyAttestationSignature(*config.attestation, config.issuerKey, topLevelProof, hashes[len(hashes)-1])
+			verifyProofsAreAttested(*config.attestation, files, hashes, complete)
+		})
+	}
+	if config.auditorKeys != nil {
+		report.runCheck(CheckEndorsements, topLevelFile, func() {
+			if config.endorsements == nil {
+				panic("proofs are not endorsed by any auditor")
+			}
+			verifyEndorsements(*config.endorsements, config.auditorKeys, config.auditorThreshold, topLevelProof)
+		})
 	}
-	verifyInclusionInProof(accountHash, []CompletedProof{bottomLayerProof})
-	verifyInclusionInProof(bottomLayerProof.MerkleRootWithAssetSumHash, []CompletedProof{midLayerProof})
-	verifyInclusionInProof(midLayerProof.MerkleRootWithAssetSumHash, []CompletedProof{topLayerProof})
+}
 
-	verifyTopLayerProofMatchesAssetSum(topLayerProof)
+// verifyProofChecks records the SNARK and leaf-to-root checks for a single proof.
+func (report *VerificationReport) verifyProofChecks(proof CompletedProof, file string) {
+	report.runCheck(CheckSnark, file, func() { verifyProofSnark(proof) })
+	report.runCheck(CheckLeavesToRoot, file, func() { verifyProofLeaves(proof) })
+}
+
+// Verify performs a complete verification of every proof file in 'out/public/' and the inclusion
+// of account in one of the bottom level proofs. Failing checks do not stop verification; they are
+// recorded in the returned report. Pass WithPublishedStatement to also pin the top level proof to
+// what the exchange announced, WithExpectedEpoch to require it be the proof of a given epoch, WithIssuerKey
+// to require the proof set be attested by the issuer, and WithAuditorEndorsements to require it be endorsed
+// by enough auditors.
+//
+// Proof files are streamed through a pool of workers (see WithWorkers) and only the commitments of
+// each proof are kept once its own checks have run, so memory does not grow with the number of accounts.
+func Verify(batchCount int, account circuit.GoAccount, options ...VerifyOption) VerificationReport {
+	return verifyProofSet(publicDir, batchCount, circuit.GoComputeMiMCHashForAccount(account), options)
+}
+
+// verifyProofSet verifies the proofs in proofsDir as Verify does those in 'out/public/', checking the account of
+// accountHash is included unless it is nil.
+func verifyProofSet(proofsDir string, batchCount int, accountHash circuit.Hash, options []VerifyOption) VerificationReport {
+	config := newVerifyConfig(options)
+	report := newVerificationReport()
+
+	bottomLevelFiles := proofFilePaths(filepath.Join(proofsDir, bottomLevelProofName), batchCount)
+	midLevelFiles := proofFilePaths(filepath.Join(proofsDir, midLevelProofName), midLevelProofCount(batchCount))
+	topLevelFile := resolveProofFile(proofFilePath(filepath.Join(proofsDir, topLevelProofName), 0))
+
+	// first, verify the proofs are valid
+	bottomLevelProofs, bottomLevelHashes, inclusionIndex := report.verifyProofFiles(bottomLevelFiles, config.workers, accountHash)
+	midLevelProofs, midLevelHashes, _ := report.verifyProofFiles(midLevelFiles, config.workers, nil)
+	topLevelProofs, topLevelHashes, _ := report.verifyProofFiles([]string{topLevelFile}, 1, nil)
+	topLevelProof := topLevelProofs[0]
+
+	// next, verify that the bottom layer proofs lead to the mid layer proofs and the mid layer proofs lead to the top layer proof
+	bottomLevelProofsBatched := batchProofs(bottomLevelProofs, 1024)
+	report.runCheck(CheckProofSetLayout, "", func() {
+		if len(bottomLevelProofsBatched) != len(midLevelProofs) {
+			panic("bottom layer proofs and mid layer proofs do not match")
+		}
+	})
+	for i := 0; i < len(bottomLevelProofsBatched) && i < len(midLevelProofs); i++ {
+		report.runCheck(CheckChildToParent, midLevelFiles[i], func() {
+			verifyLowerLayerProofsLeadToUpperLayerProof(bottomLevelProofsBatched[i], midLevelProofs[i])
+		})
+	}
+	report.runCheck(CheckChildToParent, topLevelFile, func() {
+		verifyLowerLayerProofsLeadToUpperLayerProof(midLevelProofs, topLevelProof)
+	})
+	report.runCheck(CheckTopLevelSum, topLevelFile, func() { verifyTopLayerProofMatchesAssetSum(topLevelProof) })
+
+	// finally, verify that the account is included in one of the bottom level proofs
+	if accountHash != nil {
+		inclusionFile := ""
+		if inclusionIndex != -1 {
+			inclusionFile = bottomLevelFiles[inclusionIndex]
+		}
+		report.runCheck(CheckUserInclusion, inclusionFile, func() {
+			if inclusionIndex == -1 {
+				panic("account not found in any proof")
+			}
+		})
+	}
+
+	files := append(append(append([]string{}, bottomLevelFiles...), midLevelFiles...), topLevelFile)
+	hashes := append(append(append([][]byte{}, bottomLevelHashes...), midLevelHashes...), topLevelHashes...)
+	report.verifyPinnedChecks(config, topLevelProof, files, hashes, true)
+
+	return report.finish()
+}
+
+// VerifyProofPath verifies the O(log n) path from a user's account leaf to the top level proof.
+// Use WithProofPathFiles to name the files the proofs were read from in the report.
+func VerifyProofPath(accountHash circuit.Hash, bottomLayerProof CompletedProof, midLayerProof CompletedProof, topLayerProof CompletedProof, options ...VerifyOption) VerificationReport {
+	config := newVerifyConfig(options)
+	report := newVerificationReport()
+	report.verifyProofPath(config, accountHash, bottomLayerProof, midLayerProof, topLayerProof)
+	return report.finish()
+}
+
+func (report *VerificationReport) verifyProofPath(config verifyConfig, accountHash circuit.Hash, bottomLayerProof CompletedProof, midLayerProof CompletedProof, topLayerProof CompletedProof) {
+	bottomFile, midFile, topFile := config.pathFiles[0], config.pathFiles[1], config.pathFiles[2]
+
+	report.verifyProofChecks(bottomLayerProof, bottomFile)
+	report.verifyProofChecks(midLayerProof, midFile)
+	report.verifyProofChecks(topLayerProof, topFile)
+
+	report.runCheck(CheckUserInclusion, bottomFile, func() {
+		verifyInclusionInProof(accountHash, []CompletedProof{bottomLayerProof})
+	})
+	report.runCheck(CheckChildToParent, midFile, func() {
+		verifyInclusionInProof(bottomLayerProof.MerkleRootWithAssetSumHash, []CompletedProof{midLayerProof})
+		verifyLowerLayerProofEpoch(bottomLayerProof, midLayerProof)
+	})
+	report.runCheck(CheckChildToParent, topFile, func() {
+		verifyInclusionInProof(midLayerProof.MerkleRootWithAssetSumHash, []CompletedProof{topLayerProof})
+		verifyLowerLayerProofEpoch(midLayerProof, topLayerProof)
+	})
+	report.runCheck(CheckTopLevelSum, topFile, func() { verifyTopLayerProofMatchesAssetSum(topLayerProof) })
+	report.verifyPinnedChecks(config, topLayerProof, config.pathFiles[:], [][]byte{
+		canonicalProofHash(bottomLayerProof), canonicalProofHash(midLayerProof), canonicalProofHash(topLayerProof),
+	}, false)
 }
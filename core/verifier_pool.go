@@ -0,0 +1,88 @@
+package core
+
+import (
+	"sync"
+
+	"bitgo.com/proof_of_reserves/circuit"
+)
+
+type verifiedProofFile struct {
+	checks          []CheckResult
+	commitment      CompletedProof
+	hash            []byte
+	containsAccount bool
+}
+
+// proofCommitment strips a proof down to what is needed once its own checks have run: the roots
+// linking it to its parent, the asset sum or its commitment and the epoch context, without the SNARK, VK or account
+// leaves.
+func proofCommitment(proof CompletedProof) CompletedProof {
+	return CompletedProof{
+		CircuitId:                  proof.CircuitId,
+		EpochContext:               proof.EpochContext,
+		MerkleRoot:                 proof.MerkleRoot,
+		MerkleRootWithAssetSumHash: proof.MerkleRootWithAssetSumHash,
+		AssetSum:                   proof.AssetSum,
+		AssetSumCommitment:         proof.AssetSumCommitment,
+		Reserves:                   proof.Reserves,
+		Prices:                     proof.Prices,
+	}
+}
+
+func verifyProofFile(file string, accountHash circuit.Hash) verifiedProofFile {
+	var fileReport VerificationReport
+	var proof CompletedProof
+	read := fileReport.runCheckIfFails(CheckProofFile, file, func() {
+		var err error
+		proof, err = readCompletedProof(file)
+		if err != nil {
+			panic(err)
+		}
+	})
+	if !read {
+		return verifiedProofFile{checks: fileReport.Checks}
+	}
+	fileReport.verifyProofChecks(proof, file)
+	return verifiedProofFile{
+		checks:          fileReport.Checks,
+		commitment:      proofCommitment(proof),
+		hash:            canonicalProofHash(proof),
+		containsAccount: accountHash != nil && findInclusionInProofs(accountHash, []CompletedProof{proof}) != -1,
+	}
+}
+
+// verifyProofFiles reads and checks files on a pool of workers, recording the checks in file order. It returns
+// the commitment and canonical hash of each proof and the index of the first proof containing accountHash, or
+// -1 if none does.
+func (report *VerificationReport) verifyProofFiles(files []string, workers int, accountHash circuit.Hash) (commitments []CompletedProof, hashes [][]byte, inclusionIndex int) {
+	results := make([]verifiedProofFile, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = verifyProofFile(files[i], accountHash)
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	commitments = make([]CompletedProof, len(files))
+	hashes = make([][]byte, len(files))
+	inclusionIndex = -1
+	for i, result := range results {
+		report.Checks = append(report.Checks, result.checks...)
+		commitments[i] = result.commitment
+		hashes[i] = result.hash
+		if result.containsAccount && inclusionIndex == -1 {
+			inclusionIndex = i
+		}
+	}
+	return commitments, hashes, inclusionIndex
+}
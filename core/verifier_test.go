@@ -4,6 +4,8 @@ import (
 	"bitgo.com/proof_of_reserves/circuit"
 	"github.com/consensys/gnark/test"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -117,20 +119,129 @@ func TestVerifyProofPath(t *testing.T) {
 	assert := test.NewAssert(t)
 
 	// Valid proofs pass
-	VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop)
-	VerifyProofPath(proofLower1.AccountLeaves[len(proofLower1.AccountLeaves)-1], proofLower1, proofMid, proofTop)
-	VerifyProofPath(altProofLower0.AccountLeaves[0], altProofLower0, altProofMid, altProofTop)
+	assert.True(VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, proofTop).Passed())
+	assert.True(VerifyProofPath(proofLower1.AccountLeaves[len(proofLower1.AccountLeaves)-1], proofLower1, proofMid, proofTop).Passed())
+	assert.True(VerifyProofPath(altProofLower0.AccountLeaves[0], altProofLower0, altProofMid, altProofTop).Passed())
 
 	// Test with invalid proofs
-	assert.Panics(func() { VerifyProofPath(proofLower0.AccountLeaves[0], proofLower1, proofMid, proofTop) }, "should panic when account is not included")
-	assert.Panics(func() { VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, CompletedProof{}) }, "should panic when proofs are incomplete")
+	assert.False(VerifyProofPath(proofLower0.AccountLeaves[0], proofLower1, proofMid, proofTop).Passed(), "should fail when account is not included")
+	assert.False(VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, CompletedProof{}).Passed(), "should fail when proofs are incomplete")
 
 	incorrectProofTop := proofTop
 	incorrectProofTop.AssetSum = &circuit.GoBalance{
 		Bitcoin:  *big.NewInt(123),
 		Ethereum: *big.NewInt(456),
 	}
-	assert.Panics(func() { VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, incorrectProofTop) }, "should panic when asset sum is incorrect")
-	assert.Panics(func() { VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, altProofTop) }, "should panic when mid proof does not link to top proof")
-	assert.Panics(func() { VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, altProofMid, proofTop) }, "should panic when bottom proof does not link to mid proof")
+	assert.False(VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, incorrectProofTop).Passed(), "should fail when asset sum is incorrect")
+	assert.False(VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, proofMid, altProofTop).Passed(), "should fail when mid proof does not link to top proof")
+	assert.False(VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, altProofMid, proofTop).Passed(), "should fail when bottom proof does not link to mid proof")
+}
+
+func TestVerifyProofPathReportsFailingChecks(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	report := VerifyProofPath(proofLower0.AccountLeaves[0], proofLower0, altProofMid, proofTop, WithProofPathFiles("bottom.json", "mid.json", "top.json"))
+	assert.Equal(10, len(report.Checks), "every check should be recorded")
+	failures := report.Failures()
+	assert.Equal(2, len(failures))
+	assert.Equal(CheckChildToParent, failures[0].Kind)
+	assert.Equal("mid.json", failures[0].File)
+	assert.Equal(CheckChildToParent, failures[1].Kind)
+	assert.Equal("top.json", failures[1].File)
+	assert.NotEmpty(failures[0].Error)
+}
+
+// copyTestProofs copies the testdata proofs into dir under the names Prove gives them.
+func copyTestProofs(t *testing.T, dir string) {
+	for _, name := range []string{"test_proof_0.json", "test_proof_1.json", "test_mid_level_proof_0.json", "test_top_level_proof_0.json"} {
+		b, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, name), b, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// copyTestProofsToPublicDir lays out the testdata proofs as 'out/public/' in a temporary working directory.
+func copyTestProofsToPublicDir(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, publicDir), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyTestProofs(t, filepath.Join(dir, publicDir))
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		err := os.Chdir(wd)
+		if err != nil {
+			panic(err)
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	assert := test.NewAssert(t)
+	account := ReadDataFromFile[ProofElements]("testdata/test_data_1.json").Accounts[3]
+	copyTestProofsToPublicDir(t)
+
+	report := Verify(2, account)
+	assert.True(report.Passed())
+	for _, check := range report.Checks {
+		if check.Kind == CheckUserInclusion {
+			assert.Equal("out/public/test_proof_1.json", check.File)
+		}
+	}
+
+	account.Balance.Bitcoin = *big.NewInt(1)
+	report = Verify(2, account)
+	assert.False(report.Passed(), "should fail when the account is not included")
+	assert.Equal(1, len(report.Failures()))
+	assert.Equal(CheckUserInclusion, report.Failures()[0].Kind)
+}
+
+func TestVerifyWithWorkers(t *testing.T) {
+	assert := test.NewAssert(t)
+	account := ReadDataFromFile[ProofElements]("testdata/test_data_0.json").Accounts[0]
+	copyTestProofsToPublicDir(t)
+
+	sequential := Verify(2, account, WithWorkers(1))
+	parallel := Verify(2, account, WithWorkers(4))
+	assert.True(sequential.Passed())
+	assert.True(parallel.Passed())
+	assert.Equal(len(sequential.Checks), len(parallel.Checks))
+	for i := range sequential.Checks {
+		assert.Equal(sequential.Checks[i].Kind, parallel.Checks[i].Kind, "checks should be reported in file order")
+		assert.Equal(sequential.Checks[i].File, parallel.Checks[i].File, "checks should be reported in file order")
+	}
+}
+
+func TestVerifyReportsMissingProofFiles(t *testing.T) {
+	assert := test.NewAssert(t)
+	account := ReadDataFromFile[ProofElements]("testdata/test_data_0.json").Accounts[0]
+	copyTestProofsToPublicDir(t)
+
+	report := Verify(3, account)
+	assert.False(report.Passed())
+	failures := report.Failures()
+	assert.Equal(CheckProofFile, failures[0].Kind)
+	assert.Equal("out/public/test_proof_2.json", failures[0].File)
+	assert.Equal(CheckChildToParent, failures[1].Kind, "the missing proof should break the link to the mid level proof")
+}
+
+func TestDecodeVerifyingKeyIsCached(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	assert.True(decodeVerifyingKey(proofLower0.VK) == decodeVerifyingKey(proofLower1.VK), "proofs of the same size should share a decoded VK")
+	assert.False(decodeVerifyingKey(proofLower0.VK) == decodeVerifyingKey(proofMid.VK))
+	assert.Panics(func() { decodeVerifyingKey("stuff") })
 }
@@ -0,0 +1,275 @@
+package reserves
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc/secp256k1"
+	"github.com/consensys/gnark-crypto/ecc/secp256k1/ecdsa"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	bitcoinMessageMagic = "Bitcoin Signed Message:\n"
+
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	bech32Charset  = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// bech32Constant and bech32mConstant are what the checksum of a bech32 and a bech32m string leave
+	bech32Constant  = 1
+	bech32mConstant = 0x2bc830a3
+
+	bip322Unsupported = "its ownership needs a BIP-322 signature, which is not supported"
+)
+
+// bitcoinAddressKind is how an address pays to the hash of a public key.
+type bitcoinAddressKind int
+
+const (
+	p2pkh bitcoinAddressKind = iota
+	// p2shP2wpkh pays to the hash of the script of a P2WPKH output, as wrapped segwit addresses do. Other P2SH
+	// addresses, such as multisig ones, cannot be told apart from it and fail to match the key that signed
+	p2shP2wpkh
+	p2wpkh
+)
+
+// recoverPublicKey recovers the public key of the secp256k1 signature r || s of hash with the recovery id.
+func recoverPublicKey(hash []byte, recoveryId byte, rs []byte) (*secp256k1.G1Affine, error) {
+	var publicKey ecdsa.PublicKey
+	r, s := new(big.Int).SetBytes(rs[:32]), new(big.Int).SetBytes(rs[32:64])
+	if err := publicKey.RecoverFrom(hash, uint(recoveryId), r, s); err != nil {
+		return nil, err
+	}
+	if publicKey.A.IsInfinity() {
+		return nil, errors.New("signature recovers no public key")
+	}
+	return &publicKey.A, nil
+}
+
+// serializePublicKey encodes a public key as SEC 1 does, compressed or not.
+func serializePublicKey(publicKey *secp256k1.G1Affine, compressed bool) []byte {
+	raw := publicKey.RawBytes()
+	if !compressed {
+		return append([]byte{0x04}, raw[:]...)
+	}
+	prefix := byte(0x02)
+	if raw[63]&1 == 1 {
+		prefix = 0x03
+	}
+	return append([]byte{prefix}, raw[:32]...)
+}
+
+func hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+	hasher := ripemd160.New()
+	hasher.Write(sha[:])
+	return hasher.Sum(nil)
+}
+
+func doubleSha256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+func appendVarString(b []byte, s string) []byte {
+	switch n := uint64(len(s)); {
+	case n < 0xfd:
+		b = append(b, byte(n))
+	case n <= 0xffff:
+		b = binary.LittleEndian.AppendUint16(append(b, 0xfd), uint16(n))
+	case n <= 0xffffffff:
+		b = binary.LittleEndian.AppendUint32(append(b, 0xfe), uint32(n))
+	default:
+		b = binary.LittleEndian.AppendUint64(append(b, 0xff), n)
+	}
+	return append(b, s...)
+}
+
+// bitcoinMessageHash is the hash signmessage signs: the double SHA-256 of the magic prefix and the message.
+func bitcoinMessageHash(message string) []byte {
+	return doubleSha256(appendVarString(appendVarString(nil, bitcoinMessageMagic), message))
+}
+
+func decodeBase58Check(address string) (version byte, payload []byte, err error) {
+	value := new(big.Int)
+	for _, c := range address {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			return 0, nil, fmt.Errorf("%q is not a base58 character", c)
+		}
+		value.Mul(value, big.NewInt(58)).Add(value, big.NewInt(int64(digit)))
+	}
+	decoded := value.Bytes()
+	for _, c := range address {
+		if c != rune(base58Alphabet[0]) {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+	if len(decoded) < 5 {
+		return 0, nil, errors.New("too short")
+	}
+	body, checksum := decoded[:len(decoded)-4], decoded[len(decoded)-4:]
+	if !bytes.Equal(doubleSha256(body)[:4], checksum) {
+		return 0, nil, errors.New("bad checksum")
+	}
+	return body[0], body[1:], nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	checksum := uint32(1)
+	for _, value := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ uint32(value)
+		for i, g := range generator {
+			if (top>>i)&1 == 1 {
+				checksum ^= g
+			}
+		}
+	}
+	return checksum
+}
+
+// decodeSegwit decodes a bech32 address of a witness program, as BIP-173 specifies, or a bech32m one of a witness
+// version from 1, as BIP-350 specifies.
+func decodeSegwit(address string) (hrp string, version byte, program []byte, err error) {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return "", 0, nil, errors.New("mixed case")
+	}
+	address = strings.ToLower(address)
+	separator := strings.LastIndexByte(address, '1')
+	if separator < 1 || separator+7 > len(address) {
+		return "", 0, nil, errors.New("no bech32 separator")
+	}
+	hrp = address[:separator]
+	values := make([]byte, 0, 2*len(hrp)+1+len(address)-separator-1)
+	for _, c := range hrp {
+		values = append(values, byte(c>>5))
+	}
+	values = append(values, 0)
+	for _, c := range hrp {
+		values = append(values, byte(c&31))
+	}
+	data := make([]byte, 0, len(address)-separator-1)
+	for _, c := range address[separator+1:] {
+		digit := strings.IndexRune(bech32Charset, c)
+		if digit < 0 {
+			return "", 0, nil, fmt.Errorf("%q is not a bech32 character", c)
+		}
+		data = append(data, byte(digit))
+	}
+	checksum := bech32Polymod(append(values, data...))
+	data = data[:len(data)-6]
+	if len(data) == 0 {
+		return "", 0, nil, errors.New("no witness version")
+	}
+	version = data[0]
+	if (version == 0 && checksum != bech32Constant) || (version != 0 && checksum != bech32mConstant) {
+		return "", 0, nil, errors.New("bad checksum")
+	}
+	// regroup the 5 bit values after the version into bytes
+	var accumulator, bits uint
+	for _, value := range data[1:] {
+		accumulator = accumulator<<5 | uint(value)
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			program = append(program, byte(accumulator>>bits))
+		}
+	}
+	if bits >= 5 || accumulator&(1<<bits-1) != 0 {
+		return "", 0, nil, errors.New("bad padding")
+	}
+	return hrp, version, program, nil
+}
+
+// isSegwitAddress tells whether address has the human-readable part of a segwit address of a Bitcoin network.
+func isSegwitAddress(address string) bool {
+	lower := strings.ToLower(address)
+	return strings.HasPrefix(lower, "bc1") || strings.HasPrefix(lower, "tb1") || strings.HasPrefix(lower, "bcrt1")
+}
+
+// decodeBitcoinAddress returns what kind of address it is and the hash it pays to, for mainnet, testnet and regtest.
+// Addresses paying to a script or a Taproot key are rejected, as their ownership is only shown by BIP-322 signatures.
+func decodeBitcoinAddress(address string) (bitcoinAddressKind, []byte, error) {
+	if isSegwitAddress(address) {
+		_, version, program, err := decodeSegwit(address)
+		if err != nil {
+			return 0, nil, fmt.Errorf("address %s: %w", address, err)
+		}
+		switch {
+		case version == 0 && len(program) == 20:
+			return p2wpkh, program, nil
+		case version == 0 && len(program) == 32:
+			return 0, nil, fmt.Errorf("address %s is a P2WSH address, which pays to a script; %s", address, bip322Unsupported)
+		case version == 1 && len(program) == 32:
+			return 0, nil, fmt.Errorf("address %s is a Taproot address; %s", address, bip322Unsupported)
+		default:
+			return 0, nil, fmt.Errorf("address %s is a witness version %d address of a %d byte program, which is not supported", address, version, len(program))
+		}
+	}
+	version, payload, err := decodeBase58Check(address)
+	if err != nil {
+		return 0, nil, fmt.Errorf("address %s: %w", address, err)
+	}
+	if len(payload) != 20 {
+		return 0, nil, fmt.Errorf("address %s has a %d byte hash", address, len(payload))
+	}
+	switch version {
+	case 0x00, 0x6f:
+		return p2pkh, payload, nil
+	case 0x05, 0xc4:
+		return p2shP2wpkh, payload, nil
+	default:
+		return 0, nil, fmt.Errorf("address %s has unknown version %d", address, version)
+	}
+}
+
+// verifyBitcoinSignature checks signature is a legacy signmessage signature of message by the key address pays to.
+// The base64 signature is a header byte and r || s; the header is 27 plus the recovery id, plus 4 if the key is
+// compressed, or 35 for P2SH-P2WPKH and 39 for P2WPKH addresses as BIP-137 specifies.
+func verifyBitcoinSignature(address string, message string, signature string) error {
+	kind, hash, err := decodeBitcoinAddress(address)
+	if err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(decoded) != 65 {
+		return errors.New("signature is not 65 base64 encoded bytes")
+	}
+	header := decoded[0]
+	if header < 27 || header > 42 {
+		return fmt.Errorf("signature has unknown header %d", header)
+	}
+	compressed := header >= 31
+	// headers from 35 name the kind of segwit address; below, they are the P2PKH headers some wallets also use for segwit
+	if (kind == p2pkh && header >= 35) || (kind == p2shP2wpkh && header >= 39) || (kind == p2wpkh && header >= 35 && header < 39) {
+		return fmt.Errorf("signature header %d is for another kind of address", header)
+	}
+	if kind != p2pkh && !compressed {
+		return errors.New("segwit addresses pay to compressed keys only")
+	}
+	publicKey, err := recoverPublicKey(bitcoinMessageHash(message), (header-27)&3, decoded[1:])
+	if err != nil {
+		return err
+	}
+	keyHash := hash160(serializePublicKey(publicKey, compressed))
+	if kind == p2shP2wpkh {
+		keyHash = hash160(append([]byte{0x00, 0x14}, keyHash...))
+		if !bytes.Equal(keyHash, hash) {
+			return fmt.Errorf("%s is not the P2SH-P2WPKH address of the signing key; other P2SH addresses, such as multisig ones, pay to a script and %s", address, bip322Unsupported)
+		}
+	}
+	if !bytes.Equal(keyHash, hash) {
+		return fmt.Errorf("signature is not by the key of %s", address)
+	}
+	return nil
+}
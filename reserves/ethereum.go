@@ -0,0 +1,90 @@
+package reserves
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const ethereumMessagePrefix = "\x19Ethereum Signed Message:\n"
+
+func keccak256(data ...[]byte) []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		hasher.Write(d)
+	}
+	return hasher.Sum(nil)
+}
+
+// ethereumMessageHash is the hash personal_sign signs, as EIP-191 version 0x45 specifies.
+func ethereumMessageHash(message string) []byte {
+	return keccak256([]byte(ethereumMessagePrefix+strconv.Itoa(len(message))), []byte(message))
+}
+
+// checksumAddress returns the EIP-55 form of a hex address, whose letters are upper case where the same nibble of
+// the Keccak-256 hash of the lower case address is 8 or more.
+func checksumAddress(address string) string {
+	lower := strings.ToLower(strings.TrimPrefix(address, "0x"))
+	hash := keccak256([]byte(lower))
+	checksummed := []byte(lower)
+	for i, c := range checksummed {
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0xf
+		}
+		if c >= 'a' && nibble >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(checksummed)
+}
+
+// checkEthereumAddress checks address is 20 hex encoded bytes and, unless its letters are all of one case, that
+// their case is the EIP-55 checksum.
+func checkEthereumAddress(address string) error {
+	digits, found := strings.CutPrefix(address, "0x")
+	if decoded, err := hex.DecodeString(digits); !found || err != nil || len(decoded) != 20 {
+		return fmt.Errorf("address %s is not 20 hex encoded bytes", address)
+	}
+	if digits == strings.ToLower(digits) || digits == strings.ToUpper(digits) {
+		return nil
+	}
+	if checksummed := checksumAddress(address); checksummed != address {
+		return fmt.Errorf("address %s does not match its EIP-55 checksum, %s", address, checksummed)
+	}
+	return nil
+}
+
+// verifyEthereumSignature checks signature is an EIP-191 personal_sign signature of message by address. The hex
+// signature is r || s || v, with v 27 or 28, or the recovery id itself. A mixed case address must have a valid
+// EIP-55 checksum.
+func verifyEthereumSignature(address string, message string, signature string) error {
+	if err := checkEthereumAddress(address); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(decoded) != 65 {
+		return errors.New("signature is not 65 hex encoded bytes")
+	}
+	v := decoded[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return fmt.Errorf("signature has unknown recovery id %d", decoded[64])
+	}
+	publicKey, err := recoverPublicKey(ethereumMessageHash(message), v, decoded[:64])
+	if err != nil {
+		return err
+	}
+	raw := publicKey.RawBytes()
+	signer := checksumAddress(hex.EncodeToString(keccak256(raw[:])[12:]))
+	if !strings.EqualFold(signer, address) {
+		return fmt.Errorf("signature is by %s, not %s", signer, address)
+	}
+	return nil
+}
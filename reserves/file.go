@@ -0,0 +1,80 @@
+package reserves
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// AccountBalance is the balance of an Ethereum account in wei.
+type AccountBalance struct {
+	Address string
+	Balance big.Int
+}
+
+// FileSnapshot is a snapshot dumped to a file: the Bitcoin UTXO set at BitcoinBlock, or the part of it paying to
+// custody addresses, and the balances of Ethereum accounts at EthereumBlock. Accounts missing from it hold nothing.
+type FileSnapshot struct {
+	BitcoinBlock  *Block `json:",omitempty"`
+	Utxos         []Utxo
+	EthereumBlock *Block `json:",omitempty"`
+	Balances      []AccountBalance
+}
+
+// ReadFileSnapshot reads a snapshot file, rejecting one that lists an output twice.
+func ReadFileSnapshot(filePath string) (*FileSnapshot, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot FileSnapshot
+	if err = json.Unmarshal(b, &snapshot); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filePath, err)
+	}
+	listed := make(map[string]bool)
+	for _, utxo := range snapshot.Utxos {
+		outpoint := fmt.Sprintf("%s:%d", utxo.TxId, utxo.Vout)
+		if listed[outpoint] {
+			return nil, fmt.Errorf("%s: output %s is listed twice", filePath, outpoint)
+		}
+		listed[outpoint] = true
+	}
+	return &snapshot, nil
+}
+
+func (snapshot *FileSnapshot) BitcoinUtxos(addresses []string) ([]Utxo, Block, error) {
+	if snapshot.BitcoinBlock == nil {
+		return nil, Block{}, fmt.Errorf("the snapshot does not record the Bitcoin block its outputs are unspent at")
+	}
+	wanted := make(map[string]bool)
+	for _, address := range addresses {
+		wanted[normalizeAddress(CustodyAddress{Asset: Bitcoin, Address: address})] = true
+	}
+	utxos := make([]Utxo, 0)
+	for _, utxo := range snapshot.Utxos {
+		if wanted[normalizeAddress(CustodyAddress{Asset: Bitcoin, Address: utxo.Address})] {
+			utxos = append(utxos, utxo)
+		}
+	}
+	return utxos, *snapshot.BitcoinBlock, nil
+}
+
+func (snapshot *FileSnapshot) EthereumBalance(address string) (*big.Int, Block, error) {
+	if snapshot.EthereumBlock == nil {
+		return nil, Block{}, fmt.Errorf("the snapshot does not record the Ethereum block its balances are at")
+	}
+	balance := new(big.Int)
+	found := false
+	for i := range snapshot.Balances {
+		if strings.EqualFold(snapshot.Balances[i].Address, address) {
+			if found {
+				return nil, Block{}, fmt.Errorf("snapshot has more than one balance of %s", address)
+			}
+			balance.Set(&snapshot.Balances[i].Balance)
+			found = true
+		}
+	}
+	return balance, *snapshot.EthereumBlock, nil
+}
@@ -0,0 +1,47 @@
+package reserves
+
+import (
+	"fmt"
+
+	"bitgo.com/proof_of_reserves/core"
+)
+
+// Challenge is the message each custody address signs to show the exchange controls it in the epoch of liabilities,
+// the published statement. It names the epoch and the top level merkle root of the liabilities, so that a signature
+// cannot be reused for another epoch.
+func Challenge(liabilities core.PublishedStatement) string {
+	return fmt.Sprintf("bgproof reserves: I control this address at epoch %d, liabilities merkle root %x", liabilities.Epoch, liabilities.MerkleRoot)
+}
+
+// VerifyOwnership checks every custody address signed the challenge of the epoch of liabilities: Bitcoin addresses
+// with a legacy signmessage signature and Ethereum addresses with an EIP-191 signature.
+func VerifyOwnership(addresses []CustodyAddress, liabilities core.PublishedStatement) error {
+	challenge := Challenge(liabilities)
+	for _, address := range addresses {
+		if address.Signature == "" {
+			return fmt.Errorf("%s address %s has no ownership signature", address.Asset, address.Address)
+		}
+		var err error
+		switch address.Asset {
+		case Bitcoin:
+			err = verifyBitcoinSignature(address.Address, challenge, address.Signature)
+		case Ethereum:
+			err = verifyEthereumSignature(address.Address, challenge, address.Signature)
+		default:
+			err = fmt.Errorf("unknown asset %q", address.Asset)
+		}
+		if err != nil {
+			return fmt.Errorf("ownership of %s address %s: %w", address.Asset, address.Address, err)
+		}
+	}
+	return nil
+}
+
+// VerifyStatementOwnership checks the custody addresses of a reserves statement signed its challenge, which must be
+// that of the epoch of liabilities. It is the core.OwnershipVerifier of Bitcoin and Ethereum addresses.
+func VerifyStatementOwnership(statement core.ReservesStatement, liabilities core.PublishedStatement) error {
+	if statement.Challenge != Challenge(liabilities) {
+		return fmt.Errorf("reserves statement was signed for the challenge %q, not that of the published statement", statement.Challenge)
+	}
+	return VerifyOwnership(statement.CustodyAddresses, liabilities)
+}
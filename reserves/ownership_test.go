@@ -0,0 +1,141 @@
+package reserves
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/consensys/gnark-crypto/ecc/secp256k1"
+	"github.com/consensys/gnark-crypto/ecc/secp256k1/ecdsa"
+	"github.com/consensys/gnark/test"
+)
+
+// the addresses of the private key 1, whose public key is the generator
+const (
+	keyOneP2pkh             = "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"
+	keyOneUncompressedP2pkh = "1EHNa6Q4Jz2uvNExL497mE43ikXhwF6kZm"
+	keyOneP2shP2wpkh        = "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN"
+	keyOneP2wpkh            = "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+	keyOneEthereum          = "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf"
+)
+
+func keyOne(t *testing.T) *ecdsa.PrivateKey {
+	_, generator := secp256k1.Generators()
+	raw := generator.RawBytes()
+	var key ecdsa.PrivateKey
+	if _, err := key.SetBytes(append(raw[:], big.NewInt(1).FillBytes(make([]byte, 32))...)); err != nil {
+		t.Fatal(err)
+	}
+	return &key
+}
+
+// signRecoverable signs hash, returning the recovery id and r || s.
+func signRecoverable(t *testing.T, key *ecdsa.PrivateKey, hash []byte) (byte, []byte) {
+	v, r, s, err := key.SignForRecover(hash, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return byte(v), append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+}
+
+// signBitcoinMessage signs message as signmessage does, with the header base of the kind of address.
+func signBitcoinMessage(t *testing.T, key *ecdsa.PrivateKey, message string, headerBase byte) string {
+	v, rs := signRecoverable(t, key, bitcoinMessageHash(message))
+	return base64.StdEncoding.EncodeToString(append([]byte{headerBase + v}, rs...))
+}
+
+func signEthereumMessage(t *testing.T, key *ecdsa.PrivateKey, message string) string {
+	v, rs := signRecoverable(t, key, ethereumMessageHash(message))
+	return "0x" + hex.EncodeToString(append(rs, 27+v))
+}
+
+// signedAddresses returns the addresses of the private key 1 with their signatures of the challenge of liabilities.
+func signedAddresses(t *testing.T, liabilities core.PublishedStatement) []CustodyAddress {
+	key := keyOne(t)
+	challenge := Challenge(liabilities)
+	return []CustodyAddress{
+		{Asset: Bitcoin, Address: keyOneP2pkh, Signature: signBitcoinMessage(t, key, challenge, 31)},
+		{Asset: Bitcoin, Address: keyOneUncompressedP2pkh, Signature: signBitcoinMessage(t, key, challenge, 27)},
+		{Asset: Bitcoin, Address: keyOneP2shP2wpkh, Signature: signBitcoinMessage(t, key, challenge, 35)},
+		{Asset: Bitcoin, Address: keyOneP2wpkh, Signature: signBitcoinMessage(t, key, challenge, 39)},
+		{Asset: Ethereum, Address: keyOneEthereum, Signature: signEthereumMessage(t, key, challenge)},
+	}
+}
+
+func TestVerifyOwnership(t *testing.T) {
+	assert := test.NewAssert(t)
+	liabilities := core.PublishedStatement{EpochContext: core.EpochContext{Epoch: 7}, MerkleRoot: []byte{1, 2, 3}}
+	addresses := signedAddresses(t, liabilities)
+	assert.NoError(VerifyOwnership(addresses, liabilities))
+
+	next := core.PublishedStatement{EpochContext: core.EpochContext{Epoch: 8}, MerkleRoot: []byte{1, 2, 3}}
+	assert.Error(VerifyOwnership(addresses, next), "signatures of another epoch's challenge should be rejected")
+	for i := range addresses {
+		tampered := append([]CustodyAddress{}, addresses...)
+		tampered[i].Signature = addresses[(i+1)%len(addresses)].Signature
+		assert.Error(VerifyOwnership(tampered, liabilities), "a signature for another address should be rejected")
+		tampered[i].Signature = ""
+		assert.Error(VerifyOwnership(tampered, liabilities), "an address without a signature should be rejected")
+	}
+	other := addresses[0]
+	other.Address = "1111111111111111111114oLvT2"
+	assert.Error(VerifyOwnership([]CustodyAddress{other}, liabilities), "a signature by another key should be rejected")
+}
+
+// encodeBase58Check encodes the payload with the version byte as a base58check address.
+func encodeBase58Check(version byte, payload []byte) string {
+	body := append([]byte{version}, payload...)
+	body = append(body, doubleSha256(body)[:4]...)
+	value := new(big.Int).SetBytes(body)
+	var encoded []byte
+	for value.Sign() > 0 {
+		digit := new(big.Int)
+		value.DivMod(value, big.NewInt(58), digit)
+		encoded = append([]byte{base58Alphabet[digit.Int64()]}, encoded...)
+	}
+	for _, b := range body {
+		if b != 0 {
+			break
+		}
+		encoded = append([]byte{base58Alphabet[0]}, encoded...)
+	}
+	return string(encoded)
+}
+
+func TestUnsupportedBitcoinAddresses(t *testing.T) {
+	assert := test.NewAssert(t)
+	key := keyOne(t)
+	challenge := "challenge"
+	// the BIP-173 P2WSH and BIP-350 Taproot test vectors pay to a script and a Taproot key
+	for _, address := range []string{
+		"bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3",
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+	} {
+		err := verifyBitcoinSignature(address, challenge, signBitcoinMessage(t, key, challenge, 39))
+		assert.ErrorContains(err, "BIP-322", "%s should be rejected as needing a BIP-322 signature", address)
+	}
+	// a P2SH address of a 1-of-1 multisig script of the key cannot be told apart from P2SH-P2WPKH by its address
+	publicKey := serializePublicKey(&key.PublicKey.A, true)
+	multisig := append(append([]byte{0x51, 0x21}, publicKey...), 0x51, 0xae)
+	address := encodeBase58Check(0x05, hash160(multisig))
+	assert.Equal(keyOneP2shP2wpkh, encodeBase58Check(0x05, hash160(append([]byte{0x00, 0x14}, hash160(publicKey)...))))
+	err := verifyBitcoinSignature(address, challenge, signBitcoinMessage(t, key, challenge, 35))
+	assert.ErrorContains(err, "multisig", "a P2SH address of another script should be rejected")
+}
+
+func TestEthereumChecksum(t *testing.T) {
+	assert := test.NewAssert(t)
+	key := keyOne(t)
+	challenge := "challenge"
+	signature := signEthereumMessage(t, key, challenge)
+	assert.Equal(keyOneEthereum, checksumAddress(keyOneEthereum))
+	assert.NoError(verifyEthereumSignature(keyOneEthereum, challenge, signature))
+	assert.NoError(verifyEthereumSignature(strings.ToLower(keyOneEthereum), challenge, signature), "an all lower case address has no checksum")
+	assert.NoError(verifyEthereumSignature("0x"+strings.ToUpper(keyOneEthereum[2:]), challenge, signature), "an all upper case address has no checksum")
+	miscased := "0x7e" + keyOneEthereum[4:]
+	assert.ErrorContains(verifyEthereumSignature(miscased, challenge, signature), "EIP-55", "a mixed case address must match its checksum")
+	assert.Error(verifyEthereumSignature(keyOneEthereum[:41], challenge, signature), "an address must be 20 bytes")
+}
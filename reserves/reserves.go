@@ -0,0 +1,160 @@
+// Package reserves computes the reserves of each asset held at an exchange's custody addresses from a local chain
+// snapshot: a snapshot file of Bitcoin UTXOs and Ethereum account balances, or nodes answering JSON-RPC. The totals
+// are in the base units of circuit.GoBalance, satoshis and wei, so that they can be compared with the liabilities.
+package reserves
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"bitgo.com/proof_of_reserves/core"
+)
+
+const (
+	Bitcoin  = "Bitcoin"
+	Ethereum = "Ethereum"
+)
+
+// CustodyAddress is an address the exchange holds Asset at. Signature shows the exchange controls it; see
+// VerifyOwnership.
+type CustodyAddress = core.CustodyAddress
+
+// Utxo is an unspent Bitcoin output paying Value satoshis to Address.
+type Utxo struct {
+	TxId    string
+	Vout    uint32
+	Address string
+	Value   uint64
+}
+
+// Block is a block of a chain, which a snapshot is taken at.
+type Block struct {
+	Height uint64
+	Hash   string
+}
+
+// Snapshot reports what addresses hold at one block of each chain.
+type Snapshot interface {
+	// BitcoinUtxos returns the unspent outputs paying to any of addresses, and the block they are unspent at.
+	BitcoinUtxos(addresses []string) ([]Utxo, Block, error)
+	// EthereumBalance returns the balance of address in wei, and the block it is the balance at.
+	EthereumBalance(address string) (*big.Int, Block, error)
+}
+
+// ReadCustodyAddresses reads a JSON list of custody addresses, rejecting unknown assets and repeated addresses,
+// which would count their holdings twice.
+func ReadCustodyAddresses(filePath string) ([]CustodyAddress, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []CustodyAddress
+	if err = json.Unmarshal(b, &addresses); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filePath, err)
+	}
+	seen := make(map[CustodyAddress]bool)
+	for _, address := range addresses {
+		if address.Asset != Bitcoin && address.Asset != Ethereum {
+			return nil, fmt.Errorf("%s: unknown asset %q of address %s", filePath, address.Asset, address.Address)
+		}
+		if address.Address == "" {
+			return nil, fmt.Errorf("%s: empty %s address", filePath, address.Asset)
+		}
+		key := CustodyAddress{Asset: address.Asset, Address: normalizeAddress(address)}
+		if seen[key] {
+			return nil, fmt.Errorf("%s: %s address %s is listed twice", filePath, address.Asset, address.Address)
+		}
+		seen[key] = true
+	}
+	return addresses, nil
+}
+
+// normalizeAddress returns the form addresses are compared in: Ethereum addresses are case-insensitive, the case only
+// being a checksum, and so are segwit Bitcoin addresses, which may be written all in upper case. Base58 Bitcoin
+// addresses are kept as they are, as their case is part of the address.
+func normalizeAddress(address CustodyAddress) string {
+	if address.Asset == Ethereum || (address.Asset == Bitcoin && isSegwitAddress(address.Address)) {
+		return strings.ToLower(address.Address)
+	}
+	return address.Address
+}
+
+// Total sums what the custody addresses hold in snapshot, and returns the block of each chain it was read at. Every
+// Ethereum balance must be at the same block. Each Bitcoin output must pay to one of the addresses, and an output
+// listed twice is rejected rather than counted twice.
+func Total(addresses []CustodyAddress, snapshot Snapshot) (total circuit.GoBalance, blocks []core.SnapshotBlock, err error) {
+	bitcoinAddresses := make([]string, 0)
+	listed := make(map[string]bool)
+	var ethereumBlock *Block
+	for _, address := range addresses {
+		switch address.Asset {
+		case Bitcoin:
+			bitcoinAddresses = append(bitcoinAddresses, address.Address)
+			listed[normalizeAddress(address)] = true
+		case Ethereum:
+			balance, block, err := snapshot.EthereumBalance(address.Address)
+			if err != nil {
+				return total, nil, fmt.Errorf("balance of %s: %w", address.Address, err)
+			}
+			if balance.Sign() < 0 {
+				return total, nil, fmt.Errorf("balance of %s is negative", address.Address)
+			}
+			if ethereumBlock != nil && block != *ethereumBlock {
+				return total, nil, fmt.Errorf("balance of %s is at block %d, not at block %d as the others", address.Address, block.Height, ethereumBlock.Height)
+			}
+			ethereumBlock = &block
+			total.Ethereum.Add(&total.Ethereum, balance)
+		default:
+			return total, nil, fmt.Errorf("unknown asset %q of address %s", address.Asset, address.Address)
+		}
+	}
+	if len(bitcoinAddresses) != 0 {
+		utxos, block, err := snapshot.BitcoinUtxos(bitcoinAddresses)
+		if err != nil {
+			return total, nil, fmt.Errorf("unspent outputs: %w", err)
+		}
+		counted := make(map[string]bool)
+		for _, utxo := range utxos {
+			if !listed[normalizeAddress(CustodyAddress{Asset: Bitcoin, Address: utxo.Address})] {
+				return total, nil, fmt.Errorf("output %s:%d pays to %s, which is not a custody address", utxo.TxId, utxo.Vout, utxo.Address)
+			}
+			outpoint := fmt.Sprintf("%s:%d", utxo.TxId, utxo.Vout)
+			if counted[outpoint] {
+				return total, nil, fmt.Errorf("output %s is listed twice", outpoint)
+			}
+			counted[outpoint] = true
+			total.Bitcoin.Add(&total.Bitcoin, new(big.Int).SetUint64(utxo.Value))
+		}
+		blocks = append(blocks, core.SnapshotBlock{Asset: Bitcoin, Height: block.Height, Hash: block.Hash})
+	}
+	if ethereumBlock != nil {
+		blocks = append(blocks, core.SnapshotBlock{Asset: Ethereum, Height: ethereumBlock.Height, Hash: ethereumBlock.Hash})
+	}
+	return total, blocks, nil
+}
+
+// NewStatement totals what the custody addresses hold in snapshot into the reserves statement of the epoch of
+// liabilities, the published statement it is linked to, signed with the issuer's key. The statement records the
+// block of each chain the totals were read at, and the addresses with their signatures of the epoch's challenge, which
+// every address must have signed.
+func NewStatement(addresses []CustodyAddress, snapshot Snapshot, liabilities core.PublishedStatement, privateKey ed25519.PrivateKey) (core.ReservesStatement, error) {
+	if err := VerifyOwnership(addresses, liabilities); err != nil {
+		return core.ReservesStatement{}, err
+	}
+	total, blocks, err := Total(addresses, snapshot)
+	if err != nil {
+		return core.ReservesStatement{}, err
+	}
+	statement := core.ReservesStatement{
+		Reserves:         total,
+		Blocks:           blocks,
+		Challenge:        Challenge(liabilities),
+		CustodyAddresses: addresses,
+	}
+	return core.SignReservesStatement(statement, liabilities, privateKey), nil
+}
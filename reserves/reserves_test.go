@@ -0,0 +1,163 @@
+package reserves
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/consensys/gnark/test"
+)
+
+var testAddresses = []CustodyAddress{
+	{Asset: Bitcoin, Address: "bc1qcustody0"},
+	{Asset: Bitcoin, Address: "bc1qcustody1"},
+	{Asset: Ethereum, Address: "0xAbC0000000000000000000000000000000000001"},
+}
+
+func TestTotalOfFileSnapshot(t *testing.T) {
+	assert := test.NewAssert(t)
+	snapshot := &FileSnapshot{
+		BitcoinBlock: &Block{Height: 860000, Hash: "00000000000000000001"},
+		Utxos: []Utxo{
+			{TxId: "aa", Vout: 0, Address: "bc1qcustody0", Value: 5000},
+			{TxId: "aa", Vout: 1, Address: "bc1qcustody1", Value: 700},
+			{TxId: "bb", Vout: 0, Address: "bc1qsomeoneelse", Value: 1_000_000},
+		},
+		EthereumBlock: &Block{Height: 20000000, Hash: "0xbeef"},
+		Balances: []AccountBalance{
+			{Address: "0xabc0000000000000000000000000000000000001", Balance: *big.NewInt(3_000_000_000)},
+			{Address: "0xabc0000000000000000000000000000000000002", Balance: *big.NewInt(1)},
+		},
+	}
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	b, err := json.Marshal(snapshot)
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(file, b, 0o644))
+	snapshot, err = ReadFileSnapshot(file)
+	assert.NoError(err)
+
+	total, blocks, err := Total(testAddresses, snapshot)
+	assert.NoError(err)
+	assert.Equal(int64(5700), total.Bitcoin.Int64(), "only custody outputs count")
+	assert.Equal(int64(3_000_000_000), total.Ethereum.Int64())
+	assert.Equal([]core.SnapshotBlock{{Asset: Bitcoin, Height: 860000, Hash: "00000000000000000001"}, {Asset: Ethereum, Height: 20000000, Hash: "0xbeef"}}, blocks)
+
+	// an output listed twice would be counted twice
+	snapshot.Utxos = append(snapshot.Utxos, Utxo{TxId: "aa", Vout: 0, Address: "bc1qcustody0", Value: 5000})
+	_, _, err = Total(testAddresses, snapshot)
+	assert.Error(err)
+	b, err = json.Marshal(snapshot)
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(file, b, 0o644))
+	_, err = ReadFileSnapshot(file)
+	assert.Error(err, "a snapshot listing an output twice should be rejected")
+
+	// segwit addresses are case-insensitive, whichever side writes them in upper case
+	snapshot.Utxos = snapshot.Utxos[:3]
+	snapshot.Utxos[1].Address = "BC1QCUSTODY1"
+	upperCase := []CustodyAddress{testAddresses[0], {Asset: Bitcoin, Address: "BC1QCUSTODY1"}, testAddresses[2]}
+	for _, addresses := range [][]CustodyAddress{testAddresses, upperCase} {
+		total, _, err = Total(addresses, snapshot)
+		assert.NoError(err)
+		assert.Equal(int64(5700), total.Bitcoin.Int64(), "an output to an upper-case segwit address should count")
+	}
+
+	// the statement records the blocks the reserves were read at
+	snapshot.BitcoinBlock = nil
+	_, _, err = Total(testAddresses, snapshot)
+	assert.Error(err, "a snapshot without its block should be rejected")
+}
+
+func TestReadCustodyAddresses(t *testing.T) {
+	assert := test.NewAssert(t)
+	file := filepath.Join(t.TempDir(), "addresses.json")
+	write := func(addresses []CustodyAddress) {
+		b, err := json.Marshal(addresses)
+		assert.NoError(err)
+		assert.NoError(os.WriteFile(file, b, 0o644))
+	}
+
+	write(testAddresses)
+	addresses, err := ReadCustodyAddresses(file)
+	assert.NoError(err)
+	assert.Equal(testAddresses, addresses)
+
+	write(append(testAddresses, CustodyAddress{Asset: Ethereum, Address: "0xabc0000000000000000000000000000000000001"}))
+	_, err = ReadCustodyAddresses(file)
+	assert.Error(err, "an address listed twice would be counted twice")
+	write(append(testAddresses, CustodyAddress{Asset: Bitcoin, Address: "BC1QCUSTODY0"}))
+	_, err = ReadCustodyAddresses(file)
+	assert.Error(err, "a segwit address listed twice in another case would be counted twice")
+	write(append(testAddresses, CustodyAddress{Asset: Bitcoin, Address: "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"}, CustodyAddress{Asset: Bitcoin, Address: "1bvbmseystwetqtfn5au4m4gfg7xjanvn2"}))
+	_, err = ReadCustodyAddresses(file)
+	assert.NoError(err, "base58 addresses differing in case are different addresses")
+	write([]CustodyAddress{{Asset: "Dogecoin", Address: "D123"}})
+	_, err = ReadCustodyAddresses(file)
+	assert.Error(err)
+}
+
+// newTestNode answers listunspent and eth_getBalance as a Bitcoin and an Ethereum node would, with one output paying
+// bitcoinAmount to the first address asked for.
+func newTestNode(t *testing.T, bitcoinAmount string) *httptest.Server {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+			return
+		}
+		switch request.Method {
+		case "getbestblockhash":
+			_, _ = w.Write([]byte(`{"result": "00000000000000000001", "error": null}`))
+		case "getblockheader":
+			_, _ = w.Write([]byte(`{"result": {"hash": "00000000000000000001", "height": 860000}, "error": null}`))
+		case "eth_getBlockByNumber":
+			_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"number": "0x10", "hash": "0xbeef"}}`))
+		case "listunspent":
+			address := request.Params[2].([]any)[0].(string)
+			_, _ = w.Write([]byte(`{"result": [{"txid": "aa", "vout": 0, "address": "` + address + `", "amount": ` + bitcoinAmount + `}], "error": null}`))
+		case "eth_getBalance":
+			if at, ok := request.Params[1].(map[string]any); !ok || at["blockHash"] != "0xbeef" {
+				t.Errorf("balance asked at block %v rather than by the hash of the block resolved", request.Params[1])
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0xde0b6b3a7640000"}`))
+		default:
+			_, _ = w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "error": {"code": -32601, "message": "Method not found"}}`))
+		}
+	}))
+	t.Cleanup(node.Close)
+	return node
+}
+
+func TestNewStatementFromRpc(t *testing.T) {
+	assert := test.NewAssert(t)
+	node := newTestNode(t, "0.12345678")
+	_, privateKey, err := core.GenerateSigningKey()
+	assert.NoError(err)
+	topLevelProof, err := core.ReadTopLevelProof("../core/testdata")
+	assert.NoError(err)
+	liabilities := core.NewPublishedStatement(3, topLevelProof)
+
+	addresses := signedAddresses(t, liabilities)
+	statement, err := NewStatement(addresses, NewRpcSnapshot(node.URL, node.URL, "0x10"), liabilities, privateKey)
+	assert.NoError(err)
+	assert.Equal(int64(12_345_678), statement.Reserves.Bitcoin.Int64())
+	assert.Equal(int64(1_000_000_000_000_000_000), statement.Reserves.Ethereum.Int64())
+	assert.Equal(liabilities.Epoch, statement.Epoch)
+	assert.Equal(liabilities.Hash(), statement.LiabilitiesStatementHash)
+	assert.Equal([]core.SnapshotBlock{{Asset: Bitcoin, Height: 860000, Hash: "00000000000000000001"}, {Asset: Ethereum, Height: 16, Hash: "0xbeef"}}, statement.Blocks)
+	assert.Equal(addresses, statement.CustodyAddresses)
+	assert.NoError(VerifyStatementOwnership(statement, liabilities))
+	assert.Error(VerifyStatementOwnership(statement, core.NewPublishedStatement(4, topLevelProof)), "the addresses signed the challenge of another epoch")
+
+	_, err = NewStatement(addresses, NewRpcSnapshot(newTestNode(t, "0.123456789").URL, node.URL, "0x10"), liabilities, privateKey)
+	assert.Error(err, "amounts finer than a satoshi should be rejected")
+	_, err = NewStatement(addresses, NewRpcSnapshot(node.URL, "", ""), liabilities, privateKey)
+	assert.Error(err, "Ethereum addresses need an Ethereum node")
+	_, err = NewStatement(addresses, NewRpcSnapshot(node.URL, node.URL, "0x10"), core.NewPublishedStatement(4, topLevelProof), privateKey)
+	assert.Error(err, "addresses that did not sign the epoch's challenge should be rejected")
+}
@@ -0,0 +1,203 @@
+package reserves
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	rpcTimeout = 30 * time.Second
+
+	// satoshisPerBitcoin converts the amounts listunspent reports in bitcoin to satoshis
+	satoshisPerBitcoin = 100_000_000
+)
+
+// RpcSnapshot asks nodes over JSON-RPC: a Bitcoin node for listunspent at its chain tip and an Ethereum node for
+// eth_getBalance at EthereumBlock, "latest" unless a block number is given to pin the snapshot. The Ethereum block is
+// resolved once, so that every balance is read at the same block. Credentials can be given in the URLs.
+type RpcSnapshot struct {
+	BitcoinUrl    string
+	EthereumUrl   string
+	EthereumBlock string
+	client        *http.Client
+	// ethereumAt is EthereumBlock once resolved
+	ethereumAt *Block
+}
+
+func NewRpcSnapshot(bitcoinUrl string, ethereumUrl string, ethereumBlock string) *RpcSnapshot {
+	if ethereumBlock == "" {
+		ethereumBlock = "latest"
+	}
+	return &RpcSnapshot{
+		BitcoinUrl:    bitcoinUrl,
+		EthereumUrl:   ethereumUrl,
+		EthereumBlock: ethereumBlock,
+		client:        &http.Client{Timeout: rpcTimeout},
+	}
+}
+
+type rpcRequest struct {
+	JsonRpc string `json:"jsonrpc"`
+	Id      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+func (snapshot *RpcSnapshot) call(url string, method string, params []any, result any) error {
+	if url == "" {
+		return fmt.Errorf("no node to call %s on", method)
+	}
+	body, err := json.Marshal(rpcRequest{JsonRpc: "2.0", Id: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	response, err := snapshot.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var decoded rpcResponse
+	decoder := json.NewDecoder(response.Body)
+	decoder.UseNumber()
+	if err = decoder.Decode(&decoded); err != nil {
+		return fmt.Errorf("%s: %s: %w", method, response.Status, err)
+	}
+	if decoded.Error != nil {
+		return fmt.Errorf("%s: %s (code %d)", method, decoded.Error.Message, decoded.Error.Code)
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", method, response.Status)
+	}
+	if decoded.Result == nil || string(decoded.Result) == "null" {
+		return fmt.Errorf("%s: no result", method)
+	}
+	decoder = json.NewDecoder(bytes.NewReader(decoded.Result))
+	decoder.UseNumber()
+	return decoder.Decode(result)
+}
+
+// parseSatoshis converts a decimal bitcoin amount to satoshis exactly, without going through a float.
+func parseSatoshis(amount json.Number) (uint64, error) {
+	value, ok := new(big.Rat).SetString(amount.String())
+	if !ok {
+		return 0, fmt.Errorf("amount %s is not a number", amount)
+	}
+	value.Mul(value, new(big.Rat).SetInt64(satoshisPerBitcoin))
+	if !value.IsInt() || value.Sign() < 0 || !value.Num().IsUint64() {
+		return 0, fmt.Errorf("amount %s is not a whole number of satoshis", amount)
+	}
+	return value.Num().Uint64(), nil
+}
+
+// parseQuantity parses a hex encoded quantity of the Ethereum JSON-RPC interface.
+func parseQuantity(method string, quantity string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(quantity, "0x"), 16)
+	if !strings.HasPrefix(quantity, "0x") || !ok {
+		return nil, fmt.Errorf("%s: %q is not a hex quantity", method, quantity)
+	}
+	return value, nil
+}
+
+// bitcoinTip returns the block at the tip of the Bitcoin node's chain.
+func (snapshot *RpcSnapshot) bitcoinTip() (Block, error) {
+	var tip Block
+	if err := snapshot.call(snapshot.BitcoinUrl, "getbestblockhash", []any{}, &tip.Hash); err != nil {
+		return tip, err
+	}
+	var header struct {
+		Height uint64 `json:"height"`
+	}
+	if err := snapshot.call(snapshot.BitcoinUrl, "getblockheader", []any{tip.Hash}, &header); err != nil {
+		return tip, err
+	}
+	tip.Height = header.Height
+	return tip, nil
+}
+
+func (snapshot *RpcSnapshot) BitcoinUtxos(addresses []string) ([]Utxo, Block, error) {
+	tip, err := snapshot.bitcoinTip()
+	if err != nil {
+		return nil, tip, err
+	}
+	var unspent []struct {
+		TxId    string      `json:"txid"`
+		Vout    uint32      `json:"vout"`
+		Address string      `json:"address"`
+		Amount  json.Number `json:"amount"`
+	}
+	// every confirmed output, however deep
+	err = snapshot.call(snapshot.BitcoinUrl, "listunspent", []any{1, 9999999, addresses}, &unspent)
+	if err != nil {
+		return nil, tip, err
+	}
+	// the outputs are only those unspent at tip if no block came in while they were listed
+	var after string
+	if err = snapshot.call(snapshot.BitcoinUrl, "getbestblockhash", []any{}, &after); err != nil {
+		return nil, tip, err
+	}
+	if after != tip.Hash {
+		return nil, tip, fmt.Errorf("the chain tip moved from %s to %s while unspent outputs were listed", tip.Hash, after)
+	}
+	utxos := make([]Utxo, len(unspent))
+	for i, output := range unspent {
+		value, err := parseSatoshis(output.Amount)
+		if err != nil {
+			return nil, tip, fmt.Errorf("output %s:%d: %w", output.TxId, output.Vout, err)
+		}
+		utxos[i] = Utxo{TxId: output.TxId, Vout: output.Vout, Address: output.Address, Value: value}
+	}
+	return utxos, tip, nil
+}
+
+// ethereumBlock resolves EthereumBlock to the block it is, once.
+func (snapshot *RpcSnapshot) ethereumBlock() (Block, error) {
+	if snapshot.ethereumAt != nil {
+		return *snapshot.ethereumAt, nil
+	}
+	var block struct {
+		Number string `json:"number"`
+		Hash   string `json:"hash"`
+	}
+	if err := snapshot.call(snapshot.EthereumUrl, "eth_getBlockByNumber", []any{snapshot.EthereumBlock, false}, &block); err != nil {
+		return Block{}, err
+	}
+	number, err := parseQuantity("eth_getBlockByNumber", block.Number)
+	if err != nil {
+		return Block{}, err
+	}
+	if !number.IsUint64() || block.Hash == "" {
+		return Block{}, fmt.Errorf("eth_getBlockByNumber: block %s has no number or hash", snapshot.EthereumBlock)
+	}
+	snapshot.ethereumAt = &Block{Height: number.Uint64(), Hash: block.Hash}
+	return *snapshot.ethereumAt, nil
+}
+
+func (snapshot *RpcSnapshot) EthereumBalance(address string) (*big.Int, Block, error) {
+	block, err := snapshot.ethereumBlock()
+	if err != nil {
+		return nil, block, err
+	}
+	var balance string
+	// asking by hash (EIP-1898) fails rather than reading another block of the same height after a reorganisation
+	err = snapshot.call(snapshot.EthereumUrl, "eth_getBalance", []any{address, map[string]any{"blockHash": block.Hash}}, &balance)
+	if err != nil {
+		return nil, block, err
+	}
+	value, err := parseQuantity("eth_getBalance", balance)
+	return value, block, err
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"container/list"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxTrackedClients bounds the limiter's memory; beyond it, the client seen least recently is forgotten.
+const maxTrackedClients = 10000
+
+type tokenBucket struct {
+	client string
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket per client IP: each client may make burst requests at once,
+// refilled at rate requests per second.
+type rateLimiter struct {
+	mu    sync.Mutex
+	rate  float64
+	burst float64
+	// clients maps each tracked client to its bucket's element in seen, which is ordered from the client seen most
+	// recently to the one seen least recently
+	clients map[string]*list.Element
+	seen    *list.List
+	now     func() time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), clients: make(map[string]*list.Element), seen: list.New(), now: time.Now}
+}
+
+func (limiter *rateLimiter) allow(client string) bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	now := limiter.now()
+	element, ok := limiter.clients[client]
+	if ok {
+		limiter.seen.MoveToFront(element)
+	} else {
+		if len(limiter.clients) >= maxTrackedClients {
+			oldest := limiter.seen.Back()
+			delete(limiter.clients, oldest.Value.(*tokenBucket).client)
+			limiter.seen.Remove(oldest)
+		}
+		element = limiter.seen.PushFront(&tokenBucket{client: client, tokens: limiter.burst, last: now})
+		limiter.clients[client] = element
+	}
+	bucket := element.Value.(*tokenBucket)
+	bucket.tokens = min(limiter.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limiter.rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+func (limiter *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if !limiter.allow(client) {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
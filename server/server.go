@@ -0,0 +1,122 @@
+// Package server serves an epoch's public proofs over HTTP: the published statement, a user's inclusion
+// bundle and verification of a user's proof path. It only reads the local proof directory.
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"bitgo.com/proof_of_reserves/circuit"
+	"bitgo.com/proof_of_reserves/core"
+)
+
+const maxAccountBodyBytes = 1 << 20
+
+// Timeouts of the HTTP server, so that slow clients cannot hold connections open.
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 10 * time.Second
+	writeTimeout      = 30 * time.Second
+)
+
+// InclusionBundle is everything a user needs to verify their account's inclusion offline.
+type InclusionBundle struct {
+	Files            core.ProofPathFiles
+	BottomLevelProof core.CompletedProof
+	MidLevelProof    core.CompletedProof
+	TopLevelProof    core.CompletedProof
+}
+
+type Server struct {
+	statement core.PublishedStatement
+	index     core.ProofPathIndex
+	limiter   *rateLimiter
+}
+
+// New loads the published statement, which must exist, of the epoch whose public proofs are in proofsDir and
+// indexes the proof path of every account leaf. Each client IP may make burst requests at once, refilled at rate
+// requests per second.
+func New(proofsDir string, rate float64, burst int) (*Server, error) {
+	statement, err := core.ReadStatementFromDir(proofsDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading the published statement: %w", err)
+	}
+	index, err := core.IndexProofPaths(proofsDir)
+	if err != nil {
+		return nil, fmt.Errorf("indexing the proofs: %w", err)
+	}
+	return &Server{statement: statement, index: index, limiter: newRateLimiter(rate, burst)}, nil
+}
+
+// HTTPServer returns an HTTP server for addr serving Handler, with timeouts for reading requests and writing
+// responses.
+func (server *Server) HTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+	}
+}
+
+func (server *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /statement", server.handleStatement)
+	mux.HandleFunc("GET /inclusion/{leafHash}", server.handleInclusion)
+	mux.HandleFunc("POST /verify", server.handleVerify)
+	return server.limiter.middleware(mux)
+}
+
+func (server *Server) handleStatement(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, &server.statement)
+}
+
+func (server *Server) handleInclusion(w http.ResponseWriter, r *http.Request) {
+	leafHash, err := hex.DecodeString(strings.TrimPrefix(r.PathValue("leafHash"), "0x"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "leaf hash must be hex encoded")
+		return
+	}
+	files, err := server.index.Find(leafHash)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	bundle := InclusionBundle{Files: files}
+	bundle.BottomLevelProof, bundle.MidLevelProof, bundle.TopLevelProof, err = core.ReadProofPath(files)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "reading the proof path: "+err.Error())
+		return
+	}
+	writeJson(w, http.StatusOK, bundle)
+}
+
+// handleVerify verifies the proof path of the posted account against the served statement.
+func (server *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	var account circuit.GoAccount
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAccountBodyBytes))
+	err := decoder.Decode(&account)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "body must be an account: "+err.Error())
+		return
+	}
+	report := server.index.VerifyProofPath(circuit.GoComputeMiMCHashForAccount(account), core.WithPublishedStatement(server.statement))
+	writeJson(w, http.StatusOK, report)
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, struct{ Error string }{Error: message})
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitgo.com/proof_of_reserves/core"
+	"github.com/consensys/gnark/test"
+)
+
+const testdata = "../core/testdata"
+
+// copyTestProofs copies the testdata proofs to a new directory, publishing the statement of their top level proof.
+func copyTestProofs(t *testing.T) string {
+	dir := t.TempDir()
+	for _, name := range []string{"test_proof_0.json", "test_proof_1.json", "test_mid_level_proof_0.json", "test_top_level_proof_0.json"} {
+		b, err := os.ReadFile(filepath.Join(testdata, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, name), b, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	topLevelProof := core.ReadDataFromFile[core.CompletedProof](filepath.Join(dir, "test_top_level_proof_0.json"))
+	err := core.WriteStatementToFile(filepath.Join(dir, "statement.json"), core.NewPublishedStatement(topLevelProof.Epoch, topLevelProof))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// newTestServer serves a copy of the testdata proofs and their statement.
+func newTestServer(t *testing.T, burst int) (*httptest.Server, string) {
+	dir := copyTestProofs(t)
+	s, err := New(dir, 1000, burst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s.Handler())
+	t.Cleanup(server.Close)
+	return server, dir
+}
+
+func TestNewRequiresStatement(t *testing.T) {
+	assert := test.NewAssert(t)
+	dir := copyTestProofs(t)
+	assert.NoError(os.Remove(filepath.Join(dir, "statement.json")))
+
+	_, err := New(dir, 1000, 10)
+	assert.Error(err, "a server should not make up the statement it serves")
+}
+
+func TestHTTPServerTimesOut(t *testing.T) {
+	assert := test.NewAssert(t)
+	s, err := New(copyTestProofs(t), 1000, 10)
+	assert.NoError(err)
+
+	httpServer := s.HTTPServer("localhost:0")
+	assert.NotEqual(time.Duration(0), httpServer.ReadHeaderTimeout)
+	assert.NotEqual(time.Duration(0), httpServer.ReadTimeout)
+	assert.NotEqual(time.Duration(0), httpServer.WriteTimeout)
+}
+
+func TestStatement(t *testing.T) {
+	assert := test.NewAssert(t)
+	server, _ := newTestServer(t, 10)
+	topLevelProof := core.ReadDataFromFile[core.CompletedProof](filepath.Join(testdata, "test_top_level_proof_0.json"))
+
+	response, err := http.Get(server.URL + "/statement")
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	var statement core.PublishedStatement
+	assert.NoError(json.NewDecoder(response.Body).Decode(&statement))
+	assert.Equal(topLevelProof.MerkleRoot, statement.MerkleRoot)
+	assert.True(statement.AssetSum.Equals(*topLevelProof.AssetSum))
+}
+
+func TestInclusion(t *testing.T) {
+	assert := test.NewAssert(t)
+	server, _ := newTestServer(t, 10)
+	bottomLevelProof := core.ReadDataFromFile[core.CompletedProof](filepath.Join(testdata, "test_proof_1.json"))
+
+	response, err := http.Get(server.URL + "/inclusion/" + hex.EncodeToString(bottomLevelProof.AccountLeaves[4]))
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	var bundle InclusionBundle
+	assert.NoError(json.NewDecoder(response.Body).Decode(&bundle))
+	assert.Equal("test_proof_1.json", filepath.Base(bundle.Files.Bottom))
+	assert.Equal(bottomLevelProof.MerkleRoot, bundle.BottomLevelProof.MerkleRoot)
+	assert.True(core.VerifyProofPath(bottomLevelProof.AccountLeaves[4], bundle.BottomLevelProof, bundle.MidLevelProof, bundle.TopLevelProof).Passed())
+
+	response, err = http.Get(server.URL + "/inclusion/1234")
+	assert.NoError(err)
+	assert.Equal(http.StatusNotFound, response.StatusCode)
+
+	response, err = http.Get(server.URL + "/inclusion/not-hex")
+	assert.NoError(err)
+	assert.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func TestInclusionOfMalformedProof(t *testing.T) {
+	assert := test.NewAssert(t)
+	server, dir := newTestServer(t, 10)
+	bottomLevelProof := core.ReadDataFromFile[core.CompletedProof](filepath.Join(testdata, "test_proof_1.json"))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "test_mid_level_proof_0.json"), []byte("{"), 0o644))
+
+	response, err := http.Get(server.URL + "/inclusion/" + hex.EncodeToString(bottomLevelProof.AccountLeaves[4]))
+	assert.NoError(err)
+	assert.Equal(http.StatusInternalServerError, response.StatusCode, "a malformed file should fail the request, not crash the server")
+}
+
+func TestVerify(t *testing.T) {
+	assert := test.NewAssert(t)
+	server, _ := newTestServer(t, 10)
+	account := core.ReadDataFromFile[core.ProofElements](filepath.Join(testdata, "test_data_0.json")).Accounts[0]
+
+	body, err := json.Marshal(&account)
+	assert.NoError(err)
+	response, err := http.Post(server.URL+"/verify", "application/json", bytes.NewReader(body))
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	var report core.VerificationReport
+	assert.NoError(json.NewDecoder(response.Body).Decode(&report))
+	assert.True(report.Passed())
+
+	response, err = http.Post(server.URL+"/verify", "application/json", bytes.NewReader([]byte("{")))
+	assert.NoError(err)
+	assert.Equal(http.StatusBadRequest, response.StatusCode)
+}
+
+func TestRateLimit(t *testing.T) {
+	assert := test.NewAssert(t)
+	server, _ := newTestServer(t, 2)
+
+	for i := 0; i < 2; i++ {
+		response, err := http.Get(server.URL + "/statement")
+		assert.NoError(err)
+		assert.Equal(http.StatusOK, response.StatusCode)
+	}
+	response, err := http.Get(server.URL + "/statement")
+	assert.NoError(err)
+	assert.Equal(http.StatusTooManyRequests, response.StatusCode)
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	assert := test.NewAssert(t)
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(2, 1)
+	limiter.now = func() time.Time { return now }
+
+	assert.True(limiter.allow("a"))
+	assert.False(limiter.allow("a"))
+	assert.True(limiter.allow("b"), "clients are limited separately")
+	now = now.Add(500 * time.Millisecond)
+	assert.True(limiter.allow("a"))
+	assert.False(limiter.allow("a"))
+}
+
+func TestRateLimiterIsBounded(t *testing.T) {
+	assert := test.NewAssert(t)
+	limiter := newRateLimiter(0, 1)
+	assert.True(limiter.allow("first"))
+	address := func(i int) string { return fmt.Sprintf("10.%d.%d.%d", i>>16, (i>>8)&0xff, i&0xff) }
+
+	// a flood of distinct clients, none of whose buckets refill, never grows the limiter past its bound
+	for i := 0; i < 3*maxTrackedClients; i++ {
+		assert.True(limiter.allow(address(i)))
+		assert.True(len(limiter.clients) <= maxTrackedClients && limiter.seen.Len() == len(limiter.clients))
+	}
+	assert.Equal(maxTrackedClients, len(limiter.clients))
+
+	// the clients seen most recently are still limited, the one seen least recently was forgotten
+	assert.False(limiter.allow(address(3*maxTrackedClients - 1)))
+	assert.True(limiter.allow("first"))
+}